@@ -0,0 +1,190 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the parsing of //go:build expressions.
+// It is used by scanner.go and thus part of its self-contained
+// set of files.
+
+package syntax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A constraint is a node of the expression tree of a //go:build line.
+type constraint interface {
+	// eval reports whether the constraint is satisfied by tags.
+	// A tag is satisfied if tags[tag] is true.
+	eval(tags map[string]bool) bool
+
+	// String returns the constraint in //go:build syntax.
+	String() string
+}
+
+type (
+	// tagConstraint is a build tag such as linux, cgo, or go1.10.
+	tagConstraint struct {
+		tag string
+	}
+
+	// !x
+	notConstraint struct {
+		x constraint
+	}
+
+	// x && y
+	andConstraint struct {
+		x, y constraint
+	}
+
+	// x || y
+	orConstraint struct {
+		x, y constraint
+	}
+)
+
+func (c *tagConstraint) eval(tags map[string]bool) bool { return tags[c.tag] }
+func (c *notConstraint) eval(tags map[string]bool) bool { return !c.x.eval(tags) }
+func (c *andConstraint) eval(tags map[string]bool) bool { return c.x.eval(tags) && c.y.eval(tags) }
+func (c *orConstraint) eval(tags map[string]bool) bool  { return c.x.eval(tags) || c.y.eval(tags) }
+
+func (c *tagConstraint) String() string { return c.tag }
+
+func (c *notConstraint) String() string {
+	switch c.x.(type) {
+	case *tagConstraint, *notConstraint:
+		return "!" + c.x.String()
+	}
+	return "!(" + c.x.String() + ")"
+}
+
+func (c *andConstraint) String() string {
+	return andOperand(c.x) + " && " + andOperand(c.y)
+}
+
+func (c *orConstraint) String() string {
+	return c.x.String() + " || " + c.y.String()
+}
+
+// andOperand returns x as an operand of &&, parenthesized if necessary.
+func andOperand(x constraint) string {
+	if _, ok := x.(*orConstraint); ok {
+		return "(" + x.String() + ")"
+	}
+	return x.String()
+}
+
+// goVersion reports whether c is a Go release tag of the form go1.N,
+// such as go1.10, and if so, returns the minor version N. Release
+// tags are satisfied by all releases since Go 1.N.
+func (c *tagConstraint) goVersion() (minor int, ok bool) {
+	const prefix = "go1."
+	if !strings.HasPrefix(c.tag, prefix) || len(c.tag) == len(prefix) {
+		return 0, false
+	}
+	for _, d := range c.tag[len(prefix):] {
+		if d < '0' || d > '9' {
+			return 0, false
+		}
+		minor = minor*10 + int(d-'0')
+	}
+	return minor, true
+}
+
+// parseConstraint parses the //go:build expression x.
+//
+//	Expr = AndExpr { "||" AndExpr } .
+//	AndExpr = UnaryExpr { "&&" UnaryExpr } .
+//	UnaryExpr = "!" UnaryExpr | "(" Expr ")" | tag .
+func parseConstraint(x string) (constraint, error) {
+	p := constraintParser{s: x}
+	c := p.or()
+	if p.err == nil {
+		p.skipSpace()
+		if p.s != "" {
+			p.errorf("unexpected %q", p.s)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return c, nil
+}
+
+type constraintParser struct {
+	s   string // remaining input
+	err error  // first error encountered
+}
+
+func (p *constraintParser) errorf(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+	p.s = "" // stop parsing
+}
+
+func (p *constraintParser) skipSpace() {
+	p.s = strings.TrimLeft(p.s, " \t")
+}
+
+// got reports whether the input continues with op, and if so, consumes it.
+func (p *constraintParser) got(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s, op) {
+		p.s = p.s[len(op):]
+		return true
+	}
+	return false
+}
+
+func (p *constraintParser) or() constraint {
+	x := p.and()
+	for p.got("||") {
+		x = &orConstraint{x, p.and()}
+	}
+	return x
+}
+
+func (p *constraintParser) and() constraint {
+	x := p.unary()
+	for p.got("&&") {
+		x = &andConstraint{x, p.unary()}
+	}
+	return x
+}
+
+func (p *constraintParser) unary() constraint {
+	switch {
+	case p.got("!"):
+		return &notConstraint{p.unary()}
+	case p.got("("):
+		x := p.or()
+		if !p.got(")") {
+			p.errorf("missing )")
+		}
+		return x
+	}
+
+	p.skipSpace()
+	i := 0
+	for i < len(p.s) && isTagChar(p.s[i]) {
+		i++
+	}
+	if i == 0 {
+		if p.s == "" {
+			p.errorf("unexpected end of expression")
+		} else {
+			p.errorf("unexpected %q", p.s)
+		}
+		return &tagConstraint{} // placeholder; the result is discarded
+	}
+	tag := p.s[:i]
+	p.s = p.s[i:]
+	return &tagConstraint{tag}
+}
+
+func isTagChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.'
+}
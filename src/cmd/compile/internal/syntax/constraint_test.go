@@ -0,0 +1,141 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConstraint(t *testing.T) {
+	for _, test := range []struct {
+		expr, want string
+	}{
+		{"linux", "linux"},
+		{" go1.10 ", "go1.10"},
+		{"go1.22 && linux", "go1.22 && linux"},
+		{"!cgo", "!cgo"},
+		{"!!cgo", "!!cgo"},
+		{"!(a || b)", "!(a || b)"},
+		{"!(a && b)", "!(a && b)"},
+		{"a || b && c", "a || b && c"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"a && (b || c)", "a && (b || c)"},
+		{"((a))", "a"},
+		{"a||b||c", "a || b || c"},
+	} {
+		x, err := parseConstraint(test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got := x.String(); got != test.want {
+			t.Errorf("%q: got %s; want %s", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestConstraintTree(t *testing.T) {
+	x, err := parseConstraint(" go1.22 && linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	and, ok := x.(*andConstraint)
+	if !ok {
+		t.Fatalf("got %T; want *andConstraint", x)
+	}
+
+	version, ok := and.x.(*tagConstraint)
+	if !ok {
+		t.Fatalf("left operand: got %T; want *tagConstraint", and.x)
+	}
+	if minor, ok := version.goVersion(); !ok || minor != 22 {
+		t.Errorf("%s: goVersion() = %d, %v; want 22, true", version, minor, ok)
+	}
+
+	os, ok := and.y.(*tagConstraint)
+	if !ok {
+		t.Fatalf("right operand: got %T; want *tagConstraint", and.y)
+	}
+	if os.tag != "linux" {
+		t.Errorf("got tag %s; want linux", os.tag)
+	}
+	if _, ok := os.goVersion(); ok {
+		t.Errorf("%s: goVersion() reports a release tag", os)
+	}
+}
+
+func TestConstraintGoVersion(t *testing.T) {
+	for _, test := range []struct {
+		tag   string
+		minor int
+		ok    bool
+	}{
+		{"go1.1", 1, true},
+		{"go1.10", 10, true},
+		{"go1.22", 22, true},
+		{"go1.", 0, false},
+		{"go1.x", 0, false},
+		{"go1.2.3", 0, false},
+		{"go2.0", 0, false},
+		{"go1", 0, false},
+		{"gccgo", 0, false},
+	} {
+		minor, ok := (&tagConstraint{test.tag}).goVersion()
+		if minor != test.minor || ok != test.ok {
+			t.Errorf("%s: got %d, %v; want %d, %v", test.tag, minor, ok, test.minor, test.ok)
+		}
+	}
+}
+
+func TestConstraintEval(t *testing.T) {
+	tags := map[string]bool{"linux": true, "amd64": true, "go1.9": true, "go1.10": true}
+	for _, test := range []struct {
+		expr string
+		want bool
+	}{
+		{"linux", true},
+		{"darwin", false},
+		{"go1.10 && linux", true},
+		{"go1.22 && linux", false},
+		{"!cgo", true},
+		{"linux && !amd64", false},
+		{"darwin || amd64", true},
+		{"(darwin || windows) && amd64", false},
+		{"darwin || windows && amd64", false},
+		{"!(darwin || windows)", true},
+	} {
+		x, err := parseConstraint(test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got := x.eval(tags); got != test.want {
+			t.Errorf("%q: got %v; want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestConstraintErrors(t *testing.T) {
+	for _, test := range []struct {
+		expr, err string
+	}{
+		{"", "unexpected end of expression"},
+		{"linux &&", "unexpected end of expression"},
+		{"(linux", "missing )"},
+		{"linux)", `unexpected ")"`},
+		{"linux darwin", `unexpected "darwin"`},
+		{"linux & darwin", `unexpected "& darwin"`},
+		{"!", "unexpected end of expression"},
+	} {
+		x, err := parseConstraint(test.expr)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got error %v; want %s", test.expr, err, test.err)
+		}
+		if x != nil {
+			t.Errorf("%q: got constraint %s with error", test.expr, x)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"bytes"
+	"cmd/internal/src"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrExcluded is returned by ParseDeps if the file's build
+// constraint is not satisfied.
+var ErrExcluded = errors.New("build constraints exclude file")
+
+// ParseDeps parses the imports of the Go source file src and returns
+// their import paths, in source order. If the file has a //go:build
+// line before the package clause and its expression is not satisfied
+// by tags, ParseDeps returns an empty list and ErrExcluded. A tag is
+// satisfied if tags[tag] is true. The filename is only used for
+// position information.
+func ParseDeps(filename string, src_ []byte, tags map[string]bool) ([]string, error) {
+	f, err := ParseBytes(src.NewFileBase(filename, filename), src_, nil, nil, nil, ImportsOnly|ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgLine := f.Pos().Line()
+	var expr string
+	var exprPos src.Pos
+	for _, c := range f.Comments {
+		if c.Pos.Line() >= pkgLine {
+			break
+		}
+		if c.Pos.Col() != colbase || !strings.HasPrefix(c.Text, "//go:build") {
+			continue
+		}
+		text := c.Text[len("//go:build"):]
+		if text != "" && text[0] != ' ' && text[0] != '\t' {
+			continue // some other directive, such as //go:buildx
+		}
+		if exprPos.IsKnown() {
+			return nil, Error{c.Pos, "multiple //go:build lines"}
+		}
+		expr, exprPos = text, c.Pos
+	}
+
+	if exprPos.IsKnown() {
+		x, err := parseConstraint(expr)
+		if err != nil {
+			return nil, Error{exprPos, "invalid //go:build line: " + err.Error()}
+		}
+		if !x.eval(tags) {
+			return []string{}, ErrExcluded
+		}
+	}
+
+	list := []string{}
+	for _, decl := range f.DeclList {
+		d := decl.(*ImportDecl) // ImportsOnly mode
+		if path, err := strconv.Unquote(d.Path.Value); err == nil {
+			list = append(list, path)
+		}
+	}
+	return list, nil
+}
+
+// SplitHeader returns the offset in src_ at which the header of the
+// Go source file src_ ends. The header consists of the package clause
+// and the import declarations following it, including any comments
+// before them. headerEnd is the offset immediately after the last
+// token of the header: the package name, or the closing parenthesis
+// or import path of the last import declaration. Comments and white
+// space after it, such as the rest of the line, belong to the body.
+// Only the header is scanned; the body is not checked.
+func SplitHeader(src_ []byte) (headerEnd int, err error) {
+	var s headerScanner
+	if err := s.scan(src_); err != nil {
+		return 0, err
+	}
+	return s.end, nil
+}
+
+// A Header describes the package clause and the import specs of a
+// Go source file (see ScanHeader). Positions have no base; only their
+// line and column are valid.
+type Header struct {
+	PkgPos  src.Pos // position of the package keyword
+	PkgName string
+	Imports []HeaderImport
+}
+
+// A HeaderImport describes an import spec of a Header.
+type HeaderImport struct {
+	Pos   src.Pos // position of the import spec
+	Alias string  // the package name, "_", "." or "" if there is none
+	Path  string  // unquoted import path
+}
+
+// ScanHeader scans the header of the Go source file src_ (see
+// SplitHeader) and describes the package clause and each import spec,
+// in source order. Like SplitHeader, it stops after the last import
+// declaration; the rest of the file is not checked.
+func ScanHeader(src_ []byte) (Header, error) {
+	var s headerScanner
+	if err := s.scan(src_); err != nil {
+		return Header{}, err
+	}
+	return s.h, nil
+}
+
+// A headerScanner scans the header of a Go source file for SplitHeader
+// and ScanHeader. It stops at the first error.
+type headerScanner struct {
+	scanner
+	h   Header
+	end int   // offset immediately after the last token of the header
+	err error // first error encountered
+}
+
+// scan scans the header of src_.
+func (s *headerScanner) scan(src_ []byte) error {
+	s.init(bytes.NewReader(src_), func(line, col uint, msg string) {
+		if s.err == nil {
+			s.err = Error{src.MakePos(nil, line, col), msg}
+		}
+	}, nil, packageFirst)
+
+	// PackageClause ";"
+	s.next()
+	s.h.PkgPos = s.pos()
+	s.want(_Package, "package clause")
+	s.next()
+	s.want(_Name, "package clause")
+	s.h.PkgName = s.lit
+	s.end = s.offset()
+	s.next()
+	s.want(_Semi, "package clause")
+
+	// { ImportDecl ";" }
+	for s.next(); s.err == nil && s.tok == _Import; s.next() {
+		s.next()
+		if s.tok == _Lparen {
+			// "(" { ImportSpec ";" } ")"
+			s.next()
+			for s.err == nil && s.tok != _Rparen {
+				s.importSpec()
+				s.next()
+				if s.tok == _Semi {
+					s.next()
+				} else {
+					s.want(_Rparen, "import declaration")
+				}
+			}
+		} else {
+			s.importSpec()
+		}
+		s.end = s.offset()
+		s.next()
+		s.want(_Semi, "import declaration")
+	}
+
+	return s.err
+}
+
+func (s *headerScanner) next() {
+	if s.err == nil {
+		s.scanner.next()
+	}
+}
+
+// pos returns the position of the current token.
+func (s *headerScanner) pos() src.Pos {
+	return src.MakePos(nil, s.line, s.col)
+}
+
+// want reports an error if the current token is not tok.
+func (s *headerScanner) want(tok token, context string) {
+	if s.err != nil || s.tok == tok {
+		return
+	}
+	found := s.tok.String()
+	if s.tok == _Name || s.tok == _Literal || s.tok == _Semi {
+		found = s.lit
+	}
+	s.err = Error{s.pos(), fmt.Sprintf("syntax error: unexpected %s in %s", found, context)}
+}
+
+// importSpec scans an import spec starting with the current token
+// and records it in s.h:
+//
+//	ImportSpec = [ "." | PackageName ] ImportPath .
+//
+// The current token is the import path afterwards.
+func (s *headerScanner) importSpec() {
+	if s.err != nil {
+		return
+	}
+	spec := HeaderImport{Pos: s.pos()}
+	switch s.tok {
+	case _Name:
+		spec.Alias = s.lit
+		s.next()
+	case _Dot:
+		spec.Alias = "."
+		s.next()
+	}
+	s.want(_Literal, "import declaration")
+	if s.err != nil {
+		return
+	}
+	path, err := strconv.Unquote(s.lit)
+	if s.kind != StringLit || err != nil {
+		s.err = Error{s.pos(), "import path must be a string"}
+		return
+	}
+	spec.Path = path
+	s.h.Imports = append(s.h.Imports, spec)
+}
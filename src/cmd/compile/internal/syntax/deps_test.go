@@ -0,0 +1,185 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseDeps(t *testing.T) {
+	const src = `// Copyright notice.
+
+//go:build (linux || darwin) && !cgo
+
+// Package p does things.
+package p
+
+import "fmt"
+import (
+	"os"
+	_ "unsafe"
+)
+
+func f() { fmt.Println(os.Args) }
+`
+
+	for _, test := range []struct {
+		tags map[string]bool
+		want string
+		err  error
+	}{
+		{map[string]bool{"linux": true}, "[fmt os unsafe]", nil},
+		{map[string]bool{"darwin": true, "race": true}, "[fmt os unsafe]", nil},
+		{map[string]bool{"linux": true, "cgo": true}, "[]", ErrExcluded},
+		{map[string]bool{"windows": true}, "[]", ErrExcluded},
+		{nil, "[]", ErrExcluded},
+	} {
+		deps, err := ParseDeps("p.go", []byte(src), test.tags)
+		if err != test.err {
+			t.Errorf("%v: got error %v; want %v", test.tags, err, test.err)
+		}
+		if got := fmt.Sprint(deps); got != test.want {
+			t.Errorf("%v: got %s; want %s", test.tags, got, test.want)
+		}
+	}
+
+	// without constraint
+	deps, err := ParseDeps("q.go", []byte(`package q; import ("a"; "b/c")`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(deps); got != "[a b/c]" {
+		t.Errorf("got %s; want [a b/c]", got)
+	}
+}
+
+func TestParseDepsErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"//go:build linux &&\npackage p", "p.go:1:1: invalid //go:build line: unexpected end of expression"},
+		{"//go:build (a || b\npackage p", "p.go:1:1: invalid //go:build line: missing )"},
+		{"//go:build a b\npackage p", `p.go:1:1: invalid //go:build line: unexpected "b"`},
+		{"//go:build a\n//go:build b\npackage p", "p.go:2:1: multiple //go:build lines"},
+		{"package p; import fmt", "p.go:1:22: syntax error: missing import path"},
+	} {
+		_, err := ParseDeps("p.go", []byte(test.src), nil)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+
+	// not a //go:build line
+	for _, src := range []string{
+		"//go:buildx a\npackage p",
+		"package p //go:build a",
+		"package p\n//go:build a",
+	} {
+		if _, err := ParseDeps("p.go", []byte(src), nil); err != nil {
+			t.Errorf("%q: got error %v", src, err)
+		}
+	}
+}
+
+func TestSplitHeader(t *testing.T) {
+	for _, test := range []struct {
+		src, header string
+	}{
+		{"package p", "package p"},
+		{"package p\n\nfunc f() {}\n", "package p"},
+		{"package p // comment\nvar x int\n", "package p"},
+		{"// Package p does things.\npackage p\n\ntype T int\n", "// Package p does things.\npackage p"},
+		{"package p\n\nimport \"fmt\"\n\nfunc main() {}\n", "package p\n\nimport \"fmt\""},
+		{"/* doc */ package p; import x \"os\" // comment\nconst c = 0\n", "/* doc */ package p; import x \"os\""},
+		{
+			"// Copyright notice.\n\n// Package p does things.\npackage p\n\nimport (\n\t\"fmt\"\n\t. \"io\"\n\t_ `unsafe`\n)\n\nimport \"os\"\n\nvar _ = fmt.Println\n",
+			"// Copyright notice.\n\n// Package p does things.\npackage p\n\nimport (\n\t\"fmt\"\n\t. \"io\"\n\t_ `unsafe`\n)\n\nimport \"os\"",
+		},
+		{"package p; import (); import (\"a\"; \"b\";)", "package p; import (); import (\"a\"; \"b\";)"},
+		{"package p\nimport \"a\"\n// not an import\nfunc f() { import }", "package p\nimport \"a\""},
+	} {
+		n, err := SplitHeader([]byte(test.src))
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := test.src[:n]; got != test.header {
+			t.Errorf("%q: got header %q; want %q", test.src, got, test.header)
+		}
+	}
+}
+
+func TestSplitHeaderErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"", ":1:1: expected 'package', found EOF"},
+		{"func f()", ":1:1: expected 'package', found func"},
+		{"package 1", ":1:9: syntax error: unexpected 1 in package clause"},
+		{"package p q", ":1:11: syntax error: unexpected q in package clause"},
+		{"package p\nimport fmt", ":2:11: syntax error: unexpected EOF in import declaration"},
+		{"package p\nimport (\"a\" \"b\")", ":2:13: syntax error: unexpected \"b\" in import declaration"},
+		{"package p\nimport (\n\t\"a\"\n", ":4:1: syntax error: unexpected EOF in import declaration"},
+		{"package p\nimport \"a", ":2:8: string not terminated"},
+	} {
+		_, err := SplitHeader([]byte(test.src))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestScanHeader(t *testing.T) {
+	for _, test := range []struct {
+		src     string
+		pkg     string   // package name and position
+		imports []string // alias, path, and position of each import spec
+	}{
+		{"package p\n\nfunc f() {}\n", "p 1:1", nil},
+		{"// Package p does things.\n\n/* doc */ package p; const c = 0", "p 3:11", nil},
+		{"package main\n\nimport \"fmt\"\n\nfunc main() {}\n", "main 1:1", []string{`"fmt" 3:8`}},
+		{
+			"package p\n\nimport (\n\t\"fmt\"\n\tstr \"strings\"\n\t_ `unsafe`\n\t. \"io\"\n)\n\nimport \"os\"\n\nvar _ = fmt.Println\n",
+			"p 1:1",
+			[]string{`"fmt" 4:2`, `str "strings" 5:2`, `_ "unsafe" 6:2`, `. "io" 7:2`, `"os" 10:8`},
+		},
+		{"package p; import (); import (\"a\"; b \"b\";)", "p 1:1", []string{`"a" 1:31`, `b "b" 1:36`}},
+	} {
+		h, err := ScanHeader([]byte(test.src))
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if got := fmt.Sprintf("%s %d:%d", h.PkgName, h.PkgPos.Line(), h.PkgPos.Col()); got != test.pkg {
+			t.Errorf("%q: got package %s; want %s", test.src, got, test.pkg)
+		}
+		var imports []string
+		for _, spec := range h.Imports {
+			imp := fmt.Sprintf("%q %d:%d", spec.Path, spec.Pos.Line(), spec.Pos.Col())
+			if spec.Alias != "" {
+				imp = spec.Alias + " " + imp
+			}
+			imports = append(imports, imp)
+		}
+		if strings.Join(imports, "; ") != strings.Join(test.imports, "; ") {
+			t.Errorf("%q: got imports %q; want %q", test.src, imports, test.imports)
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"package p\nimport 1", ":2:8: import path must be a string"},
+		{"package p\nimport x 'a'", ":2:10: import path must be a string"},
+		{"package p\nimport (\"a\" \"b\")", ":2:13: syntax error: unexpected \"b\" in import declaration"},
+	} {
+		_, err := ScanHeader([]byte(test.src))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
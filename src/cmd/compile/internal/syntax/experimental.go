@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the registry for experimental syntax.
+//
+// An experimental statement or expression is introduced by an
+// identifier (for instance "try") which acts like a keyword if the
+// parser runs in Experimental mode. The corresponding production is
+// implemented by a function which is registered (typically in an init
+// function of a separate file) with registerExperimentalStmt or
+// registerExperimentalExpr. The function is called with the parser
+// positioned at the introducing identifier and must consume it.
+//
+// If the Experimental mode is not set, the registered productions are
+// never reached and the identifiers are ordinary names.
+
+package syntax
+
+var (
+	experimentalStmts = make(map[string]func(*parser) Stmt)
+	experimentalExprs = make(map[string]func(*parser) Expr)
+)
+
+// registerExperimentalStmt registers parse as the production for
+// statements introduced by the identifier name.
+func registerExperimentalStmt(name string, parse func(*parser) Stmt) {
+	if experimentalStmts[name] != nil {
+		panic("experimental statement " + name + " already registered")
+	}
+	experimentalStmts[name] = parse
+}
+
+// registerExperimentalExpr registers parse as the production for
+// expressions introduced by the identifier name.
+func registerExperimentalExpr(name string, parse func(*parser) Expr) {
+	if experimentalExprs[name] != nil {
+		panic("experimental expression " + name + " already registered")
+	}
+	experimentalExprs[name] = parse
+}
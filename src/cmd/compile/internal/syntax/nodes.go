@@ -4,7 +4,10 @@
 
 package syntax
 
-import "cmd/internal/src"
+import (
+	"cmd/internal/src"
+	"strconv"
+)
 
 // ----------------------------------------------------------------------------
 // Nodes
@@ -36,9 +39,11 @@ func (*node) aNode()         {}
 
 // package PkgName; DeclList[0], DeclList[1], ...
 type File struct {
-	PkgName  *Name
-	DeclList []Decl
-	Lines    uint
+	PkgName    *Name
+	DeclList   []Decl
+	Lines      uint
+	Incomplete []Expr     // *SelectorExpr and *CallExpr nodes ending prematurely; only set in AllowIncomplete mode
+	Comments   []*Comment // all comments in source order; only set in ParseComments mode
 	node
 }
 
@@ -72,12 +77,14 @@ type (
 	}
 
 	// Name Type
+	// Name TParamList Type
 	TypeDecl struct {
-		Name   *Name
-		Alias  bool
-		Type   Expr
-		Group  *Group // nil means not part of a group
-		Pragma Pragma
+		Name       *Name
+		TParamList []*Field // nil means no type parameters (AllowGenerics mode only)
+		Alias      bool
+		Type       Expr
+		Group      *Group // nil means not part of a group
+		Pragma     Pragma
 		decl
 	}
 
@@ -92,17 +99,18 @@ type (
 		decl
 	}
 
-	// func          Name Type { Body }
-	// func          Name Type
+	// func          Name TParamList Type { Body }
+	// func          Name TParamList Type
 	// func Receiver Name Type { Body }
 	// func Receiver Name Type
 	FuncDecl struct {
-		Attr   map[string]bool // go:attr map
-		Recv   *Field          // nil means regular function
-		Name   *Name
-		Type   *FuncType
-		Body   *BlockStmt // nil means no body (forward declaration)
-		Pragma Pragma     // TODO(mdempsky): Cleaner solution.
+		Attr       map[string]bool // go:attr map
+		Recv       *Field          // nil means regular function
+		Name       *Name
+		TParamList []*Field // nil means no type parameters (AllowGenerics mode only)
+		Type       *FuncType
+		Body       *BlockStmt // nil means no body (forward declaration)
+		Pragma     Pragma     // TODO(mdempsky): Cleaner solution.
 		decl
 	}
 )
@@ -256,14 +264,14 @@ type (
 	// Name Type
 	//      Type
 	Field struct {
-		Name *Name // nil means anonymous field/parameter (structs/parameters), or embedded interface (interfaces)
+		Name *Name // nil means anonymous field/parameter (structs/parameters), or embedded interface or type element (interfaces)
 		Type Expr  // field names declared in a list share the same Type (identical pointers)
 		node
 	}
 
 	// interface { MethodList[0]; MethodList[1]; ... }
 	InterfaceType struct {
-		MethodList []*Field
+		MethodList []*Field // methods have a name; embedded interfaces and type elements (AllowGenerics mode only) don't
 		expr
 	}
 
@@ -294,6 +302,19 @@ type expr struct{ node }
 
 func (*expr) aExpr() {}
 
+// Tag returns the unquoted tag of field i of struct type t,
+// or "" if the field has no tag. The tag literal is TagList[i].
+func (t *StructType) Tag(i int) string {
+	if i >= len(t.TagList) || t.TagList[i] == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(t.TagList[i].Value)
+	if err != nil {
+		return "" // invalid tag literal (already reported by the scanner)
+	}
+	return tag
+}
+
 type ChanDir uint
 
 const (
@@ -465,4 +486,5 @@ type Comment struct {
 	Kind CommentKind
 	Text string
 	Next *Comment
+	Pos  src.Pos // position of the comment start
 }
@@ -20,6 +20,7 @@ type parser struct {
 	errh  ErrorHandler
 	fileh FilenameHandler
 	mode  Mode
+	progh ProgressHandler
 	scanner
 
 	first  error  // first error encountered
@@ -28,14 +29,28 @@ type parser struct {
 
 	fnest  int    // function nesting level (for error handling)
 	xnest  int    // expression nesting level (for complit ambiguity resolution)
+	recv   bool   // parsing a method receiver (AllowGenerics mode only)
 	indent []byte // tracing support
+
+	incomplete []Expr             // incomplete expressions (AllowIncomplete mode only)
+	comments   []*Comment         // comments (ParseComments mode only)
+	guards     []*TypeSwitchGuard // type switch guards in the current statement header (see header)
 }
 
 func (p *parser) init(base *src.PosBase, r io.Reader, errh ErrorHandler, pragh PragmaHandler, fileh FilenameHandler, mode Mode) {
 	p.base = base
 	p.errh = errh
+	if errh == nil && mode&AllowIncomplete != 0 {
+		p.errh = func(error) {} // never stop early; the first error is still recorded
+	}
 	p.fileh = fileh
 	p.mode = mode
+	p.progh = nil
+
+	var smode scanMode
+	if mode&AllowGenerics != 0 {
+		smode = tildeOp
+	}
 	p.scanner.init(
 		r,
 		// Error and pragma handlers for scanner.
@@ -55,7 +70,13 @@ func (p *parser) init(base *src.PosBase, r io.Reader, errh ErrorHandler, pragh P
 				p.pragma |= pragh(p.pos_at(line, col), text)
 			}
 		},
+		smode,
 	)
+	if mode&ParseComments != 0 {
+		p.commh = func(line, col uint, text string) {
+			p.comments = append(p.comments, &Comment{Text: text, Pos: p.pos_at(line, col)})
+		}
+	}
 
 	p.first = nil
 	p.errcnt = 0
@@ -63,7 +84,12 @@ func (p *parser) init(base *src.PosBase, r io.Reader, errh ErrorHandler, pragh P
 
 	p.fnest = 0
 	p.xnest = 0
+	p.recv = false
 	p.indent = nil
+
+	p.incomplete = nil
+	p.comments = nil
+	p.guards = nil
 }
 
 const lineMax = 1<<24 - 1 // TODO(gri) this limit is defined for src.Pos - fix
@@ -130,7 +156,7 @@ func (p *parser) syntax_error_at(pos src.Pos, msg string) {
 		p.print("syntax error: " + msg)
 	}
 
-	if p.tok == _EOF && p.first != nil {
+	if p.tok == _EOF && p.first != nil && p.mode&AllErrors == 0 {
 		return // avoid meaningless follow-up errors
 	}
 
@@ -240,6 +266,16 @@ func (p *parser) advance(followlist ...token) {
 	}
 }
 
+// atStmtEnd reports whether the current token ends the current
+// statement or starts a new one.
+func (p *parser) atStmtEnd() bool {
+	switch p.tok {
+	case _Semi, _Rparen, _Rbrack, _Rbrace, _EOF:
+		return true
+	}
+	return p.fnest > 0 && contains(stopset, p.tok)
+}
+
 // usage: defer p.trace(msg)()
 func (p *parser) trace(msg string) func() {
 	p.print(msg + " (")
@@ -296,7 +332,15 @@ func (p *parser) fileOrNil() *File {
 		p.want(_Semi)
 	}
 
+	if p.mode&ImportsOnly != 0 {
+		// the rest of the file is not scanned
+		f.Lines = p.source.line
+		f.Comments = p.comments
+		return f
+	}
+
 	// { TopLevelDecl ";" }
+	ndecls := 0
 	for p.tok != _EOF {
 		switch p.tok {
 		case _Const:
@@ -332,6 +376,11 @@ func (p *parser) fileOrNil() *File {
 		// since comments before may set pragmas for the next function decl.
 		p.pragma = 0
 
+		if p.progh != nil {
+			ndecls++
+			p.progh(ndecls, p.line)
+		}
+
 		if p.tok != _EOF && !p.got(_Semi) {
 			p.syntax_error("after top level declaration")
 			p.advance(_Const, _Type, _Var, _Func)
@@ -340,6 +389,12 @@ func (p *parser) fileOrNil() *File {
 	// p.tok == _EOF
 
 	f.Lines = p.source.line
+	f.Incomplete = p.incomplete
+	f.Comments = p.comments
+
+	if p.mode&DeclarationErrors != 0 {
+		p.checkDecls(f.DeclList)
+	}
 
 	return f
 }
@@ -372,7 +427,12 @@ func (p *parser) list(open, sep, close token, f func() bool) src.Pos {
 		// sep is optional before close
 		if !p.got(sep) && p.tok != close {
 			p.syntax_error(fmt.Sprintf("expecting %s or %s", tokstring(sep), tokstring(close)))
-			p.advance(_Rparen, _Rbrack, _Rbrace)
+			if p.mode&AllowIncomplete != 0 {
+				// don't skip past the end of the current statement
+				p.advance(_Rparen, _Rbrack, _Rbrace, _Semi)
+			} else {
+				p.advance(_Rparen, _Rbrack, _Rbrace)
+			}
 			if p.tok != close {
 				// position could be better but we had an error so we don't care
 				return p.pos()
@@ -467,8 +527,13 @@ func (p *parser) typeDecl(group *Group) Decl {
 	d.pos = p.pos()
 
 	d.Name = p.name()
-	d.Alias = p.got(_Assign)
-	d.Type = p.typeOrNil()
+	if p.mode&AllowGenerics != 0 && p.tok == _Lbrack {
+		d.TParamList, d.Type = p.tparamsOrArray()
+	}
+	if d.Type == nil {
+		d.Alias = p.got(_Assign)
+		d.Type = p.typeOrNil()
+	}
 	if d.Type == nil {
 		d.Type = p.bad()
 		p.syntax_error("in type declaration")
@@ -480,6 +545,214 @@ func (p *parser) typeDecl(group *Group) Decl {
 	return d
 }
 
+// tparamsOrArray parses the "[" following the name in a type
+// declaration and resolves the ambiguity between a type parameter
+// list (type T[P C] ...) and an array or slice type (type T [N]E).
+// It returns either the type parameters or the type.
+func (p *parser) tparamsOrArray() ([]*Field, Expr) {
+	if trace {
+		defer p.trace("tparamsOrArray")()
+	}
+
+	pos := p.pos()
+	p.want(_Lbrack)
+	if p.tok != _Name {
+		return nil, p.sliceOrArrayType(pos, nil)
+	}
+
+	name := p.name()
+	switch p.tok {
+	case _Comma, _Name, _Func, _Chan, _Map, _Struct, _Interface:
+		// name is followed by another type parameter name or by a
+		// constraint, which can't be the case in an array length
+		// (but note that "[N *M]" is an array length)
+		return p.typeParams(name, nil), nil
+	case _Operator:
+		if p.op == Tilde {
+			return p.typeParams(name, nil), nil
+		}
+	case _Lbrack:
+		// name "[" starts an array or slice type constraint
+		// (type T[P [N]E] ...) or an index expression (type T [a[i]]E)
+		typ, x := p.arrayConstraintOrIndex(name)
+		if typ != nil {
+			return p.typeParams(name, p.union(typ)), nil
+		}
+		p.xnest++
+		x = p.binaryExpr(p.pexpr(x, false), 0)
+		p.xnest--
+		return nil, p.sliceOrArrayType(pos, x)
+	}
+
+	// the array length is an expression starting with name,
+	// unless it is a type parameter with a parenthesized
+	// constraint (type T[P (C)] ...)
+	p.xnest++
+	x := p.binaryExpr(p.pexpr(name, false), 0)
+	p.xnest--
+	if typ := extractConstraint(name, x, p.tok == _Comma); typ != nil {
+		return p.typeParams(name, typ), nil
+	}
+	return nil, p.sliceOrArrayType(pos, x)
+}
+
+// arrayConstraintOrIndex parses the "[" following name in a type
+// parameter list or array length. It returns the array or slice type
+// if name is followed by one (the constraint of the type parameter
+// name), or else the index expression name[i].
+func (p *parser) arrayConstraintOrIndex(name *Name) (typ, x Expr) {
+	pos := p.pos()
+	p.want(_Lbrack)
+	if p.tok == _Rbrack {
+		// an index can't be empty: name []E
+		return p.sliceOrArrayType(pos, nil), nil
+	}
+
+	p.xnest++
+	i := p.expr()
+	p.want(_Rbrack)
+	p.xnest--
+	switch p.tok {
+	case _Name, _Func, _Chan, _Map, _Struct, _Interface, _Arrow:
+		// an element type follows: name [i]E
+		// (but note that [a[i] *M], [a[i][j]] and [a[i](x)]
+		// are array lengths)
+		t := new(ArrayType)
+		t.pos = pos
+		t.Len = i
+		t.Elem = p.type_()
+		return t, nil
+	}
+	t := new(IndexExpr)
+	t.pos = pos
+	t.X = name
+	t.Index = i
+	return nil, t
+}
+
+// extractConstraint returns the constraint C if the expression x
+// starting with name has the form name (C), or C | D if it has the
+// form name (C) | D, provided that the constraint contains a type
+// element that can't be an ordinary expression or force is set (x is
+// followed by a comma). Otherwise x is an array length; the result
+// is nil.
+func extractConstraint(name *Name, x Expr, force bool) Expr {
+	switch x := x.(type) {
+	case *CallExpr:
+		if x.Fun == name && len(x.ArgList) == 1 && !x.HasDots && (force || isTypeElem(x.ArgList[0])) {
+			// like the parentheses of a type, the parentheses
+			// of the constraint are not recorded
+			return x.ArgList[0]
+		}
+	case *Operation:
+		if x.Op == Or && x.Y != nil {
+			if lhs := extractConstraint(name, x.X, force || isTypeElem(x.Y)); lhs != nil {
+				t := *x
+				t.X = lhs
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// isTypeElem reports whether x is (or contains) a type which can't be
+// an ordinary expression.
+func isTypeElem(x Expr) bool {
+	switch x := x.(type) {
+	case *ArrayType, *StructType, *FuncType, *InterfaceType, *SliceType, *MapType, *ChanType:
+		return true
+	case *Operation:
+		return isTypeElem(x.X) || x.Y != nil && isTypeElem(x.Y) || x.Op == Tilde
+	case *ParenExpr:
+		return isTypeElem(x.X)
+	}
+	return false
+}
+
+// TypeParams = "[" TypeParamDecl { "," TypeParamDecl } [ "," ] "]" .
+// TypeParamDecl = IdentifierList Constraint .
+// Constraint = Term { "|" Term } .
+// Term = [ "~" ] Type .
+//
+// The opening "[" and the first type parameter name have been consumed
+// already, as well as its constraint typ if not nil. Type parameters
+// declared together share the same constraint (Field.Type), like the
+// parameters of a parameter list.
+func (p *parser) typeParams(name *Name, typ Expr) []*Field {
+	if trace {
+		defer p.trace("typeParams")()
+	}
+
+	p.xnest++
+	var list []*Field
+	names := []*Name{name}
+	for {
+		if typ == nil && p.got(_Comma) {
+			// another type parameter with the same constraint
+			names = append(names, p.name())
+			continue
+		}
+		if typ == nil {
+			if p.tok == _Rbrack || p.tok == _EOF {
+				p.syntax_error("missing type constraint")
+				break
+			}
+			typ = p.constraint()
+		}
+		for _, name := range names {
+			f := new(Field)
+			f.pos = name.Pos()
+			f.Name = name
+			f.Type = typ
+			list = append(list, f)
+		}
+		if !p.got(_Comma) || p.tok == _Rbrack {
+			break
+		}
+		names = []*Name{p.name()}
+		typ = nil
+	}
+	p.want(_Rbrack)
+	p.xnest--
+
+	return list
+}
+
+// constraint parses a type constraint. A union of terms is represented
+// as a (left-associative) Operation with the Or operator, and a ~
+// term as a unary Operation with the Tilde operator.
+func (p *parser) constraint() Expr {
+	return p.union(p.term())
+}
+
+// union parses the remaining terms of a union whose first term x
+// has been consumed already.
+func (p *parser) union(x Expr) Expr {
+	for p.tok == _Operator && p.op == Or {
+		t := new(Operation)
+		t.pos = p.pos()
+		t.Op = Or
+		p.next()
+		t.X = x
+		t.Y = p.term()
+		x = t
+	}
+	return x
+}
+
+func (p *parser) term() Expr {
+	if p.tok == _Operator && p.op == Tilde {
+		t := new(Operation)
+		t.pos = p.pos()
+		t.Op = Tilde
+		p.next()
+		t.X = p.type_()
+		return t
+	}
+	return p.type_()
+}
+
 // VarSpec = IdentifierList ( Type [ "=" ExpressionList ] | "=" ExpressionList ) .
 func (p *parser) varDecl(group *Group) Decl {
 	if trace {
@@ -517,7 +790,9 @@ func (p *parser) funcDeclOrNil() *FuncDecl {
 	f.pos = p.pos()
 
 	if p.tok == _Lparen {
+		p.recv = true
 		rcvr := p.paramList()
+		p.recv = false
 		switch len(rcvr) {
 		case 0:
 			p.error("method has no receiver")
@@ -527,6 +802,9 @@ func (p *parser) funcDeclOrNil() *FuncDecl {
 		case 1:
 			f.Recv = rcvr[0]
 		}
+		if f.Recv != nil && p.mode&AllowGenerics != 0 {
+			p.checkRecvTParams(f.Recv.Type)
+		}
 	}
 
 	if p.tok != _Name {
@@ -536,9 +814,20 @@ func (p *parser) funcDeclOrNil() *FuncDecl {
 	}
 
 	f.Name = p.name()
+	if p.mode&AllowGenerics != 0 && p.tok == _Lbrack {
+		if f.Recv != nil {
+			p.error("methods cannot have type parameters")
+		}
+		p.next()
+		f.TParamList = p.typeParams(p.name(), nil)
+	}
 	f.Type = p.funcType()
 	if p.tok == _Lbrace {
-		f.Body = p.funcBody()
+		if p.mode&SkipFuncBodies != 0 {
+			f.Body = p.skipFuncBody()
+		} else {
+			f.Body = p.funcBody()
+		}
 	}
 	f.Pragma = p.pragma
 
@@ -555,12 +844,77 @@ func (p *parser) funcBody() *BlockStmt {
 	// as it may lead to spurious errors (e.g., see test/switch2.go) or
 	// possibly crashes due to incomplete syntax trees.
 	if p.mode&CheckBranches != 0 && errcnt == p.errcnt {
-		checkBranches(body, p.errh)
+		// Report branch errors via the parser so that they are
+		// recorded, and so that we don't call a nil p.errh.
+		checkBranches(body, func(err error) {
+			e := err.(Error)
+			p.error_at(e.Pos, e.Msg)
+		})
 	}
 
 	return body
 }
 
+// skipFuncBody skips a function body without parsing it
+// and returns an empty block in its place.
+func (p *parser) skipFuncBody() *BlockStmt {
+	b := new(BlockStmt)
+	b.pos = p.pos()
+	p.want(_Lbrace)
+	for n := 0; p.tok != _EOF && (p.tok != _Rbrace || n > 0); p.next() {
+		switch p.tok {
+		case _Lbrace:
+			n++
+		case _Rbrace:
+			n--
+		}
+	}
+	b.Rbrace = p.pos()
+	p.want(_Rbrace)
+	return b
+}
+
+// checkDecls reports package-level names which are declared
+// more than once in list. Methods are distinguished by their
+// receiver base type; init functions may be declared repeatedly.
+func (p *parser) checkDecls(list []Decl) {
+	seen := make(map[string]*Name)
+	declare := func(key string, name *Name) {
+		if name.Value == "_" {
+			return
+		}
+		if prev := seen[key]; prev != nil {
+			p.error_at(name.Pos(), fmt.Sprintf("%s redeclared in this block\n\tprevious declaration at %s", key, prev.Pos()))
+			return
+		}
+		seen[key] = name
+	}
+
+	for _, decl := range list {
+		switch d := decl.(type) {
+		case *ConstDecl:
+			for _, name := range d.NameList {
+				declare(name.Value, name)
+			}
+		case *VarDecl:
+			for _, name := range d.NameList {
+				declare(name.Value, name)
+			}
+		case *TypeDecl:
+			declare(d.Name.Value, d.Name)
+		case *FuncDecl:
+			switch {
+			case d.Recv != nil:
+				if recv := recvBaseName(d.Recv.Type); recv != "" {
+					declare(recv+"."+d.Name.Value, d.Name)
+				}
+			case d.Name.Value != "init":
+				declare(d.Name.Value, d.Name)
+			}
+		}
+	}
+}
+
 // ----------------------------------------------------------------------------
 // Expressions
 
@@ -569,14 +923,17 @@ func (p *parser) expr() Expr {
 		defer p.trace("expr")()
 	}
 
-	return p.binaryExpr(0)
+	return p.binaryExpr(nil, 0)
 }
 
 // Expression = UnaryExpr | Expression binary_op Expression .
-func (p *parser) binaryExpr(prec int) Expr {
+// If x is not nil, it is the (already parsed) first operand.
+func (p *parser) binaryExpr(x Expr, prec int) Expr {
 	// don't trace binaryExpr - only leads to overly nested trace output
 
-	x := p.unaryExpr()
+	if x == nil {
+		x = p.unaryExpr()
+	}
 	for (p.tok == _Operator || p.tok == _Star) && p.prec > prec {
 		t := new(Operation)
 		t.pos = p.pos()
@@ -584,7 +941,7 @@ func (p *parser) binaryExpr(prec int) Expr {
 		t.X = x
 		tprec := p.prec
 		p.next()
-		t.Y = p.binaryExpr(tprec)
+		t.Y = p.binaryExpr(nil, tprec)
 		x = t
 	}
 	return x
@@ -679,7 +1036,7 @@ func (p *parser) unaryExpr() Expr {
 	// TODO(mdempsky): We need parens here so we can report an
 	// error for "(x) := true". It should be possible to detect
 	// and reject that more efficiently though.
-	return p.pexpr(true)
+	return p.pexpr(nil, true)
 }
 
 // callStmt parses call-like statements that can be preceded by 'defer' and 'go'.
@@ -693,7 +1050,7 @@ func (p *parser) callStmt() *CallStmt {
 	s.Tok = p.tok // _Defer or _Go
 	p.next()
 
-	x := p.pexpr(p.tok == _Lparen) // keep_parens so we can report error below
+	x := p.pexpr(nil, p.tok == _Lparen) // keep_parens so we can report error below
 	if t := unparen(x); t != x {
 		p.error(fmt.Sprintf("expression in %s must not be parenthesized", s.Tok))
 		// already progressed, no need to advance
@@ -724,6 +1081,11 @@ func (p *parser) operand(keep_parens bool) Expr {
 
 	switch p.tok {
 	case _Name:
+		if p.mode&Experimental != 0 {
+			if parse := experimentalExprs[p.lit]; parse != nil {
+				return parse(p)
+			}
+		}
 		return p.name()
 
 	case _Literal:
@@ -789,6 +1151,9 @@ func (p *parser) operand(keep_parens bool) Expr {
 	default:
 		x := p.bad()
 		p.syntax_error("expecting expression")
+		if p.mode&AllowIncomplete != 0 && p.atStmtEnd() {
+			return x // don't skip past the end of the current statement
+		}
 		p.advance()
 		return x
 	}
@@ -815,12 +1180,14 @@ func (p *parser) operand(keep_parens bool) Expr {
 //                  "]" .
 // TypeAssertion  = "." "(" Type ")" .
 // Arguments      = "(" [ ( ExpressionList | Type [ "," ExpressionList ] ) [ "..." ] [ "," ] ] ")" .
-func (p *parser) pexpr(keep_parens bool) Expr {
+func (p *parser) pexpr(x Expr, keep_parens bool) Expr {
 	if trace {
 		defer p.trace("pexpr")()
 	}
 
-	x := p.operand(keep_parens)
+	if x == nil {
+		x = p.operand(keep_parens)
+	}
 
 loop:
 	for {
@@ -844,6 +1211,12 @@ loop:
 					t.pos = pos
 					t.X = x
 					x = t
+					if p.xnest >= 0 {
+						// not at the top level of a statement header
+						p.error_at(pos, "use of .(type) outside type switch")
+					} else {
+						p.guards = append(p.guards, t) // checked by header
+					}
 				} else {
 					t := new(AssertExpr)
 					t.pos = pos
@@ -855,6 +1228,18 @@ loop:
 
 			default:
 				p.syntax_error("expecting name or (")
+				if p.mode&AllowIncomplete != 0 {
+					// x. with missing selector; don't skip
+					// anything so we don't lose the rest of
+					// the enclosing block
+					t := new(SelectorExpr)
+					t.pos = pos
+					t.X = x
+					t.Sel = p.newName("_")
+					p.incomplete = append(p.incomplete, t)
+					x = t
+					break
+				}
 				p.advance(_Semi, _Rparen)
 			}
 
@@ -909,7 +1294,11 @@ loop:
 			t := new(CallExpr)
 			t.pos = pos
 			t.Fun = x
+			errcnt := p.errcnt
 			t.ArgList, t.HasDots = p.argList()
+			if p.mode&AllowIncomplete != 0 && p.errcnt > errcnt {
+				p.incomplete = append(p.incomplete, t)
+			}
 			x = t
 
 		case _Lbrace:
@@ -1056,26 +1445,7 @@ func (p *parser) typeOrNil() Expr {
 		// '[' oexpr ']' ntype
 		// '[' _DotDotDot ']' ntype
 		p.next()
-		p.xnest++
-		if p.got(_Rbrack) {
-			// []T
-			p.xnest--
-			t := new(SliceType)
-			t.pos = pos
-			t.Elem = p.type_()
-			return t
-		}
-
-		// [n]T
-		t := new(ArrayType)
-		t.pos = pos
-		if !p.got(_DotDotDot) {
-			t.Len = p.expr()
-		}
-		p.want(_Rbrack)
-		p.xnest--
-		t.Elem = p.type_()
-		return t
+		return p.sliceOrArrayType(pos, nil)
 
 	case _Chan:
 		// _Chan non_recvchantype
@@ -1107,7 +1477,14 @@ func (p *parser) typeOrNil() Expr {
 		return p.interfaceType()
 
 	case _Name:
-		return p.dotname(p.name())
+		t := p.dotname(p.name())
+		if p.mode&AllowGenerics != 0 && p.tok == _Lbrack {
+			// generic type instantiation
+			pos := p.pos()
+			p.next()
+			t = instance(pos, t, p.typeArgs())
+		}
+		return t
 
 	case _Lparen:
 		p.next()
@@ -1132,6 +1509,34 @@ func (p *parser) funcType() *FuncType {
 	return typ
 }
 
+// sliceOrArrayType parses a slice or array type; the opening "["
+// at pos has been consumed already. If length is not nil, it is the
+// array length, which has been parsed already as well.
+func (p *parser) sliceOrArrayType(pos src.Pos, length Expr) Expr {
+	p.xnest++
+	if length == nil && p.got(_Rbrack) {
+		// []T
+		p.xnest--
+		t := new(SliceType)
+		t.pos = pos
+		t.Elem = p.type_()
+		return t
+	}
+
+	// [n]T
+	t := new(ArrayType)
+	t.pos = pos
+	if length != nil {
+		t.Len = length
+	} else if !p.got(_DotDotDot) {
+		t.Len = p.expr()
+	}
+	p.want(_Rbrack)
+	p.xnest--
+	t.Elem = p.type_()
+	return t
+}
+
 func (p *parser) chanElem() Expr {
 	if trace {
 		defer p.trace("chanElem")()
@@ -1181,7 +1586,7 @@ func (p *parser) structType() *StructType {
 	return typ
 }
 
-// InterfaceType = "interface" "{" { MethodSpec ";" } "}" .
+// InterfaceType = "interface" "{" { ( MethodSpec | TypeElem ) ";" } "}" .
 func (p *parser) interfaceType() *InterfaceType {
 	if trace {
 		defer p.trace("interfaceType")()
@@ -1329,6 +1734,14 @@ func (p *parser) oliteral() *BasicLit {
 // MethodSpec        = MethodName Signature | InterfaceTypeName .
 // MethodName        = identifier .
 // InterfaceTypeName = TypeName .
+//
+// In AllowGenerics mode, an interface may also contain type elements:
+//
+// TypeElem = Constraint .
+//
+// Embedded interfaces and type elements are both represented as
+// fields without a name; the type of a type element is the constraint
+// expression (see constraint).
 func (p *parser) methodDecl() *Field {
 	if trace {
 		defer p.trace("methodDecl")()
@@ -1354,6 +1767,16 @@ func (p *parser) methodDecl() *Field {
 		if p.tok != _Lparen {
 			// packname
 			f.Type = p.qualifiedName(name)
+			if p.mode&AllowGenerics != 0 {
+				if p.tok == _Lbrack {
+					// generic type instantiation
+					pos := p.pos()
+					p.next()
+					f.Type = instance(pos, f.Type, p.typeArgs())
+				}
+				// the type may be the first term of a union
+				f.Type = p.union(f.Type)
+			}
 			return f
 		}
 
@@ -1370,6 +1793,16 @@ func (p *parser) methodDecl() *Field {
 		p.want(_Rparen)
 		return f
 
+	case _Operator, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface:
+		if p.mode&AllowGenerics != 0 && (p.tok != _Operator || p.op == Tilde) {
+			// type element
+			f := new(Field)
+			f.pos = p.pos()
+			f.Type = p.constraint()
+			return f
+		}
+		fallthrough
+
 	default:
 		p.syntax_error("expecting method or interface name")
 		p.advance(_Semi, _Rbrace)
@@ -1389,6 +1822,11 @@ func (p *parser) paramDeclOrNil() *Field {
 	switch p.tok {
 	case _Name:
 		f.Name = p.name()
+		if p.mode&AllowGenerics != 0 && p.tok == _Lbrack {
+			// name "[" starts an array or slice type or a type argument list
+			f.Name, f.Type = p.arrayOrTArgs(f.Name)
+			break
+		}
 		switch p.tok {
 		case _Name, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface, _Lparen:
 			// sym name_or_type
@@ -1422,6 +1860,131 @@ func (p *parser) paramDeclOrNil() *Field {
 	return f
 }
 
+// arrayOrTArgs parses the "[" ... following a parameter name and
+// resolves the ambiguity between a parameter of array type (name [N]E)
+// and an unnamed parameter of generic type (name[T]). It returns the
+// parameter name, if any, and type.
+func (p *parser) arrayOrTArgs(name *Name) (*Name, Expr) {
+	if trace {
+		defer p.trace("arrayOrTArgs")()
+	}
+
+	pos := p.pos()
+	p.want(_Lbrack)
+	if p.got(_Rbrack) {
+		// name []E
+		t := new(SliceType)
+		t.pos = pos
+		t.Elem = p.type_()
+		return name, t
+	}
+	if p.got(_DotDotDot) {
+		// name [...]E
+		p.want(_Rbrack)
+		t := new(ArrayType)
+		t.pos = pos
+		t.Elem = p.type_()
+		return name, t
+	}
+
+	args := p.typeArgs()
+	switch p.tok {
+	case _Name, _Star, _Arrow, _Func, _Lbrack, _Chan, _Map, _Struct, _Interface, _Lparen:
+		if len(args) == 1 {
+			// name [N]E
+			t := new(ArrayType)
+			t.pos = pos
+			t.Len = args[0]
+			t.Elem = p.type_()
+			return name, t
+		}
+	}
+
+	// name[T1, T2, ...]
+	return nil, instance(pos, name, args)
+}
+
+// TypeArgs = "[" Type { "," Type } [ "," ] "]" .
+//
+// The opening "[" has been consumed already. In a method receiver,
+// the type arguments are the names of the receiver type parameters.
+func (p *parser) typeArgs() []Expr {
+	if trace {
+		defer p.trace("typeArgs")()
+	}
+
+	p.xnest++
+	var list []Expr
+	for p.tok != _EOF && p.tok != _Rbrack {
+		x := p.expr()
+		list = append(list, x)
+		if !p.got(_Comma) {
+			if p.tok != _Rbrack {
+				if name, ok := x.(*Name); ok && p.recv && p.tok != _Rparen {
+					p.error(fmt.Sprintf("receiver type parameter %s cannot have a constraint", name.Value))
+				} else {
+					p.syntax_error("expecting comma or ]")
+				}
+				p.advance(_Rbrack, _Rparen)
+			}
+			break
+		}
+	}
+	if len(list) == 0 {
+		p.syntax_error("expecting type argument")
+	}
+	p.xnest--
+	p.want(_Rbrack)
+	return list
+}
+
+// instance returns the instantiation of the generic type x with the
+// type arguments args, represented as an IndexExpr. If there is more
+// than one type argument, the index is a ListExpr.
+func instance(pos src.Pos, x Expr, args []Expr) *IndexExpr {
+	t := new(IndexExpr)
+	t.pos = pos
+	t.X = x
+	switch len(args) {
+	case 0:
+		b := new(BadExpr) // error reported by typeArgs
+		b.pos = pos
+		t.Index = b
+	case 1:
+		t.Index = args[0]
+	default:
+		l := new(ListExpr)
+		l.pos = args[0].Pos()
+		l.ElemList = args
+		t.Index = l
+	}
+	return t
+}
+
+// checkRecvTParams reports an error if the type arguments of the
+// receiver type typ, if any, are not names of type parameters.
+func (p *parser) checkRecvTParams(typ Expr) {
+	if star, ok := typ.(*Operation); ok && star.Op == Mul && star.Y == nil {
+		typ = star.X
+	}
+	t, ok := typ.(*IndexExpr)
+	if !ok {
+		return
+	}
+	list := []Expr{t.Index}
+	if l, ok := t.Index.(*ListExpr); ok {
+		list = l.ElemList
+	}
+	for _, x := range list {
+		switch x.(type) {
+		case *Name, *BadExpr:
+			// ok, or error reported already
+		default:
+			p.error_at(x.Pos(), "receiver type parameter must be an identifier")
+		}
+	}
+}
+
 // ...Type
 func (p *parser) dotsType() *DotsType {
 	if trace {
@@ -1581,7 +2144,11 @@ func (p *parser) simpleStmt(lhs Expr, rangeOk bool) SimpleStmt {
 		}
 
 		// expr_list '=' expr_list
-		return p.newAssignStmt(pos, 0, lhs, p.exprList())
+		rhs := p.exprList()
+		if p.mode&AssignmentErrors != 0 {
+			p.checkAssign(pos, lhs, rhs)
+		}
+		return p.newAssignStmt(pos, 0, lhs, rhs)
 
 	case _Define:
 		p.next()
@@ -1613,6 +2180,10 @@ func (p *parser) simpleStmt(lhs Expr, rangeOk bool) SimpleStmt {
 			return s
 		}
 
+		if p.mode&AssignmentErrors != 0 {
+			p.checkAssign(pos, lhs, rhs)
+			p.checkDefine(pos, lhs)
+		}
 		as := p.newAssignStmt(pos, Def, lhs, rhs)
 		return as
 
@@ -1630,6 +2201,56 @@ func (p *parser) simpleStmt(lhs Expr, rangeOk bool) SimpleStmt {
 	}
 }
 
+// checkAssign reports an error if the numbers of variables and values
+// of the assignment lhs = rhs (or lhs := rhs) at pos differ. That is
+// only known syntactically if there is more than one value; a single
+// value, such as a function call, may provide several. checkAssign and
+// checkDefine are only used in AssignmentErrors mode; otherwise these
+// errors are left to the type checker.
+func (p *parser) checkAssign(pos src.Pos, lhs, rhs Expr) {
+	r, ok := rhs.(*ListExpr)
+	if !ok {
+		return
+	}
+	nl := 1
+	if l, ok := lhs.(*ListExpr); ok {
+		nl = len(l.ElemList)
+	}
+	if nr := len(r.ElemList); nl != nr {
+		vars := "variables"
+		if nl == 1 {
+			vars = "variable"
+		}
+		p.error_at(pos, fmt.Sprintf("cannot assign %d values to %d %s", nr, nl, vars))
+	}
+}
+
+// checkDefine reports an error if the variables lhs of the short
+// variable declaration at pos are not all names, or if they are all
+// blank: a short variable declaration must declare at least one new
+// (non-blank) variable. Whether the others are new depends on the
+// enclosing scope, which the parser doesn't know.
+func (p *parser) checkDefine(pos src.Pos, lhs Expr) {
+	list := []Expr{lhs}
+	if l, ok := lhs.(*ListExpr); ok {
+		list = l.ElemList
+	}
+	blank := true
+	for _, x := range list {
+		name, ok := x.(*Name)
+		if !ok {
+			p.error_at(x.Pos(), fmt.Sprintf("non-name %s on left side of :=", String(x)))
+			return
+		}
+		if name.Value != "_" {
+			blank = false
+		}
+	}
+	if blank {
+		p.error_at(pos, "no new variables on left side of :=")
+	}
+}
+
 func (p *parser) newRangeClause(lhs Expr, def bool) *RangeClause {
 	r := new(RangeClause)
 	r.pos = p.pos()
@@ -1748,6 +2369,9 @@ func (p *parser) header(keyword token) (init SimpleStmt, cond Expr, post SimpleS
 
 	outer := p.xnest
 	p.xnest = -1
+	outerGuards := p.guards
+	p.guards = nil
+	errcnt := p.errcnt
 
 	if p.tok != _Semi {
 		// accept potential varDecl but complain
@@ -1757,7 +2381,9 @@ func (p *parser) header(keyword token) (init SimpleStmt, cond Expr, post SimpleS
 		init = p.simpleStmt(nil, keyword == _For)
 		// If we have a range clause, we are done (can only happen for keyword == _For).
 		if _, ok := init.(*RangeClause); ok {
+			p.checkGuards(nil, errcnt)
 			p.xnest = outer
+			p.guards = outerGuards
 			return
 		}
 	}
@@ -1780,7 +2406,11 @@ func (p *parser) header(keyword token) (init SimpleStmt, cond Expr, post SimpleS
 				condStmt = p.simpleStmt(nil, false)
 			}
 			p.want(_Semi)
-			if p.tok != _Lbrace {
+			if p.tok == _Semi {
+				// for init; cond; ; {} has one clause too many
+				p.syntax_error("expecting for loop post statement or {")
+				p.advance(_Lbrace)
+			} else if p.tok != _Lbrace {
 				post = p.simpleStmt(nil, false)
 				if a, _ := post.(*AssignStmt); a != nil && a.Op == Def {
 					p.syntax_error_at(a.Pos(), "cannot declare in post statement of for loop")
@@ -1811,10 +2441,31 @@ done:
 		p.syntax_error(fmt.Sprintf("%s used as value", String(s)))
 	}
 
+	var tag Expr
+	if keyword == _Switch {
+		tag = cond
+	}
+	p.checkGuards(tag, errcnt)
 	p.xnest = outer
+	p.guards = outerGuards
 	return
 }
 
+// checkGuards reports the type switch guards parsed in the current
+// statement header except for tag, the tag of a type switch, if any.
+// Errors are not reported if the header had other errors, which may
+// be the cause; errcnt is the error count at the start of the header.
+func (p *parser) checkGuards(tag Expr, errcnt int) {
+	if errcnt != p.errcnt {
+		return
+	}
+	for _, g := range p.guards {
+		if g != tag {
+			p.error_at(g.Pos(), "use of .(type) outside type switch")
+		}
+	}
+}
+
 func (p *parser) ifStmt() *IfStmt {
 	if trace {
 		defer p.trace("ifStmt")()
@@ -1861,9 +2512,73 @@ func (p *parser) switchStmt() *SwitchStmt {
 	s.Rbrace = p.pos()
 	p.want(_Rbrace)
 
+	if p.mode&DuplicateCases != 0 {
+		p.checkCases(s)
+	}
+
 	return s
 }
 
+// checkCases reports duplicate cases in the switch statement s.
+// In expression switches, only basic literal cases are considered
+// since, without type information, the values of other constant
+// expressions are unknown. In type switches, types are considered
+// duplicates if they are spelled the same.
+func (p *parser) checkCases(s *SwitchStmt) {
+	_, typeSwitch := s.Tag.(*TypeSwitchGuard)
+	seen := make(map[string]Expr)
+	for _, c := range s.Body {
+		var list []Expr
+		switch x := c.Cases.(type) {
+		case nil:
+			// default case
+		case *ListExpr:
+			list = x.ElemList
+		default:
+			list = []Expr{x}
+		}
+		for _, x := range list {
+			var key string
+			if typeSwitch {
+				key = String(x)
+			} else {
+				lit, ok := unparen(x).(*BasicLit)
+				if !ok {
+					continue
+				}
+				key = litKey(lit)
+			}
+			if prev := seen[key]; prev != nil {
+				what := "switch"
+				if typeSwitch {
+					what = "type switch"
+				}
+				p.error_at(x.Pos(), fmt.Sprintf("duplicate case %s in %s\n\tprevious case at %s", String(x), what, prev.Pos()))
+				continue
+			}
+			seen[key] = x
+		}
+	}
+}
+
+// litKey returns a map key for the value of the basic literal lit,
+// such that literals with the same value have the same key where
+// this is easy to determine (for instance, 10 and 0xa).
+func litKey(lit *BasicLit) string {
+	val := lit.Value
+	switch lit.Kind {
+	case IntLit:
+		if x, err := strconv.ParseUint(val, 0, 64); err == nil {
+			val = strconv.FormatUint(x, 10)
+		}
+	case RuneLit, StringLit:
+		if x, err := strconv.Unquote(val); err == nil {
+			val = x
+		}
+	}
+	return fmt.Sprintf("%d:%s", lit.Kind, val)
+}
+
 func (p *parser) selectStmt() *SelectStmt {
 	if trace {
 		defer p.trace("selectStmt")()
@@ -1934,10 +2649,12 @@ func (p *parser) commClause() *CommClause {
 		//     lhs = <-x
 		//     lhs := <-x
 		//
-		// All these (and more) are recognized by simpleStmt and invalid
-		// syntax trees are flagged later, during type checking.
-		// TODO(gri) eventually may want to restrict valid syntax trees
-		// here.
+		// All these (and more) are recognized by simpleStmt; report
+		// anything else here. Invalid left-hand sides are flagged
+		// later, during type checking.
+		if !isCommStmt(c.Comm) {
+			p.error_at(c.pos, "select case must be receive, send or assign recv")
+		}
 
 	case _Default:
 		p.next()
@@ -1954,6 +2671,32 @@ func (p *parser) commClause() *CommClause {
 	return c
 }
 
+// isCommStmt reports whether s is a statement permitted in a select case:
+// a send statement, a receive operation, or an assignment or short
+// variable declaration with a receive operation on the right-hand side.
+// It also accepts statements that are invalid for reasons that have
+// already been reported.
+func isCommStmt(s SimpleStmt) bool {
+	switch s := s.(type) {
+	case *SendStmt:
+		return true
+	case *ExprStmt:
+		if _, ok := s.X.(*BadExpr); ok {
+			return true // error reported elsewhere
+		}
+		return isRecv(s.X)
+	case *AssignStmt:
+		return (s.Op == 0 || s.Op == Def) && isRecv(s.Rhs)
+	}
+	return false
+}
+
+// isRecv reports whether x is a (possibly parenthesized) receive operation.
+func isRecv(x Expr) bool {
+	op, ok := unparen(x).(*Operation)
+	return ok && op.Op == Recv && op.Y == nil
+}
+
 // Statement =
 // 	Declaration | LabeledStmt | SimpleStmt |
 // 	GoStmt | ReturnStmt | BreakStmt | ContinueStmt | GotoStmt |
@@ -1967,6 +2710,11 @@ func (p *parser) stmtOrNil() Stmt {
 	// Most statements (assignments) start with an identifier;
 	// look for it first before doing anything more expensive.
 	if p.tok == _Name {
+		if p.mode&Experimental != 0 {
+			if parse := experimentalStmts[p.lit]; parse != nil {
+				return parse(p)
+			}
+		}
 		lhs := p.exprList()
 		if label, ok := lhs.(*Name); ok && p.tok == _Colon {
 			return p.labeledStmtOrNil(label)
@@ -2075,9 +2823,57 @@ func (p *parser) stmtList() (l []Stmt) {
 			p.got(_Semi) // avoid spurious empty statement
 		}
 	}
+	if p.mode&UnreachableCode != 0 {
+		p.checkUnreachable(l)
+	}
 	return
 }
 
+// checkUnreachable reports the first statement of list following
+// a statement after which control never continues with the next
+// statement in the list. A labeled statement is considered reachable
+// since it may be the target of a goto. The check is syntactic; for
+// instance, panic is assumed to be the predeclared function.
+func (p *parser) checkUnreachable(list []Stmt) {
+	dead := false
+	for _, s := range list {
+		switch s.(type) {
+		case *EmptyStmt:
+			continue
+		case *LabeledStmt:
+			dead = false
+		}
+		if dead {
+			p.error_at(s.Pos(), "unreachable code")
+			dead = false // report only the first statement
+		}
+		dead = isTerminating(s)
+	}
+}
+
+// isTerminating reports whether s is a return or branch
+// statement (other than fallthrough) or a call of panic
+// or os.Exit.
+func isTerminating(s Stmt) bool {
+	switch s := s.(type) {
+	case *ReturnStmt:
+		return true
+	case *BranchStmt:
+		return s.Tok != _Fallthrough
+	case *ExprStmt:
+		if call, ok := s.X.(*CallExpr); ok {
+			switch fun := unparen(call.Fun).(type) {
+			case *Name:
+				return fun.Value == "panic"
+			case *SelectorExpr:
+				pkg, ok := fun.X.(*Name)
+				return ok && pkg.Value == "os" && fun.Sel.Value == "Exit"
+			}
+		}
+	}
+	return false
+}
+
 // Arguments = "(" [ ( ExpressionList | Type [ "," ExpressionList ] ) [ "..." ] [ "," ] ] ")" .
 func (p *parser) argList() (list []Expr, hasDots bool) {
 	if trace {
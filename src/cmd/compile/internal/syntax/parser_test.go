@@ -10,7 +10,10 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -181,6 +184,66 @@ func TestParseFile(t *testing.T) {
 	}
 }
 
+// TestErrorCheck parses the errorcheck tests in $GOROOT/test that
+// exercise syntax errors and verifies that the reported errors match
+// the ERROR comments, the way test/run.go does for the compiler.
+func TestErrorCheck(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "..", "..", "test")
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip(err)
+	}
+
+	errRx := regexp.MustCompile(`// ERROR (.*)`)
+	quotesRx := regexp.MustCompile(`"([^"]*)"`)
+	for _, name := range []string{
+		"fixedbugs/bug163.go",
+		"fixedbugs/issue11359.go",
+		"fixedbugs/issue4405.go",
+		"syntax/ddd.go",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		// collect expected errors by line
+		want := make(map[uint][]*regexp.Regexp)
+		for i, line := range strings.Split(string(data), "\n") {
+			m := errRx.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			for _, q := range quotesRx.FindAllStringSubmatch(m[1], -1) {
+				rx, err := regexp.Compile(q[1])
+				if err != nil {
+					t.Fatalf("%s:%d: %v", name, i+1, err)
+				}
+				want[uint(i+1)] = append(want[uint(i+1)], rx)
+			}
+		}
+
+		ParseBytes(src.NewFileBase(filename, filename), data, func(err error) {
+			e := err.(Error)
+			line := e.Pos.Line()
+			for i, rx := range want[line] {
+				if rx.MatchString(e.Msg) {
+					want[line] = append(want[line][:i], want[line][i+1:]...)
+					return
+				}
+			}
+			t.Errorf("%s: unexpected error: %s", name, err)
+		}, nil, nil, CheckBranches)
+
+		for line, rxs := range want {
+			for _, rx := range rxs {
+				t.Errorf("%s:%d: missing error %q", name, line, rx)
+			}
+		}
+	}
+}
+
 func TestLineDirectives(t *testing.T) {
 	for _, test := range []struct {
 		src, msg  string
@@ -232,3 +295,1556 @@ func TestLineDirectives(t *testing.T) {
 		}
 	}
 }
+
+// fuzzParse parses src and checks that the parser doesn't panic
+// and that it returns either a syntax tree or an error.
+func fuzzParse(t *testing.T, src []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			t.Fatalf("%q: parser panicked: %v", src, p)
+		}
+	}()
+
+	for _, mode := range []Mode{CheckBranches, AllowIncomplete} {
+		for _, errh := range []ErrorHandler{nil, func(error) {}} {
+			f, err := ParseBytes(nil, src, errh, nil, nil, mode)
+			if f == nil && err == nil {
+				t.Fatalf("%q: got neither syntax tree nor error", src)
+			}
+		}
+	}
+}
+
+func TestFuzzParse(t *testing.T) {
+	corpus := []string{
+		"package p",
+		"package p; import (\"a\"; b \"b\"; . \"c\")",
+		"package p; type T struct { a, b int `tag`; *E }; type A = T",
+		"package p; var x, y = []int{1, 2}, map[string]T{\"a\": {}}",
+		"package p; func (r *T) m(a int, b ...string) (n int, err error) { return }",
+		"package p; func f() { L: for i := range x { if i > 0 { continue L } }; goto L }",
+		"package p; func f() { switch x := y.(type) { case int, nil: }; select { case v, ok := <-c: _ = v; default: } }",
+		"//line foo:10\npackage p; func f() { defer g(); go h(); x++; c <- 1 }",
+
+		// crashers found while fuzzing
+		"package p; func f() { L: }",     // unused label with nil error handler
+		"package p; func f() { goto L }", // undefined label with nil error handler
+	}
+
+	// The seeds themselves as well as all their prefixes
+	// (truncated input is a common source of crashes).
+	for _, src := range corpus {
+		for i := 0; i <= len(src); i++ {
+			fuzzParse(t, []byte(src[:i]))
+		}
+	}
+
+	// Random single-byte mutations of the seeds.
+	rnd := rand.New(rand.NewSource(1))
+	const alphabet = "(){}[];:,.=+-*/<>!&|^~\"'`\n\t _ax0\x00\xff"
+	for _, src := range corpus {
+		for n := 0; n < 200; n++ {
+			b := []byte(src)
+			b[rnd.Intn(len(b))] = alphabet[rnd.Intn(len(alphabet))]
+			fuzzParse(t, b)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for _, test := range []struct {
+		src, want string // want is the printed type, or the error
+	}{
+		{"int", "int"},
+		{"map[string][]*Foo", "map[string][]*Foo"},
+		{"func(int) (string, error)", "func(int) (string, error)"},
+		{"<-chan int", "<-chan int"},
+		{"chan<- []byte\n", "chan<- []byte"},
+		{"struct{ x, y float64 }", "struct{ x, y float64 }"},
+		{"pkg.T", "pkg.T"},
+
+		{"", "1:1: syntax error: unexpected EOF, expecting type"},
+		{"1 + 2", "1:1: syntax error: unexpected literal 1, expecting type"},
+		{"x + y", "1:3: syntax error: unexpected + after type"},
+		{"[]int{}", "1:6: syntax error: unexpected { after type"},
+	} {
+		typ, err := ParseType(test.src)
+		var got string
+		if err != nil {
+			e := err.(Error)
+			got = fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col(), e.Msg)
+		} else {
+			got = String(typ)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q; want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestParseStmts(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		want string // statement types, or the error
+	}{
+		{"", ""},
+		{"x := 1; y = x + 2", "*syntax.AssignStmt *syntax.AssignStmt"},
+		{"a, b := f()\nb++\n", "*syntax.AssignStmt *syntax.AssignStmt"},
+		{"if x > 0 {\n\tprintln(x)\n} else {\n\treturn\n}", "*syntax.IfStmt"},
+		{"for i := 0; i < 3; i++ { continue }; var v T", "*syntax.ForStmt *syntax.DeclStmt"},
+		{"switch x { case 1: break }; go f(); L: goto L", "*syntax.SwitchStmt *syntax.CallStmt *syntax.LabeledStmt"},
+		{"f(x); ch <- v; { x := 2 }", "*syntax.ExprStmt *syntax.SendStmt *syntax.BlockStmt"},
+
+		{"x := 1; y z", "1:11: syntax error: unexpected z at end of statement"},
+		{"x = 1 }", "1:7: syntax error: unexpected } after statement list"},
+		{"case 1:", "1:1: syntax error: unexpected case after statement list"},
+		{"x :=", "1:5: syntax error: unexpected EOF, expecting expression"},
+		{"package p", "1:1: syntax error: unexpected package after statement list"},
+	} {
+		list, err := ParseStmts(test.src)
+		var got string
+		if err != nil {
+			e := err.(Error)
+			got = fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col(), e.Msg)
+		} else {
+			var types []string
+			for _, s := range list {
+				types = append(types, fmt.Sprintf("%T", s))
+			}
+			got = strings.Join(types, " ")
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q; want %q", test.src, got, test.want)
+		}
+	}
+
+	list, err := ParseStmts("v, ok := m[k]; if !ok { v = def }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := String(list[0]); got != "v, ok := m[k]" {
+		t.Errorf("got %s; want v, ok := m[k]", got)
+	}
+	if got := String(list[1].(*IfStmt).Cond); got != "!ok" {
+		t.Errorf("got condition %s; want !ok", got)
+	}
+}
+
+func TestParseWithProgress(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+const c = 0
+
+var (
+	x int
+	y string
+)
+
+type T struct{}
+
+func (T) m() {}
+
+func f() {
+	fmt.Println(c)
+}
+`
+	type call struct {
+		count int
+		line  uint
+	}
+	var calls []call
+	_, err := ParseWithProgress(nil, strings.NewReader(src), nil, nil, nil, func(count int, line uint) {
+		calls = append(calls, call{count, line})
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 5 top-level declarations (the var group counts once, imports don't count)
+	want := []call{{1, 5}, {2, 10}, {3, 12}, {4, 14}, {5, 18}}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("got calls %v; want %v", calls, want)
+	}
+}
+
+func TestParseIncomplete(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		want string // incomplete expressions, printed
+		body int    // number of statements in the body of f
+	}{
+		{"x := foo.\n}", "foo._", 1},
+		{"a := 1\n\tx := foo.\n}", "foo._", 2},
+		{"x := bar(\n}", "bar(<bad expr>)", 1},
+		{"x := bar(1, \n\treturn\n}", "bar(1, <bad expr>)", 2},
+		{"x := bar(a.b(c.\n}", "c._ a.b(c._) bar(a.b(c._))", 1},
+		{"x := bar(1, 2)\n}", "", 1},
+	} {
+		src := "package p; func f() {\n\t" + test.src + "\nfunc g() {}"
+		for _, errh := range []ErrorHandler{nil, func(error) {}} {
+			f, err := ParseBytes(nil, []byte(src), errh, nil, nil, AllowIncomplete)
+			if (err != nil) != (test.want != "") {
+				t.Errorf("%q: got error %v", test.src, err)
+			}
+			if f == nil {
+				t.Errorf("%q: no syntax tree", test.src)
+				continue
+			}
+
+			var list []string
+			for _, x := range f.Incomplete {
+				list = append(list, String(x))
+			}
+			if got := strings.Join(list, " "); got != test.want {
+				t.Errorf("%q: got incomplete %q; want %q", test.src, got, test.want)
+			}
+
+			// the rest of f and g must not be dropped
+			if len(f.DeclList) != 2 {
+				t.Errorf("%q: got %d declarations; want 2", test.src, len(f.DeclList))
+				continue
+			}
+			if n := len(f.DeclList[0].(*FuncDecl).Body.List); n != test.body {
+				t.Errorf("%q: got %d statements in f; want %d", test.src, n, test.body)
+			}
+		}
+	}
+}
+
+func TestExperimentalSyntax(t *testing.T) {
+	// try x is parsed as the call try(x)
+	registerExperimentalExpr("try", func(p *parser) Expr {
+		x := new(CallExpr)
+		x.pos = p.pos()
+		x.Fun = p.name()
+		x.ArgList = []Expr{p.unaryExpr()}
+		return x
+	})
+	defer delete(experimentalExprs, "try")
+
+	// unless cond { ... } is parsed as if !(cond) { ... }
+	registerExperimentalStmt("unless", func(p *parser) Stmt {
+		s := new(IfStmt)
+		s.pos = p.pos()
+		p.next()
+		outer := p.xnest
+		p.xnest = -1 // { starts the block, not a composite literal
+		cond := new(ParenExpr)
+		cond.pos = p.pos()
+		cond.X = p.expr()
+		p.xnest = outer
+		not := new(Operation)
+		not.pos = cond.pos
+		not.Op = Not
+		not.X = cond
+		s.Cond = not
+		s.Then = p.blockStmt("unless clause")
+		return s
+	})
+	defer delete(experimentalStmts, "unless")
+
+	const src = `package p; func f() { unless try g() { x := try h(); _ = x } }`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, Experimental)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "if !(try(g())) { x := try(h()); _ = x }"
+	if got := String(f.DeclList[0].(*FuncDecl).Body.List[0]); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// without Experimental mode, try and unless are ordinary names
+	if _, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0); err == nil {
+		t.Error("no error without Experimental mode")
+	}
+}
+
+func TestSkipFuncBodies(t *testing.T) {
+	const src = `package p
+
+func f() {
+	if x { y := func() { }; { nested } } ) not valid Go
+}
+
+func (T) m() int { return 0 }
+
+func g()
+
+var x = func() int { return 1 }()
+`
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, SkipFuncBodies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.DeclList) != 4 {
+		t.Fatalf("got %d declarations; want 4", len(f.DeclList))
+	}
+
+	for i, test := range []struct {
+		line              uint // line of the declaration
+		lbrace, rbrace    uint // body {} positions (line) or 0 if there's no body
+		lbraceCol, rbrCol uint
+	}{
+		{3, 3, 5, 10, 1},
+		{7, 7, 7, 18, 29},
+		{9, 0, 0, 0, 0},
+	} {
+		d := f.DeclList[i].(*FuncDecl)
+		if line := d.Pos().Line(); line != test.line {
+			t.Errorf("%s: got line %d; want %d", d.Name.Value, line, test.line)
+		}
+		if test.lbrace == 0 {
+			if d.Body != nil {
+				t.Errorf("%s: got body; want none", d.Name.Value)
+			}
+			continue
+		}
+		b := d.Body
+		if len(b.List) != 0 {
+			t.Errorf("%s: body was parsed", d.Name.Value)
+		}
+		if got, want := fmt.Sprintf("%d:%d-%d:%d", b.Pos().Line(), b.Pos().Col(), b.Rbrace.Line(), b.Rbrace.Col()),
+			fmt.Sprintf("%d:%d-%d:%d", test.lbrace, test.lbraceCol, test.rbrace, test.rbrCol); got != want {
+			t.Errorf("%s: got body at %s; want %s", d.Name.Value, got, want)
+		}
+	}
+
+	// function literals are still parsed
+	if got := String(f.DeclList[3]); got != "var x = func() int { return 1 }()" {
+		t.Errorf("got %q", got)
+	}
+
+	// an unterminated body is reported
+	if _, err := ParseBytes(nil, []byte("package p; func f() { {"), nil, nil, nil, SkipFuncBodies); err == nil {
+		t.Error("no error for unterminated function body")
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	const src = `// Package p.
+package p
+
+import "a" // a
+import ("b"; "c")
+
+var x int /* x */
+const x = 0
+type T int
+func (T) m() {}
+func (*T) m() {}
+func init() {}
+func init() {}
+`
+
+	// ImportsOnly
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.DeclList) != 3 {
+		t.Errorf("ImportsOnly: got %d declarations; want 3", len(f.DeclList))
+	}
+	for _, d := range f.DeclList {
+		if _, ok := d.(*ImportDecl); !ok {
+			t.Errorf("ImportsOnly: got %T; want *ImportDecl", d)
+		}
+	}
+
+	// ParseComments
+	f, err = ParseBytes(nil, []byte(src), nil, nil, nil, ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var comments []string
+	for _, c := range f.Comments {
+		comments = append(comments, fmt.Sprintf("%d:%d %s", c.Pos.Line(), c.Pos.Col(), c.Text))
+	}
+	if got, want := strings.Join(comments, ", "), "1:1 // Package p., 4:12 // a, 7:11 /* x */"; got != want {
+		t.Errorf("ParseComments: got %s; want %s", got, want)
+	}
+
+	// DeclarationErrors
+	var errs []string
+	ParseBytes(nil, []byte(src), func(err error) {
+		e := err.(Error)
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col(), strings.Replace(e.Msg, "\n\t", " ", -1)))
+	}, nil, nil, DeclarationErrors)
+	want := []string{
+		"8:7: x redeclared in this block previous declaration at :7:5",
+		"11:11: T.m redeclared in this block previous declaration at :10:10",
+	}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("DeclarationErrors: got %q; want %q", errs, want)
+	}
+	if _, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0); err != nil {
+		t.Errorf("got %v without DeclarationErrors", err)
+	}
+
+	// AllErrors
+	for _, mode := range []Mode{0, AllErrors} {
+		var n int
+		ParseBytes(nil, []byte("package p; func f() { x := (1"), func(error) { n++ }, nil, nil, mode)
+		if (n > 1) != (mode == AllErrors) {
+			t.Errorf("mode %d: got %d errors", mode, n)
+		}
+	}
+}
+
+func TestDuplicateCases(t *testing.T) {
+	const src = `package p
+
+func _() {
+	switch x {
+	case 1, 2, 0x1:
+	case y, y: // not literals
+	case "a", ("a"):
+	case '\x61', 'a':
+	default:
+	}
+
+	switch x := x.(type) {
+	case int, []string:
+	case nil, p.T:
+	case []string, nil, *p.T:
+	}
+}
+`
+
+	var errs []string
+	ParseBytes(nil, []byte(src), func(err error) {
+		e := err.(Error)
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col(), strings.Replace(e.Msg, "\n\t", " ", -1)))
+	}, nil, nil, DuplicateCases)
+	want := []string{
+		"5:13: duplicate case 0x1 in switch previous case at :5:7",
+		`7:12: duplicate case ("a") in switch previous case at :7:7`,
+		`8:15: duplicate case 'a' in switch previous case at :8:7`,
+		"15:7: duplicate case []string in type switch previous case at :13:12",
+		"15:17: duplicate case nil in type switch previous case at :14:7",
+	}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("got %q\nwant %q", errs, want)
+	}
+
+	if _, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0); err != nil {
+		t.Errorf("got %v without DuplicateCases", err)
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	const src = "package p; type _ struct {\n" +
+		"\ta int `json:\"a\"`\n" +
+		"\tb, c string \"x\\ty\"\n" +
+		"\td []byte\n" +
+		"\t*T `embedded`\n" +
+		"\tp.U\n" +
+		"}"
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	styp := f.DeclList[0].(*TypeDecl).Type.(*StructType)
+
+	want := []struct {
+		tag  string
+		line uint // of tag literal; 0 means no tag
+	}{
+		{`json:"a"`, 2},
+		{"x\ty", 3},
+		{"x\ty", 3},
+		{"", 0},
+		{"embedded", 5},
+		{"", 0},
+	}
+	if len(styp.FieldList) != len(want) {
+		t.Fatalf("got %d fields; want %d", len(styp.FieldList), len(want))
+	}
+	for i, w := range want {
+		if got := styp.Tag(i); got != w.tag {
+			t.Errorf("field %d: got tag %q; want %q", i, got, w.tag)
+		}
+		var line uint
+		if i < len(styp.TagList) && styp.TagList[i] != nil {
+			line = styp.TagList[i].Pos().Line()
+		}
+		if line != w.line {
+			t.Errorf("field %d: got tag at line %d; want %d", i, line, w.line)
+		}
+	}
+}
+
+func TestLabeledStmts(t *testing.T) {
+	const src = `package p
+
+func _() {
+Loop:
+	for {
+		if x {
+			continue Loop
+		}
+		goto Done
+	}
+Done:
+	return
+}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, CheckBranches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := f.DeclList[0].(*FuncDecl).Body.List
+	if len(body) != 2 {
+		t.Fatalf("got %d statements; want 2", len(body))
+	}
+
+	loop, ok := body[0].(*LabeledStmt)
+	if !ok {
+		t.Fatalf("got %T; want *LabeledStmt", body[0])
+	}
+	if name, pos := loop.Label.Value, loop.Label.Pos(); name != "Loop" || pos.Line() != 4 || pos.Col() != 1 {
+		t.Errorf("got label %s at %d:%d; want Loop at 4:1", name, pos.Line(), pos.Col())
+	}
+	forStmt, ok := loop.Stmt.(*ForStmt)
+	if !ok {
+		t.Fatalf("got labeled %T; want *ForStmt", loop.Stmt)
+	}
+
+	cont := forStmt.Body.List[0].(*IfStmt).Then.List[0].(*BranchStmt)
+	if cont.Tok != _Continue || cont.Label == nil || cont.Label.Value != "Loop" {
+		t.Errorf("got %s %v; want continue Loop", cont.Tok, cont.Label)
+	}
+	if cont.Target != forStmt {
+		t.Errorf("continue: got target %v; want the for statement", cont.Target)
+	}
+
+	done, ok := body[1].(*LabeledStmt)
+	if !ok || done.Label.Value != "Done" {
+		t.Fatalf("got %T; want labeled statement Done", body[1])
+	}
+	if _, ok := done.Stmt.(*ReturnStmt); !ok {
+		t.Errorf("got labeled %T; want *ReturnStmt", done.Stmt)
+	}
+
+	jump := forStmt.Body.List[1].(*BranchStmt)
+	if jump.Tok != _Goto || jump.Label == nil || jump.Label.Value != "Done" {
+		t.Errorf("got %s %v; want goto Done", jump.Tok, jump.Label)
+	}
+	if jump.Target != done {
+		t.Errorf("goto: got target %v; want the statement labeled Done", jump.Target)
+	}
+}
+
+func TestTypeDecls(t *testing.T) {
+	const src = `package p
+
+type A = B
+type D B
+type M[T any] = map[string]T
+type L[T, U any, V comparable] struct{}
+type N[T interface{ m() }] *T
+type S []int
+type X [N]int
+type Y [N + 1]int
+type Z [N * M]int
+type C [len(x)]int
+type I [a[0]]int
+type J [a[i][j] * 2]int
+type P[T []E] struct{}
+type Q[T [N]E, U any] struct{}
+type R[T (*C), U (C)] struct{}
+type W[T (interface{}) | int] struct{}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, AllowGenerics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []struct {
+		alias   bool
+		tparams string // name constraint pairs
+		typ     string
+	}{
+		{true, "", "B"},
+		{false, "", "B"},
+		{true, "T any", "map[string]T"},
+		{false, "T any U any V comparable", "struct{}"},
+		{false, "T interface{ m() }", "*T"},
+		{false, "", "[]int"},
+		{false, "", "[N]int"},
+		{false, "", "[N + 1]int"},
+		{false, "", "[N * M]int"},
+		{false, "", "[len(x)]int"},
+		{false, "", "[a[0]]int"},
+		{false, "", "[a[i][j] * 2]int"},
+		{false, "T []E", "struct{}"},
+		{false, "T [N]E U any", "struct{}"},
+		{false, "T *C U C", "struct{}"},
+		{false, "T interface{} | int", "struct{}"},
+	} {
+		d := f.DeclList[i].(*TypeDecl)
+		var tparams []string
+		for _, f := range d.TParamList {
+			tparams = append(tparams, f.Name.Value+" "+String(f.Type))
+		}
+		if d.Alias != want.alias {
+			t.Errorf("%s: got Alias = %v; want %v", d.Name.Value, d.Alias, want.alias)
+		}
+		if got := strings.Join(tparams, " "); got != want.tparams {
+			t.Errorf("%s: got type parameters %q; want %q", d.Name.Value, got, want.tparams)
+		}
+		if got := String(d.Type); got != want.typ {
+			t.Errorf("%s: got type %s; want %s", d.Name.Value, got, want.typ)
+		}
+	}
+
+	// T and U share their constraint
+	if l := f.DeclList[3].(*TypeDecl).TParamList; l[0].Type != l[1].Type || l[1].Type == l[2].Type {
+		t.Error("L: type parameters don't share the constraint as declared")
+	}
+
+	// type declarations print as written
+	var buf bytes.Buffer
+	if _, err := Fprint(&buf, f, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "type L[T, U any, V comparable] struct{}") || !strings.Contains(got, "type M[T any] = map[string]T") {
+		t.Errorf("got printed source\n%s", got)
+	}
+}
+
+func TestFuncTypeParams(t *testing.T) {
+	const src = `package p
+
+func f[T, U any, V comparable](x T, y U) V
+func g[T any, U ~int | ~string](T, U)
+func h[T interface{ m() }, P *T,](p P)
+type C[T ~int, U ~[]T | map[T]U] struct{}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, AllowGenerics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{
+		"T any, U any, V comparable",
+		"T any, U ~int | ~string",
+		"T interface{ m() }, P *T",
+		"T ~int, U ~[]T | map[T]U",
+	} {
+		var list []*Field
+		switch d := f.DeclList[i].(type) {
+		case *FuncDecl:
+			list = d.TParamList
+		case *TypeDecl:
+			list = d.TParamList
+		}
+		var got []string
+		for _, f := range list {
+			got = append(got, f.Name.Value+" "+String(f.Type))
+		}
+		if got := strings.Join(got, ", "); got != want {
+			t.Errorf("decl %d: got %s; want %s", i, got, want)
+		}
+	}
+
+	// the constraint of U is a union of two ~ terms
+	u := f.DeclList[1].(*FuncDecl).TParamList[1].Type.(*Operation)
+	if u.Op != Or {
+		t.Fatalf("got %s; want |", u.Op)
+	}
+	for _, x := range []Expr{u.X, u.Y} {
+		if x, ok := x.(*Operation); !ok || x.Op != Tilde || x.Y != nil {
+			t.Errorf("got %s; want ~ term", String(x))
+		}
+	}
+
+	// T and U share their constraint
+	if l := f.DeclList[0].(*FuncDecl).TParamList; l[0].Type != l[1].Type || l[1].Type == l[2].Type {
+		t.Error("f: type parameters don't share the constraint as declared")
+	}
+
+	var buf bytes.Buffer
+	if _, err := Fprint(&buf, f, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "func f[T, U any, V comparable](x T, y U) V") || !strings.Contains(got, "func g[T any, U ~int | ~string](T, U)") {
+		t.Errorf("got printed source\n%s", got)
+	}
+
+	for _, test := range []struct {
+		src  string
+		mode Mode
+		err  string
+	}{
+		{"func (T) m[P any]()", AllowGenerics, "1:11: methods cannot have type parameters"},
+		{"func f[P, Q]()", AllowGenerics, "1:12: syntax error: missing type constraint"},
+		{"func f[P ~int]()", 0, "1:7: syntax error: unexpected [, expecting ("},
+		{"var x = ~1", 0, "1:9: bitwise complement operator is ^"},
+		{"var x = ~1", AllowGenerics, "1:9: syntax error: unexpected ~, expecting expression"},
+	} {
+		_, err := ParseBytes(nil, []byte("package p; "+test.src), nil, nil, nil, test.mode)
+		if err == nil {
+			t.Errorf("%s: no error", test.src)
+			continue
+		}
+		e := err.(Error)
+		if got := fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col()-uint(len("package p; ")), e.Msg); got != test.err {
+			t.Errorf("%s: got %s; want %s", test.src, got, test.err)
+		}
+	}
+}
+
+func TestInterfaceTypeElems(t *testing.T) {
+	const src = `package p
+
+type Number interface {
+	~int | ~int64 | float64
+	~string
+	fmt.Stringer
+	Ordered[Number]
+	[]byte
+	M(x int) error
+}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, AllowGenerics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range f.DeclList[0].(*TypeDecl).Type.(*InterfaceType).MethodList {
+		kind := "embedded"
+		switch typ := m.Type.(type) {
+		case *FuncType:
+			if m.Name == nil {
+				t.Errorf("got method %s without a name", String(typ))
+			}
+			kind = "method " + m.Name.Value
+		case *Operation:
+			kind = "union"
+			if typ.Op == Tilde {
+				kind = "~term"
+			}
+		case *SliceType:
+			kind = "type"
+		}
+		got = append(got, kind+": "+String(m.Type))
+	}
+	want := []string{
+		"union: ~int | ~int64 | float64",
+		"~term: ~string",
+		"embedded: fmt.Stringer",
+		"embedded: Ordered[Number]",
+		"type: []byte",
+		"method M: func(x int) error",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, test := range []struct {
+		src  string
+		mode Mode
+		err  string
+	}{
+		{"type I interface{ ~int }", 0, "1:19: bitwise complement operator is ^"},
+		{"type I interface{ []byte }", 0, "1:19: syntax error: unexpected [, expecting method or interface name"},
+		{"type I interface{ int | }", AllowGenerics, "1:25: syntax error: unexpected }, expecting type"},
+		{"type I interface{ + }", AllowGenerics, "1:19: syntax error: unexpected +, expecting method or interface name"},
+	} {
+		_, err := ParseBytes(nil, []byte("package p; "+test.src), nil, nil, nil, test.mode)
+		if err == nil {
+			t.Errorf("%s: no error", test.src)
+			continue
+		}
+		e := err.(Error)
+		if got := fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col()-uint(len("package p; ")), e.Msg); got != test.err {
+			t.Errorf("%s: got %s; want %s", test.src, got, test.err)
+		}
+	}
+}
+
+func TestRangeClauses(t *testing.T) {
+	const src = `package p
+
+func _() {
+	for i := range 10 {}
+	for range 10 {}
+	for v := range seq {}
+	for k, v = range m {}
+	for x := range func(yield func(int) bool) {} {}
+}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		lhs string
+		def bool
+		x   string // type of range expression
+	}{
+		{"i", true, "*syntax.BasicLit"},
+		{"", false, "*syntax.BasicLit"},
+		{"v", true, "*syntax.Name"},
+		{"k, v", false, "*syntax.Name"},
+		{"x", true, "*syntax.FuncLit"},
+	} {
+		s := f.DeclList[0].(*FuncDecl).Body.List[i].(*ForStmt)
+		r, ok := s.Init.(*RangeClause)
+		if !ok {
+			t.Errorf("for %d: got %T; want *RangeClause", i, s.Init)
+			continue
+		}
+		var lhs string
+		if r.Lhs != nil {
+			lhs = String(r.Lhs)
+		}
+		if lhs != want.lhs || r.Def != want.def {
+			t.Errorf("for %d: got Lhs %q, Def %v; want %q, %v", i, lhs, r.Def, want.lhs, want.def)
+		}
+		if x := fmt.Sprintf("%T", r.X); x != want.x {
+			t.Errorf("for %d: got range expression %s; want %s", i, x, want.x)
+		}
+	}
+}
+
+func TestFuncResults(t *testing.T) {
+	for _, test := range []struct {
+		src, want string // want lists the results as "name type" or "type"
+	}{
+		{"func f()", ""},
+		{"func f() int", "int"},
+		{"func f() (int)", "int"},
+		{"func f() (int, error)", "int, error"},
+		{"func f() ([]int, *T, p.T)", "[]int, *T, p.T"},
+		{"func f() (n int, err error)", "n int, err error"},
+		{"func f() (a, b int)", "a int, b int"},
+		{"func f() (a, b int, c, d string,)", "a int, b int, c string, d string"},
+		{"func f() (a []int, b func() (x, y T))", "a []int, b func() (x, y T)"},
+		{"func f() (int, err error)", "int error, err error"}, // int is a name here
+	} {
+		f, err := Parse(nil, strings.NewReader("package p; "+test.src), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		var list []string
+		for _, res := range f.DeclList[0].(*FuncDecl).Type.ResultList {
+			s := String(res.Type)
+			if res.Name != nil {
+				s = res.Name.Value + " " + s
+			}
+			list = append(list, s)
+		}
+		if got := strings.Join(list, ", "); got != test.want {
+			t.Errorf("%s: got results %s; want %s", test.src, got, test.want)
+		}
+	}
+
+	// names and types cannot be mixed
+	for _, src := range []string{
+		"func f() (a int, error)",
+		"func f() (a int, b)",
+		"func f() (a, b int, []byte)",
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; "+src), nil, nil, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), "mixed named and unnamed function parameters") {
+			t.Errorf("%s: got error %v; want mixed named and unnamed function parameters", src, err)
+		}
+	}
+}
+
+func TestBuiltinCalls(t *testing.T) {
+	// Built-in functions are predeclared names, not keywords;
+	// calls of them are ordinary call expressions.
+	const src = `package p
+
+func _() {
+	clear(m)
+	min(a, b)
+	max(a, b, c)
+	len(s...)
+}
+
+var min = func(a, b int) int { return a }
+
+func _() { min(1, 2) }
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(s Stmt, fun string, nargs int) {
+		t.Helper()
+		call, ok := s.(*ExprStmt).X.(*CallExpr)
+		if !ok {
+			t.Fatalf("got %T; want *CallExpr", s.(*ExprStmt).X)
+		}
+		name, ok := call.Fun.(*Name)
+		if !ok || name.Value != fun || len(call.ArgList) != nargs {
+			t.Errorf("got %s; want call of %s with %d arguments", String(call), fun, nargs)
+		}
+	}
+
+	body := f.DeclList[0].(*FuncDecl).Body.List
+	check(body[0], "clear", 1)
+	check(body[1], "min", 2)
+	check(body[2], "max", 3)
+	check(body[3], "len", 1)
+
+	if d := f.DeclList[1].(*VarDecl); d.NameList[0].Value != "min" {
+		t.Errorf("got var %s; want min", d.NameList[0].Value)
+	}
+	check(f.DeclList[2].(*FuncDecl).Body.List[0], "min", 2)
+}
+
+func TestElidedCompositeLitTypes(t *testing.T) {
+	// describe prints x like String, but marks elided
+	// composite literal types with a _
+	var describe func(x Expr) string
+	describe = func(x Expr) string {
+		switch x := x.(type) {
+		case *CompositeLit:
+			typ := "_"
+			if x.Type != nil {
+				typ = String(x.Type)
+			}
+			var elems []string
+			for _, e := range x.ElemList {
+				elems = append(elems, describe(e))
+			}
+			return typ + "{" + strings.Join(elems, ", ") + "}"
+		case *KeyValueExpr:
+			return describe(x.Key) + ": " + describe(x.Value)
+		}
+		return String(x)
+	}
+
+	for _, test := range []struct {
+		src, want string
+		nkeys     int // of the outer literal
+	}{
+		{"[][]int{{1, 2}, {3, 4}}", "[][]int{_{1, 2}, _{3, 4}}", 0},
+		{"[][][]int{{{1}}, {}}", "[][][]int{_{_{1}}, _{}}", 0},
+		{"map[string]struct{ x, y int }{\"a\": {1, 2}, \"b\": {y: 3}}", "map[string]struct{ x, y int }{\"a\": _{1, 2}, \"b\": _{y: 3}}", 2},
+		{"map[[2]int]string{{1, 2}: \"x\"}", "map[[2]int]string{_{1, 2}: \"x\"}", 1},
+		{"[...]int{2: 1, 0: 3, 4}", "[...]int{2: 1, 0: 3, 4}", 2},
+		{"[3]T{1: {x: 1}, {}}", "[3]T{1: _{x: 1}, _{}}", 1},
+		{"[]*T{{}, &T{}}", "[]*T{_{}, &T{}}", 0},
+	} {
+		src := "package p; var _ = " + test.src
+		f, err := Parse(nil, strings.NewReader(src), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		lit := f.DeclList[0].(*VarDecl).Values.(*CompositeLit)
+		if got := describe(lit); got != test.want {
+			t.Errorf("%s: got %s; want %s", test.src, got, test.want)
+		}
+		if lit.NKeys != test.nkeys {
+			t.Errorf("%s: got %d keys; want %d", test.src, lit.NKeys, test.nkeys)
+		}
+	}
+}
+
+func TestConstDecls(t *testing.T) {
+	const src = `package p
+
+const (
+	A Kind = iota
+	B
+	_
+	C
+)
+
+const (
+	a, b = iota, iota * 2
+	c, d
+	e, f = "e", 1 << iota
+)
+
+const x, y = 1, 2
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An elided type and value list is recorded as a nil Type and
+	// a nil Values; the previous spec's type and values are repeated
+	// by the type checker, not by the parser.
+	for i, want := range []struct {
+		names, typ, values string
+		group              int // index of the group, or -1
+	}{
+		{"A", "Kind", "iota", 0},
+		{"B", "", "", 0},
+		{"_", "", "", 0},
+		{"C", "", "", 0},
+		{"a, b", "", "iota, iota * 2", 1},
+		{"c, d", "", "", 1},
+		{"e, f", "", `"e", 1 << iota`, 1},
+		{"x, y", "", "1, 2", -1},
+	} {
+		d := f.DeclList[i].(*ConstDecl)
+		var names []string
+		for _, n := range d.NameList {
+			names = append(names, n.Value)
+		}
+		var typ, values string
+		if d.Type != nil {
+			typ = String(d.Type)
+		}
+		if d.Values != nil {
+			values = String(d.Values)
+		}
+		if got := strings.Join(names, ", "); got != want.names || typ != want.typ || values != want.values {
+			t.Errorf("spec %d: got %s %s = %s; want %s %s = %s", i, got, typ, values, want.names, want.typ, want.values)
+		}
+
+		group := -1
+		if d.Group != nil {
+			group = 0
+			if d.Group != f.DeclList[0].(*ConstDecl).Group {
+				group = 1
+			}
+		}
+		if group != want.group {
+			t.Errorf("spec %d: got group %d; want %d", i, group, want.group)
+		}
+	}
+}
+
+func TestAnonymousTypes(t *testing.T) {
+	const src = `package p
+
+var x struct{ A int }
+var y = []interface{ M() }{}
+var z struct {
+	T
+	*q.U
+	A, B int "tag"
+}
+var _ = struct{ io.Reader }{r}
+var _ = map[string]interface {
+	io.Reader
+	M(int) error
+}{}
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decls := f.DeclList
+
+	// var x struct{ A int }
+	st := decls[0].(*VarDecl).Type.(*StructType)
+	if len(st.FieldList) != 1 || st.FieldList[0].Name.Value != "A" {
+		t.Errorf("x: got %s; want struct{ A int }", String(st))
+	}
+
+	// []interface{ M() }{}
+	lit := decls[1].(*VarDecl).Values.(*CompositeLit)
+	it := lit.Type.(*SliceType).Elem.(*InterfaceType)
+	if len(it.MethodList) != 1 || it.MethodList[0].Name.Value != "M" {
+		t.Errorf("y: got %s; want []interface{ M() }{}", String(lit))
+	}
+
+	// embedded fields have no name
+	st = decls[2].(*VarDecl).Type.(*StructType)
+	var fields []string
+	for _, f := range st.FieldList {
+		name := "_"
+		if f.Name != nil {
+			name = f.Name.Value
+		}
+		fields = append(fields, name+" "+String(f.Type))
+	}
+	if got, want := strings.Join(fields, "; "), "_ T; _ *q.U; A int; B int"; got != want {
+		t.Errorf("z: got fields %s; want %s", got, want)
+	}
+	if len(st.TagList) != 4 || st.TagList[3] == nil || st.TagList[3].Value != `"tag"` {
+		t.Errorf("z: got tags %v; want tag for A and B", st.TagList)
+	}
+
+	// struct{ io.Reader }{r}
+	lit = decls[3].(*VarDecl).Values.(*CompositeLit)
+	st = lit.Type.(*StructType)
+	if len(st.FieldList) != 1 || st.FieldList[0].Name != nil || String(st.FieldList[0].Type) != "io.Reader" {
+		t.Errorf("got %s; want struct{ io.Reader }{r}", String(lit))
+	}
+
+	// map[string]interface{ io.Reader; M(int) error }{}
+	lit = decls[4].(*VarDecl).Values.(*CompositeLit)
+	it = lit.Type.(*MapType).Value.(*InterfaceType)
+	if len(it.MethodList) != 2 || it.MethodList[0].Name != nil || it.MethodList[1].Name.Value != "M" {
+		t.Errorf("got %s; want embedded io.Reader and method M", String(lit))
+	}
+}
+
+func TestCallStmts(t *testing.T) {
+	for _, src := range []string{
+		"go obj.Method()",
+		"defer f()",
+		"go a.b().c(x)",
+		"defer func() {}()",
+		"defer m[k](x)",
+		"go F[int]()",
+		"defer p.F[int](x)",
+	} {
+		f, err := Parse(nil, strings.NewReader("package p; func _() { "+src+" }"), nil, nil, nil, AllowGenerics)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		s := f.DeclList[0].(*FuncDecl).Body.List[0].(*CallStmt)
+		if got := s.Tok.String() + " " + String(s.Call); got != src {
+			t.Errorf("got %s; want %s", got, src)
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"defer x", "expression in defer must be function call"},
+		{"go obj.Method", "expression in go must be function call"},
+		{"defer m[k]", "expression in defer must be function call"},
+		{"go 1", "expression in go must be function call"},
+		{"defer (f())", "expression in defer must not be parenthesized"},
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestSelectStmts(t *testing.T) {
+	const src = `package p; func _() {
+	select {
+	case <-ch:
+	case v := <-ch:
+	case v, ok := <-ch:
+	case v = <-ch:
+	case ch <- x:
+	case (<-ch):
+	default:
+	}
+}`
+	f, err := Parse(nil, strings.NewReader(src), nil, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := f.DeclList[0].(*FuncDecl).Body.List[0].(*SelectStmt)
+
+	// describe each clause by its kind
+	var got []string
+	for _, c := range s.Body {
+		var kind string
+		switch comm := c.Comm.(type) {
+		case nil:
+			kind = "default"
+		case *SendStmt:
+			kind = "send " + String(comm.Chan) + " <- " + String(comm.Value)
+		case *ExprStmt:
+			kind = "recv " + String(comm.X)
+		case *AssignStmt:
+			op := "="
+			if comm.Op == Def {
+				op = ":="
+			}
+			kind = "recv " + String(comm.Lhs) + " " + op + " " + String(comm.Rhs)
+		default:
+			kind = fmt.Sprintf("unexpected %T", comm)
+		}
+		got = append(got, kind)
+	}
+	want := []string{
+		"recv <-ch",
+		"recv v := <-ch",
+		"recv v, ok := <-ch",
+		"recv v = <-ch",
+		"send ch <- x",
+		"recv (<-ch)",
+		"default",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got clauses\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"case x:", "1:32: select case must be receive, send or assign recv"},
+		{"case f():", "1:32: select case must be receive, send or assign recv"},
+		{"case x++:", "1:32: select case must be receive, send or assign recv"},
+		{"case x = y:", "1:32: select case must be receive, send or assign recv"},
+		{"case x += <-ch:", "1:32: select case must be receive, send or assign recv"},
+		{"case a, b := <-c, <-d:", "1:32: select case must be receive, send or assign recv"},
+		{"case x < y:", "1:32: select case must be receive, send or assign recv"},
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; func _() { select { "+test.src+" } }"), nil, nil, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestTypeSwitches(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		lhs   string // bound identifier, if any
+		x     string // guarded expression
+		cases []string
+	}{
+		{"switch x.(type) {}", "", "x", nil},
+		{"switch v := x.(type) { case int: }", "v", "x", []string{"int"}},
+		{"switch init(); f().(type) { case int, []byte, nil: default: }", "", "f()", []string{"int, []byte, nil", "default"}},
+		{"switch v := p.x.(type) { case int, error: case nil: }", "v", "p.x", []string{"int, error", "nil"}},
+	} {
+		f, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		s := f.DeclList[0].(*FuncDecl).Body.List[0].(*SwitchStmt)
+		g, ok := s.Tag.(*TypeSwitchGuard)
+		if !ok {
+			t.Errorf("%s: got tag %T; want *TypeSwitchGuard", test.src, s.Tag)
+			continue
+		}
+		var lhs string
+		if g.Lhs != nil {
+			lhs = g.Lhs.Value
+		}
+		if lhs != test.lhs {
+			t.Errorf("%s: got bound identifier %q; want %q", test.src, lhs, test.lhs)
+		}
+		if got := String(g.X); got != test.x {
+			t.Errorf("%s: got guarded expression %s; want %s", test.src, got, test.x)
+		}
+		var cases []string
+		for _, c := range s.Body {
+			if c.Cases == nil {
+				cases = append(cases, "default")
+			} else {
+				cases = append(cases, String(c.Cases))
+			}
+		}
+		if strings.Join(cases, "; ") != strings.Join(test.cases, "; ") {
+			t.Errorf("%s: got cases %q; want %q", test.src, cases, test.cases)
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"x := y.(type)", "1:29: use of .(type) outside type switch"},
+		{"_ = x.(type)", "1:28: use of .(type) outside type switch"},
+		{"switch f(x.(type)) {}", "1:33: use of .(type) outside type switch"},
+		{"switch x.(type) + 1 {}", "1:31: use of .(type) outside type switch"},
+		{"switch (x.(type)) {}", "1:32: use of .(type) outside type switch"},
+		{"switch v := x.(type); y {}", "1:36: use of .(type) outside type switch"},
+		{"if x.(type) {}", "1:27: use of .(type) outside type switch"},
+		{"for range x.(type) {}", "1:34: use of .(type) outside type switch"},
+		{"switch x.(type) { case y.(type): }", "1:47: use of .(type) outside type switch"},
+		{"switch a, b := x.(type) {}", "1:30: cannot assign 1 value to 2 variables"},
+		{"switch v = x.(type) {}", "v = x.(type) used as value"},
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestForStmts(t *testing.T) {
+	for _, test := range []struct {
+		src              string
+		init, cond, post string // clauses present, if any
+	}{
+		{"for {}", "", "", ""},
+		{"for x < n {}", "", "x < n", ""},
+		{"for i := 0; i < n; i++ {}", "i := 0", "i < n", "i++"},
+		{"for ; i < n; i++ {}", "", "i < n", "i++"},
+		{"for i := 0; i < n; {}", "i := 0", "i < n", ""},
+		{"for i := 0; ; i++ {}", "i := 0", "", "i++"},
+		{"for ;; {}", "", "", ""},
+		{"for ; ; f() {}", "", "", "f()"},
+	} {
+		f, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		s := f.DeclList[0].(*FuncDecl).Body.List[0].(*ForStmt)
+		for _, c := range []struct {
+			clause    string
+			got, want string
+		}{
+			{"init", clause(s.Init), test.init},
+			{"condition", clause(s.Cond), test.cond},
+			{"post", clause(s.Post), test.post},
+		} {
+			if c.got != c.want {
+				t.Errorf("%s: got %s %q; want %q", test.src, c.clause, c.got, c.want)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"for ;; ; {}", "1:30: syntax error: unexpected semicolon, expecting for loop post statement or {"},
+		{"for i := 0; i < n; ; i++ {}", "1:42: syntax error: unexpected semicolon, expecting for loop post statement or {"},
+		{"for ; {}", "1:29: syntax error: unexpected {, expecting for loop condition"},
+		{"for i := 0; i < n {}", "1:41: syntax error: unexpected {, expecting semicolon or newline"},
+		{"for i := 0; i < n; j := 1 {}", "1:44: syntax error: cannot declare in post statement of for loop"},
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestAssignStmts(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		op       Operator // 0 for plain assignments, Def for :=
+		lhs, rhs int      // number of variables and values
+	}{
+		{"x = 1", 0, 1, 1},
+		{"a, b = b, a", 0, 2, 2},
+		{"a, b = f()", 0, 2, 1},
+		{"v, ok = m[k]", 0, 2, 1},
+		{"x += 1", Add, 1, 1},
+		{"x -= y", Sub, 1, 1},
+		{"x *= 2", Mul, 1, 1},
+		{"x /= 2", Div, 1, 1},
+		{"x %= 2", Rem, 1, 1},
+		{"x &= m", And, 1, 1},
+		{"x |= m", Or, 1, 1},
+		{"x ^= m", Xor, 1, 1},
+		{"x <<= 2", Shl, 1, 1},
+		{"x >>= 2", Shr, 1, 1},
+		{"x &^= m", AndNot, 1, 1},
+		{"i, j, _ := 0, len(s), f()", Def, 3, 3},
+		{"x, err := f()", Def, 2, 1},
+		{"_, err := f()", Def, 2, 1},
+	} {
+		f, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		s, ok := f.DeclList[0].(*FuncDecl).Body.List[0].(*AssignStmt)
+		if !ok {
+			t.Errorf("%s: not parsed as an assignment", test.src)
+			continue
+		}
+		if s.Op != test.op {
+			t.Errorf("%s: got operator %s; want %s", test.src, s.Op, test.op)
+		}
+		if n := len(unpackList(s.Lhs)); n != test.lhs {
+			t.Errorf("%s: got %d variables; want %d", test.src, n, test.lhs)
+		}
+		if n := len(unpackList(s.Rhs)); n != test.rhs {
+			t.Errorf("%s: got %d values; want %d", test.src, n, test.rhs)
+		}
+	}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"a, b = 1, 2, 3", "1:28: cannot assign 3 values to 2 variables"},
+		{"x = 1, 2", "1:25: cannot assign 2 values to 1 variable"},
+		{"a, b, c := 1, 2", "1:31: cannot assign 2 values to 3 variables"},
+		{"_ := f()", "1:25: no new variables on left side of :="},
+		{"_, _ := 1, 2", "1:28: no new variables on left side of :="},
+		{"x.y := 1", "1:24: non-name x.y on left side of :="},
+		{"a, p[0] := 1, 2", "1:27: non-name p[0] on left side of :="},
+		{"a, b += 1", "1:28: syntax error: unexpected +=, expecting := or = or comma"},
+	} {
+		_, err := Parse(nil, strings.NewReader("package p; func _() { "+test.src+" }"), nil, nil, nil, AssignmentErrors)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v; want %s", test.src, err, test.err)
+		}
+	}
+
+	// the checks require AssignmentErrors
+	if _, err := Parse(nil, strings.NewReader("package p; func _() { a, b = 1, 2, 3; _ := 0 }"), nil, nil, nil, 0); err != nil {
+		t.Errorf("got error %v without AssignmentErrors", err)
+	}
+}
+
+// unpackList returns the elements of x if it is a list, or else x.
+func unpackList(x Expr) []Expr {
+	if l, ok := x.(*ListExpr); ok {
+		return l.ElemList
+	}
+	return []Expr{x}
+}
+
+// clause returns the source text of a statement clause, or "" if the
+// clause is absent.
+func clause(n Node) string {
+	if n == nil {
+		return ""
+	}
+	return String(n)
+}
+
+func TestUnreachableCode(t *testing.T) {
+	const src = `package p
+
+func _() {
+	return
+	x++ // unreachable
+	y++
+}
+
+func _() {
+L:
+	for {
+		switch {
+		case x:
+			break L
+			f()
+		case y:
+			fallthrough
+		default:
+			continue
+		}
+	}
+	goto M
+M: // target of goto
+	f()
+	panic("unreachable")
+	;
+	g()
+	if x {
+		os.Exit(1)
+		h()
+	}
+}
+`
+
+	var errs []string
+	ParseBytes(nil, []byte(src), func(err error) {
+		e := err.(Error)
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col(), e.Msg))
+	}, nil, nil, UnreachableCode)
+	want := []string{
+		// positions of simple statements are at the operator
+		"5:3: unreachable code",
+		"15:5: unreachable code",
+		"30:4: unreachable code", // inner block is reported first
+		"27:3: unreachable code",
+	}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("got %q\nwant %q", errs, want)
+	}
+
+	if _, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0); err != nil {
+		t.Errorf("got %v without UnreachableCode", err)
+	}
+}
+
+func TestGenericReceivers(t *testing.T) {
+	const src = `package p
+
+func (l *List[T]) Push(x T) {}
+func (m Map[K, V,]) Get(k K) V
+func (List[T]) Len() int
+func (a [N]int) Len() int
+func (s []T) Len() int
+func f(a [4]int, b *Map[K, []V])
+func g(List[T], Map[K, V])
+`
+
+	f, err := ParseBytes(nil, []byte(src), nil, nil, nil, AllowGenerics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range f.DeclList {
+		fun := d.(*FuncDecl)
+		params := fun.Type.ParamList
+		if fun.Recv != nil {
+			params = []*Field{fun.Recv}
+		}
+		for _, p := range params {
+			var name string
+			if p.Name != nil {
+				name = p.Name.Value + " "
+			}
+			got = append(got, fmt.Sprintf("%s%s (%T)", name, String(p.Type), p.Type))
+		}
+	}
+	want := []string{
+		"l *List[T] (*syntax.Operation)",
+		"m Map[K, V] (*syntax.IndexExpr)",
+		"List[T] (*syntax.IndexExpr)",
+		"a [N]int (*syntax.ArrayType)",
+		"s []T (*syntax.SliceType)",
+		"a [4]int (*syntax.ArrayType)",
+		"b *Map[K, []V] (*syntax.Operation)",
+		"List[T] (*syntax.IndexExpr)",
+		"Map[K, V] (*syntax.IndexExpr)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the receiver type parameter is captured
+	recv := f.DeclList[0].(*FuncDecl).Recv.Type.(*Operation).X.(*IndexExpr)
+	if x, ok := recv.X.(*Name); !ok || x.Value != "List" {
+		t.Errorf("got receiver base type %s; want List", String(recv.X))
+	}
+	if tpar, ok := recv.Index.(*Name); !ok || tpar.Value != "T" {
+		t.Errorf("got receiver type parameter %s; want T", String(recv.Index))
+	}
+}
+
+func TestGenericReceiverErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"func (l *List[T any]) Push(x T)", "1:17: receiver type parameter T cannot have a constraint"},
+		{"func (l List[K, V comparable]) m()", "1:19: receiver type parameter V cannot have a constraint"},
+		{"func (l List[*T]) m()", "1:14: receiver type parameter must be an identifier"},
+		{"func (l List[]) m()", "1:14: syntax error: unexpected ], expecting type argument"},
+	} {
+		_, err := ParseBytes(nil, []byte("package p; "+test.src), nil, nil, nil, AllowGenerics)
+		if err == nil {
+			t.Errorf("%s: no error", test.src)
+			continue
+		}
+		e := err.(Error)
+		if got := fmt.Sprintf("%d:%d: %s", e.Pos.Line(), e.Pos.Col()-uint(len("package p; ")), e.Msg); got != test.err {
+			t.Errorf("%s: got %s; want %s", test.src, got, test.err)
+		}
+	}
+
+	// type parameters require AllowGenerics
+	if _, err := ParseBytes(nil, []byte("package p; func (l *List[T]) Push(x T)"), nil, nil, nil, 0); err == nil {
+		t.Error("no error without AllowGenerics")
+	}
+}
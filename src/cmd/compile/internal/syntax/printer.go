@@ -628,7 +628,11 @@ func (p *printer) printRawNode(n Node) {
 		if n.Group == nil {
 			p.print(_Type, blank)
 		}
-		p.print(n.Name, blank)
+		p.print(n.Name)
+		if n.TParamList != nil {
+			p.printParameterList(n.TParamList, true)
+		}
+		p.print(blank)
 		if n.Alias {
 			p.print(_Assign, blank)
 		}
@@ -657,6 +661,9 @@ func (p *printer) printRawNode(n Node) {
 			p.print(_Rparen, blank)
 		}
 		p.print(n.Name)
+		if n.TParamList != nil {
+			p.printParameterList(n.TParamList, true)
+		}
 		p.printSignature(n.Type)
 		if n.Body != nil {
 			p.print(blank, n.Body)
@@ -840,19 +847,25 @@ func (p *printer) printDeclList(list []Decl) {
 }
 
 func (p *printer) printSignature(sig *FuncType) {
-	p.printParameterList(sig.ParamList)
+	p.printParameterList(sig.ParamList, false)
 	if list := sig.ResultList; list != nil {
 		p.print(blank)
 		if len(list) == 1 && list[0].Name == nil {
 			p.printNode(list[0].Type)
 		} else {
-			p.printParameterList(list)
+			p.printParameterList(list, false)
 		}
 	}
 }
 
-func (p *printer) printParameterList(list []*Field) {
-	p.print(_Lparen)
+// printParameterList prints a parenthesized parameter list,
+// or a bracketed type parameter list if tparams is set.
+func (p *printer) printParameterList(list []*Field, tparams bool) {
+	open, close := _Lparen, _Rparen
+	if tparams {
+		open, close = _Lbrack, _Rbrack
+	}
+	p.print(open)
 	if len(list) > 0 {
 		for i, f := range list {
 			if i > 0 {
@@ -871,7 +884,7 @@ func (p *printer) printParameterList(list []*Field) {
 			p.printNode(f.Type)
 		}
 	}
-	p.print(_Rparen)
+	p.print(close)
 }
 
 func (p *printer) printStmtList(list []Stmt, braces bool) {
@@ -39,3 +39,28 @@ func TestPrintString(t *testing.T) {
 		}
 	}
 }
+
+func TestPrintParens(t *testing.T) {
+	// Parenthesized expressions are represented by ParenExpr nodes,
+	// so the printer reproduces the parentheses in the source.
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"(a + b) * c", "(a + b) * c"},
+		{"a + b*c", "a + b * c"},
+		{"a + (b * c)", "a + (b * c)"},
+		{"((x))", "((x))"},
+		{"f((x), -(y))", "f((x), -(y))"},
+		{"(<-c)", "(<-c)"},
+	} {
+		src := "package p; var _ = " + test.src
+		ast, err := ParseBytes(nil, []byte(src), nil, nil, nil, 0)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := String(ast); got != "package p; var _ = "+test.want {
+			t.Errorf("%q: got %q", test.src, got)
+		}
+	}
+}
@@ -6,37 +6,401 @@
 // Go source. After initialization, consecutive calls of
 // next advance the scanner one token at a time.
 //
-// This file, source.go, and tokens.go are self-contained
-// (go tool compile scanner.go source.go tokens.go compiles)
-// and thus could be made into its own package.
+// This file, source.go, tokens.go, and constraint.go are
+// self-contained (go tool compile scanner.go source.go tokens.go
+// constraint.go compiles) and thus could be made into its own
+// package.
 
 package syntax
 
 import (
 	"fmt"
 	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
+// A scanMode controls the behavior of the scanner.
+// The modes may be combined.
+type scanMode uint
+
+const (
+	scanComments   scanMode = 1 << iota // report comments as _Comment tokens
+	scanWhitespace                      // report white space as _Whitespace tokens
+	packageFirst                        // report an error if the first token (other than comments and white space) is not 'package'
+	internNames                         // share the lit strings of identifiers with the same spelling
+	loneCR                              // report carriage returns not followed by a newline in white space and interpreted strings
+	semiCause                           // record the token that caused an automatic semicolon in semiTok and semiOp
+	tildeOp                             // report ~ as an operator (for type constraints)
+	lineEndings                         // record the line ending styles used (see mixedLineEndings)
+	keepLines                           // keep the source text (see lineText)
+	attachTrivia                        // attach the comments and white space preceding each token to it (see trivia)
+	highlight                           // report comments and classify each token for syntax highlighting (see class)
+)
+
 type scanner struct {
 	source
+	mode   scanMode
 	pragh  func(line, col uint, msg string)
 	nlsemi bool // if set '\n' and EOF translate to ';'
+	semi   bool // if set, the next token is a ';' for a multi-line comment (scanComments mode only)
+
+	// Optional handlers; they may be set after calling init.
+	mixh   func(line uint)                                 // if set, called for lines indented with both tabs and spaces
+	genh   func(line, col uint, cmd string, toplevel bool) // if set, called for each //go:generate directive
+	embedh func(line, col uint, patterns []string)         // if set, called for each //go:embed directive
+	caseh  func(line, col uint, ident, keyword string)     // if set, called for identifiers that differ from a keyword only in case
+	commh  func(line, col uint, text string)               // if set, called for each comment
+	blankh func(line, col uint)                            // if set, called for each blank identifier _
+	bigh   func(line, col uint, lit string)                // if set, called for integer literals that don't fit into a uint64
+	pkgh   func(line, col uint, name string)               // if set, called for the package name in the package clause
+	predh  func(line, col uint, name string)               // if set, called for predeclared identifiers (see IsPredeclared)
+	indh   func(line uint, indent []byte)                  // if set, called with the leading white space of each line (see indent)
+	octh   func(line, col uint, value byte)                // if set, called for octal escapes such as \101 in rune and string literals
+	declh  func(line, col uint, keyword string)            // if set, called for the keyword starting each top-level declaration
+	buildh func(line, col uint, x constraint)              // if set, called with the parsed expression of each //go:build directive
+	linth  func(line, col uint, linters []string)          // if set, called for each suppression comment such as //nolint (see lint)
+
+	// If set, only the //go: directives named in directives, such as
+	// "go:embed", are passed to pragh; //line directives are always
+	// passed. May be set after calling init.
+	directives []string
+
+	// The name of suppression comments reported to linth, "nolint"
+	// if empty; may be set after calling init.
+	lintPrefix string
+
+	// Additional reserved words; may be set after calling init. They are
+	// reported as _Reserved tokens, with the word in lit, instead of as
+	// names. Like most keywords, they don't cause semicolon insertion.
+	reserved map[string]bool
+
+	depth    int               // {} nesting level
+	pkgFirst bool              // first token not yet checked (packageFirst mode)
+	names    map[string]string // identifiers seen so far (internNames mode only)
+	lastLine uint              // source line at the end of the most recent significant token (see atLineStart)
+	pkgNext  bool              // the most recent significant token is the keyword package
+	prev     token             // the most recent significant token before the current one (see prevTok)
+	atSemi   bool              // the most recent significant token is a ';' (including automatic ones), or there is none
+
+	// comment statistics (see commentStats)
+	ncomm, commsize int
+
+	// indentation state (only maintained if mixh != nil or indh != nil)
+	inIndent         bool   // currently skipping the leading white space of a line
+	indTab, indSpace bool   // indentation seen so far contains tabs, spaces
+	indBuf           []byte // indentation seen so far (only maintained if indh != nil)
 
 	// current token, valid after calling next()
 	line, col uint
 	tok       token
-	lit       string   // valid if tok is _Name, _Literal, or _Semi ("semicolon", "newline", or "EOF")
+	lit       string   // valid if tok is _Name, _Literal, _Comment, _Whitespace, _Reserved, or _Semi ("semicolon", "newline", or "EOF")
 	kind      LitKind  // valid if tok is _Literal
 	op        Operator // valid if tok is _Operator, _AssignOp, or _IncOp
 	prec      int      // valid if tok is _Operator, _AssignOp, or _IncOp
+
+	// token preceding an automatically inserted semicolon; valid if tok
+	// is _Semi and lit is "newline" or "EOF" (semiCause mode only)
+	semiTok token
+	semiOp  Operator // valid if semiTok is _Operator, _AssignOp, or _IncOp
+
+	// comments and white space preceding the current token, in source
+	// order (attachTrivia mode only)
+	trivia []triviaRun
+
+	// highlighting category of the current token (highlight mode only)
+	class tokenClass
+}
+
+// A triviaRun is a comment or a run of white space.
+type triviaRun struct {
+	line, col uint
+	tok       token // _Comment or _Whitespace
+	lit       string
 }
 
-func (s *scanner) init(src io.Reader, errh, pragh func(line, col uint, msg string)) {
+// A tokenClass is the category of a token for syntax highlighting.
+// It is derived from the token alone: names are not classified further,
+// for instance as type or function names, since that requires semantic
+// analysis.
+type tokenClass uint8
+
+const (
+	classNone     tokenClass = iota // white space, automatically inserted semicolons, EOF
+	classKeyword                    // keywords and additional reserved words
+	className                       // identifiers, including predeclared ones
+	classString                     // string and rune literals
+	classNumber                     // integer, floating-point, and imaginary literals
+	classComment                    // comments
+	classOperator                   // operators, including assignment operators, <- and *
+	classPunct                      // parentheses, brackets, braces, and , ; : . ...
+)
+
+var classNames = [...]string{
+	classNone:     "none",
+	classKeyword:  "keyword",
+	className:     "name",
+	classString:   "string",
+	classNumber:   "number",
+	classComment:  "comment",
+	classOperator: "operator",
+	classPunct:    "punctuation",
+}
+
+func (c tokenClass) String() string { return classNames[c] }
+
+// classify returns the highlighting category of the current token.
+// It relies on the grouping of the token constants (see tokens.go).
+func (s *scanner) classify() tokenClass {
+	switch tok := s.tok; {
+	case tok == _Comment:
+		return classComment
+	case tok == _Name:
+		return className
+	case tok == _Literal:
+		if s.kind == StringLit || s.kind == RuneLit {
+			return classString
+		}
+		return classNumber
+	case _Operator <= tok && tok <= _Star:
+		return classOperator
+	case tok == _Semi && s.lit != "semicolon":
+		return classNone // not in the source
+	case _Lparen <= tok && tok <= _DotDotDot:
+		return classPunct
+	case _Break <= tok && tok <= _Reserved:
+		return classKeyword
+	}
+	return classNone
+}
+
+func (s *scanner) init(src io.Reader, errh, pragh func(line, col uint, msg string), mode scanMode) {
 	s.source.init(src, errh)
+	s.source.eols = mode&lineEndings != 0
+	s.source.keepSrc = mode&keepLines != 0
+	if mode&attachTrivia != 0 {
+		mode |= scanComments | scanWhitespace
+	}
+	if mode&highlight != 0 {
+		mode |= scanComments
+	}
+	s.mode = mode
 	s.pragh = pragh
 	s.nlsemi = false
+	s.semi = false
+	s.mixh = nil
+	s.genh = nil
+	s.embedh = nil
+	s.caseh = nil
+	s.commh = nil
+	s.blankh = nil
+	s.bigh = nil
+	s.pkgh = nil
+	s.predh = nil
+	s.indh = nil
+	s.octh = nil
+	s.declh = nil
+	s.buildh = nil
+	s.linth = nil
+	s.lintPrefix = ""
+	s.reserved = nil
+	s.directives = nil
+	s.depth = 0
+	s.pkgFirst = mode&packageFirst != 0
+	s.names = nil
+	if mode&internNames != 0 {
+		s.names = make(map[string]string)
+	}
+	s.inIndent = false
+	s.lastLine = 0
+	s.pkgNext = false
+	s.prev = 0
+	s.atSemi = true
+	s.ncomm, s.commsize = 0, 0
+	s.tok = 0 // no token yet
+	s.trivia = nil
+	s.class = classNone
+}
+
+// A checkpoint records the state of a scanner between two tokens
+// (see scanner.checkpoint).
+type checkpoint struct {
+	offs      int  // source offset
+	line, col uint // source position
+
+	// scanner state
+	nlsemi, semi, pkgFirst     bool
+	depth                      int
+	inIndent, indTab, indSpace bool
+	indent                     string // indBuf if inIndent is set
+	lastLine                   uint
+	pkgNext                    bool
+	prev                       token
+	atSemi                     bool
+	ncomm, commsize            int
+
+	// current token
+	tline, tcol uint
+	tok         token
+	lit         string
+	kind        LitKind
+	op          Operator
+	prec        int
+	semiTok     token
+	semiOp      Operator
+	trivia      []triviaRun
+	class       tokenClass
+}
+
+// checkpoint returns the current state of s, including the current
+// token, so that scanning can later continue from here by calling
+// restore, for instance to allow a parser to backtrack after looking
+// ahead. Until restore or release is called, s keeps the source bytes
+// read after the checkpoint; they are not read from src again. After
+// restore, the handlers are called again for the rescanned tokens.
+// Only the most recent checkpoint can be restored, and only once.
+func (s *scanner) checkpoint() checkpoint {
+	s.mark()
+	return checkpoint{
+		offs:     s.markOffset(),
+		line:     s.source.line,
+		col:      s.source.col,
+		nlsemi:   s.nlsemi,
+		semi:     s.semi,
+		pkgFirst: s.pkgFirst,
+		depth:    s.depth,
+		inIndent: s.inIndent,
+		indTab:   s.indTab,
+		indSpace: s.indSpace,
+		indent:   s.indentText(),
+		lastLine: s.lastLine,
+		pkgNext:  s.pkgNext,
+		prev:     s.prev,
+		atSemi:   s.atSemi,
+		ncomm:    s.ncomm,
+		commsize: s.commsize,
+		tline:    s.line,
+		tcol:     s.col,
+		tok:      s.tok,
+		lit:      s.lit,
+		kind:     s.kind,
+		op:       s.op,
+		prec:     s.prec,
+		semiTok:  s.semiTok,
+		semiOp:   s.semiOp,
+		trivia:   s.trivia,
+		class:    s.class,
+	}
+}
+
+// restore returns s to the state recorded by cp, which must be the
+// most recent checkpoint. The current token is the token at the time
+// of the checkpoint, but its text (see tokenText) is not available.
+func (s *scanner) restore(cp checkpoint) {
+	if cp.offs != s.markOffset() {
+		panic("restore of invalid checkpoint")
+	}
+	s.rewind()
+	s.source.line, s.source.col = cp.line, cp.col
+	s.nlsemi = cp.nlsemi
+	s.semi = cp.semi
+	s.pkgFirst = cp.pkgFirst
+	s.depth = cp.depth
+	s.inIndent = cp.inIndent
+	s.indTab = cp.indTab
+	s.indSpace = cp.indSpace
+	s.indBuf = append(s.indBuf[:0], cp.indent...)
+	s.lastLine = cp.lastLine
+	s.pkgNext = cp.pkgNext
+	s.prev = cp.prev
+	s.atSemi = cp.atSemi
+	s.ncomm, s.commsize = cp.ncomm, cp.commsize
+	s.line, s.col = cp.tline, cp.tcol
+	s.tok = cp.tok
+	s.lit = cp.lit
+	s.kind = cp.kind
+	s.op = cp.op
+	s.prec = cp.prec
+	s.semiTok = cp.semiTok
+	s.semiOp = cp.semiOp
+	s.trivia = cp.trivia
+	s.class = cp.class
+}
+
+// release discards the most recent checkpoint if it is not needed anymore.
+func (s *scanner) release() {
+	s.unmark()
+}
+
+// atLineStart reports whether the current token is the first
+// significant token on its line, that is, whether no other token
+// (ignoring comments, white space, and automatically inserted
+// semicolons) ends on the line where the current token starts.
+func (s *scanner) atLineStart() bool {
+	return s.line > s.lastLine
+}
+
+// lineText returns the text of the given source line, without the
+// terminating "\n" or "\r\n". The result is nil if the line has not
+// been read yet or is beyond the end of the source. It is only valid
+// in keepLines mode. Line numbers count physical lines; they are not
+// affected by //line directives.
+func (s *scanner) lineText(line uint) []byte {
+	// extend the index of line starts over the source read so far
+	if s.lineOffs == nil {
+		s.lineOffs = []int{0}
+	}
+	for i := s.indexed; i < len(s.srcBytes); i++ {
+		if s.srcBytes[i] == '\n' {
+			s.lineOffs = append(s.lineOffs, i+1)
+		}
+	}
+	s.indexed = len(s.srcBytes)
+
+	if line < linebase || line-linebase >= uint(len(s.lineOffs)) {
+		return nil
+	}
+	i := int(line - linebase)
+	start, end := s.lineOffs[i], len(s.srcBytes)
+	if i+1 < len(s.lineOffs) {
+		end = s.lineOffs[i+1] - 1 // exclude '\n'
+		if end > start && s.srcBytes[end-1] == '\r' {
+			end--
+		}
+	} else if start == end {
+		return nil // nothing read after the last newline
+	}
+	return s.srcBytes[start:end:end]
+}
+
+// mixedLineEndings reports whether the source scanned so far contains
+// lines ending in "\n" as well as lines ending in "\r\n", including
+// lines inside comments and string literals. It is only valid in
+// lineEndings mode, typically after scanning reaches EOF.
+func (s *scanner) mixedLineEndings() bool {
+	return s.lf && s.crlf
+}
+
+// prevTok returns the most recent significant token before the current
+// token, ignoring comments, white space, and automatically inserted
+// semicolons, or 0 if there is none. If the current token is an
+// automatically inserted semicolon, prevTok is the token that caused
+// its insertion.
+func (s *scanner) prevTok() token {
+	return s.prev
+}
+
+// tokenText returns the source text of the current token, exactly as
+// it appears in the source. Unlike lit, it is also set for operators
+// and delimiters, and it is empty for an EOF token (including a _Semi
+// token representing EOF). The result is only valid until the next
+// call of next.
+func (s *scanner) tokenText() []byte {
+	return s.source.text()
 }
 
 // next advances the scanner by reading the next token.
@@ -49,22 +413,123 @@ func (s *scanner) init(src io.Reader, errh, pragh func(line, col uint, msg strin
 // of a line, next calls the directive handler pragh installed
 // with init, if not nil.
 //
+// In packageFirst mode, next reports an error if the first
+// token other than comments and white space is not the keyword
+// package.
+//
+// In attachTrivia mode, next doesn't report comments and white
+// space as tokens; instead, they are collected in trivia, which
+// holds the comments and white space preceding the current token.
+// Together with tokenText, they allow reconstructing the source
+// exactly; the comments and white space at the end of the source
+// are attached to the _EOF token. A new trivia slice is allocated
+// for each token.
+//
+// In highlight mode, next sets class to the highlighting category
+// of the token. Comments are reported as tokens (unless attachTrivia
+// is set as well).
+//
 // The (line, col) position passed to the error and directive
 // handler is always at or after the current source reading
 // position.
 func (s *scanner) next() {
+	if s.mode&attachTrivia == 0 {
+		s.next0()
+	} else {
+		s.trivia = nil
+		s.next0()
+		for s.tok == _Comment || s.tok == _Whitespace {
+			s.trivia = append(s.trivia, triviaRun{s.line, s.col, s.tok, s.lit})
+			s.next0()
+		}
+	}
+	if s.mode&highlight != 0 {
+		s.class = s.classify()
+	}
+}
+
+// next0 is like next but reports comments and white space as tokens
+// in scanComments and scanWhitespace mode, independent of attachTrivia.
+func (s *scanner) next0() {
+	if s.pkgFirst {
+		s.pkgFirst = false
+		s.next0()
+		if s.tok == _Comment || s.tok == _Whitespace {
+			s.pkgFirst = true // check next token
+			return
+		}
+		if s.tok != _Package {
+			found := s.lit
+			if s.tok != _Name && s.tok != _Literal && s.tok != _Semi {
+				found = s.tok.String()
+			}
+			s.errh(s.line, s.col, "expected 'package', found "+found)
+		}
+		return
+	}
+
+	switch s.tok {
+	case 0, _Comment, _Whitespace:
+		// no token yet, or not significant
+	case _Semi:
+		if s.lit == "semicolon" {
+			s.lastLine = s.source.line
+			s.pkgNext = false
+			s.prev = _Semi
+		}
+		s.atSemi = true
+	default:
+		s.lastLine = s.source.line
+		s.pkgNext = s.tok == _Package
+		s.prev = s.tok
+		s.atSemi = false
+	}
+
+	if s.nlsemi && s.mode&semiCause != 0 && s.tok != _Comment && s.tok != _Whitespace {
+		// s.tok may cause the next ';' (comments and white space
+		// don't change nlsemi)
+		s.semiTok, s.semiOp = s.tok, s.op
+	}
+
+	if s.semi {
+		// ';' for the preceding multi-line comment
+		s.semi = false
+		s.line, s.col = s.source.line, s.source.col
+		s.startText()
+		s.txtpos = s.r // empty; startText includes the comment's final '/'
+		s.lit = "newline"
+		s.tok = _Semi
+		return
+	}
+
 	nlsemi := s.nlsemi
 	s.nlsemi = false
 
 redo:
-	// skip white space
 	c := s.getr()
+	if s.mode&scanWhitespace != 0 && (c == ' ' || c == '\t' || c == '\n' && !nlsemi || c == '\r') {
+		s.whitespace(c, nlsemi)
+		s.nlsemi = nlsemi
+		return
+	}
+
+	// skip white space
 	for c == ' ' || c == '\t' || c == '\n' && !nlsemi || c == '\r' {
+		if s.mixh != nil || s.indh != nil {
+			s.indent(c)
+		}
+		if c == '\r' && s.mode&loneCR != 0 {
+			s.checkCR()
+		}
 		c = s.getr()
 	}
+	if s.mixh != nil || s.indh != nil {
+		s.indent(c)
+	}
 
 	// token start
 	s.line, s.col = s.source.line0, s.source.col0
+	s.startText()
 
 	if isLetter(c) || c >= utf8.RuneSelf && s.isIdentRune(c, true) {
 		s.ident()
@@ -103,6 +568,7 @@ redo:
 		s.tok = _Lbrack
 
 	case '{':
+		s.depth++
 		s.tok = _Lbrace
 
 	case ',':
@@ -121,6 +587,7 @@ redo:
 		s.tok = _Rbrack
 
 	case '}':
+		s.depth--
 		s.nlsemi = true
 		s.tok = _Rbrace
 
@@ -146,6 +613,17 @@ redo:
 				break
 			}
 			s.ungetr2()
+			if isDigit(c) {
+				// . followed by the literal .d
+				s.ungetr()
+				s.tok = _Dot
+				break
+			}
+			// .. is not a token; report it once and treat it
+			// as a single . to avoid follow-on syntax errors
+			s.error("invalid token ..")
+			s.tok = _Dot
+			break
 		}
 		s.ungetr()
 		s.tok = _Dot
@@ -182,10 +660,27 @@ redo:
 		c = s.getr()
 		if c == '/' {
 			s.lineComment()
+			s.gotComment()
+			if s.mode&scanComments != 0 {
+				s.comment()
+				s.nlsemi = nlsemi // the comment doesn't include the '\n'
+				break
+			}
 			goto redo
 		}
 		if c == '*' {
 			s.fullComment()
+			s.gotComment()
+			if s.mode&scanComments != 0 {
+				s.comment()
+				if s.source.line > s.line && nlsemi {
+					// A multi-line comment acts like a newline.
+					s.semi = true
+				} else {
+					s.nlsemi = nlsemi
+				}
+				break
+			}
 			if s.source.line > s.line && nlsemi {
 				// A multi-line comment acts like a newline;
 				// it translates to a ';' if nlsemi is set.
@@ -228,6 +723,11 @@ redo:
 		goto assignop
 
 	case '~':
+		if s.mode&tildeOp != 0 {
+			s.op, s.prec = Tilde, 0
+			s.tok = _Operator
+			break
+		}
 		s.error("bitwise complement operator is ^")
 		fallthrough
 
@@ -293,7 +793,7 @@ redo:
 
 	default:
 		s.tok = 0
-		s.error(fmt.Sprintf("invalid character %#U", c))
+		s.error(fmt.Sprintf("invalid character %#U at offset %d", c, s.offset0()))
 		goto redo
 	}
 
@@ -308,6 +808,163 @@ assignop:
 	s.tok = _Operator
 }
 
+// nextSignificant is like next but skips _Comment and _Whitespace
+// tokens, so that it advances to the next token that matters to a
+// parser independent of the scanner mode. Handlers such as commh are
+// still called for the skipped comments.
+func (s *scanner) nextSignificant() {
+	s.next()
+	for s.tok == _Comment || s.tok == _Whitespace {
+		s.next()
+	}
+}
+
+// whitespace scans a run of white space starting with c
+// and reports it as a _Whitespace token. If nlsemi is set,
+// a newline ends the run since it translates to a ';'.
+func (s *scanner) whitespace(c rune, nlsemi bool) {
+	s.line, s.col = s.source.line0, s.source.col0
+	s.startText()
+	for c == ' ' || c == '\t' || c == '\n' && !nlsemi || c == '\r' {
+		if s.mixh != nil || s.indh != nil {
+			s.indent(c)
+		}
+		if c == '\r' && s.mode&loneCR != 0 {
+			s.checkCR()
+		}
+		c = s.getr()
+	}
+	s.ungetr()
+	s.lit = string(s.text())
+	s.tok = _Whitespace
+}
+
+// gotComment is called for each comment after it has been scanned.
+// It reports suppression comments to linth (see lint), and it updates
+// the comment statistics and calls commh. Comments are only recorded
+// in scanComments mode or if commh is set.
+func (s *scanner) gotComment() {
+	if s.linth != nil {
+		s.lint(s.text())
+	}
+	if s.mode&scanComments == 0 && s.commh == nil {
+		return
+	}
+	text := s.text()
+	s.ncomm++
+	s.commsize += len(text)
+	if s.commh != nil {
+		s.commh(s.line, s.col, string(text))
+	}
+}
+
+// lint calls linth if text is the text of a suppression comment, that
+// is, a line comment starting with "//nolint" (or lintPrefix, if set)
+// optionally followed by a colon and a comma-separated list of linter
+// names, as in "//nolint:govet,staticcheck". The rest of the comment,
+// after white space, is ignored. linth receives the linter names, or
+// nil if there are none; they are not interpreted.
+func (s *scanner) lint(text []byte) {
+	prefix := s.lintPrefix
+	if prefix == "" {
+		prefix = "nolint"
+	}
+	if len(text) < 2+len(prefix) || text[1] != '/' || string(text[2:2+len(prefix)]) != prefix {
+		return
+	}
+	rest := string(text[2+len(prefix):])
+	if i := strings.IndexAny(rest, " \t\r"); i >= 0 {
+		rest = rest[:i]
+	}
+	var linters []string
+	switch {
+	case rest == "":
+		// no linter names
+	case rest[0] == ':':
+		for _, name := range strings.Split(rest[1:], ",") {
+			if name != "" {
+				linters = append(linters, name)
+			}
+		}
+	default:
+		return // some other comment, e.g. //nolintx
+	}
+	s.linth(s.line, s.col, linters)
+}
+
+// commentStats returns the number of comments scanned so far and
+// their total size in bytes, including the comment delimiters but
+// not the newline ending a line comment. The statistics are only
+// collected in scanComments mode or if commh is set.
+func (s *scanner) commentStats() (count, bytes int) {
+	return s.ncomm, s.commsize
+}
+
+// comment reports the comment just scanned as a _Comment token.
+func (s *scanner) comment() {
+	s.lit = string(s.text())
+	s.tok = _Comment
+}
+
+// indent tracks the leading white space of each source line and
+// calls mixh for lines whose indentation mixes tabs and spaces.
+// It also calls indh with the leading white space of each line
+// starting outside of a comment or string literal; for blank
+// lines, the white space is empty. The white space passed to indh
+// is only valid during the call.
+// c is the most recently read character; indent must be called
+// for each white space character skipped by next, and for the
+// character terminating a white space run.
+func (s *scanner) indent(c rune) {
+	switch c {
+	case ' ', '\t':
+		if s.col0 == colbase {
+			// start of line
+			s.inIndent = true
+			s.indTab, s.indSpace = false, false
+			s.indBuf = s.indBuf[:0]
+		}
+		if s.inIndent {
+			if c == '\t' {
+				s.indTab = true
+			} else {
+				s.indSpace = true
+			}
+			if s.indh != nil {
+				s.indBuf = append(s.indBuf, byte(c))
+			}
+		}
+	case '\n', '\r', -1:
+		// blank line or end of file
+		if s.indh != nil && (s.inIndent || c != -1 && s.col0 == colbase) {
+			s.indh(s.line0, nil)
+		}
+		s.inIndent = false
+	default:
+		// first character of line after indentation
+		if s.inIndent && s.indTab && s.indSpace && s.mixh != nil {
+			s.mixh(s.line0)
+		}
+		if s.indh != nil {
+			if s.inIndent {
+				s.indh(s.line0, s.indBuf)
+			} else if s.col0 == colbase {
+				s.indh(s.line0, nil) // not indented
+			}
+		}
+		s.inIndent = false
+	}
+}
+
+// indentText returns the indentation seen so far on the current line
+// as a string (see checkpoint).
+func (s *scanner) indentText() string {
+	if !s.inIndent || len(s.indBuf) == 0 {
+		return ""
+	}
+	return string(s.indBuf)
+}
+
 func isLetter(c rune) bool {
 	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
 }
@@ -334,19 +991,83 @@ func (s *scanner) ident() {
 	s.ungetr()
 
 	lit := s.stopLit()
+	// A truncated name (see maxLit) is a name even if its
+	// prefix spells a keyword or a special name.
+	truncated := s.big
 
 	// possibly a keyword
-	if len(lit) >= 2 {
+	if len(lit) >= 2 && !truncated {
 		if tok := keywordMap[hash(lit)]; tok != 0 && tokstrings[tok] == string(lit) {
 			s.nlsemi = contains(1<<_Break|1<<_Continue|1<<_Fallthrough|1<<_Return, tok)
 			s.tok = tok
+			if s.declh != nil && s.depth == 0 && s.atSemi && contains(1<<_Const|1<<_Func|1<<_Import|1<<_Type|1<<_Var, tok) {
+				// A top-level declaration starts after a ';' outside
+				// of braces; this excludes function literals and types
+				// and the names declared in grouped declarations.
+				s.declh(s.line, s.col, tokstrings[tok])
+			}
 			return
 		}
 	}
 
+	if !truncated && s.reserved[string(lit)] { // no allocation for the lookup
+		s.nlsemi = false
+		s.lit = string(lit)
+		s.tok = _Reserved
+		return
+	}
+
+	if s.caseh != nil {
+		s.checkCase(lit)
+	}
+	if s.blankh != nil && !truncated && len(lit) == 1 && lit[0] == '_' {
+		s.blankh(s.line, s.col)
+	}
+
 	s.nlsemi = true
-	s.lit = string(lit)
+	s.lit = s.name(lit)
 	s.tok = _Name
+
+	if s.pkgNext && s.pkgh != nil {
+		s.pkgh(s.line, s.col, s.lit)
+	}
+	if s.predh != nil && !truncated && predeclared[s.lit] {
+		s.predh(s.line, s.col, s.lit)
+	}
+}
+
+// name returns the identifier lit as a string. In internNames mode,
+// identifiers with the same spelling share the same string, which
+// avoids an allocation for each repeated occurrence.
+func (s *scanner) name(lit []byte) string {
+	if s.names == nil {
+		return string(lit)
+	}
+	if name, ok := s.names[string(lit)]; ok { // no allocation for the lookup
+		return name
+	}
+	name := string(lit)
+	s.names[name] = name
+	return name
+}
+
+// checkCase calls caseh if the identifier lit differs from a keyword
+// only in letter case (such as Func or IF), which often is a typo.
+func (s *scanner) checkCase(lit []byte) {
+	var buf [len("fallthrough")]byte // longest keyword
+	if len(lit) < 2 || len(lit) > len(buf) {
+		return
+	}
+	low := buf[:len(lit)]
+	for i, b := range lit {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		low[i] = b
+	}
+	if tok := keywordMap[hash(low)]; tok != 0 && tokstrings[tok] == string(low) {
+		s.caseh(s.line, s.col, string(lit), tokstrings[tok])
+	}
 }
 
 func (s *scanner) isIdentRune(c rune, first bool) bool {
@@ -355,10 +1076,10 @@ func (s *scanner) isIdentRune(c rune, first bool) bool {
 		// ok
 	case unicode.IsDigit(c):
 		if first {
-			s.error(fmt.Sprintf("identifier cannot begin with digit %#U", c))
+			s.error(fmt.Sprintf("identifier cannot begin with digit %#U at offset %d", c, s.offset0()))
 		}
 	case c >= utf8.RuneSelf:
-		s.error(fmt.Sprintf("invalid identifier character %#U", c))
+		s.error(fmt.Sprintf("invalid identifier character %#U at offset %d", c, s.offset0()))
 	default:
 		return false
 	}
@@ -464,6 +1185,66 @@ done:
 	s.nlsemi = true
 	s.lit = string(s.stopLit())
 	s.tok = _Literal
+
+	if s.bigh != nil && s.kind == IntLit {
+		if _, err := strconv.ParseUint(s.lit, 0, 64); err != nil && err.(*strconv.NumError).Err == strconv.ErrRange {
+			s.bigh(s.line, s.col, s.lit)
+		}
+	}
+}
+
+// intVal returns the value of the current token, which must be
+// an integer literal (s.tok == _Literal && s.kind == IntLit).
+// The result is nil if the literal is malformed.
+func (s *scanner) intVal() *big.Int {
+	x, ok := new(big.Int).SetString(s.lit, 0) // handles 0x and octal prefixes
+	if !ok {
+		return nil
+	}
+	return x
+}
+
+// floatVal returns the float64 value closest to the value of the
+// current token, which must be a floating-point or imaginary literal
+// (s.tok == _Literal && s.kind == FloatLit or ImagLit); for imaginary
+// literals, the value is the imaginary part. The result is ±Inf if the
+// value overflows a float64. exact reports whether the result is
+// exactly the value of the literal.
+func (s *scanner) floatVal() (x float64, exact bool) {
+	lit := s.lit
+	if s.kind == ImagLit {
+		lit = lit[:len(lit)-1] // strip 'i'
+	}
+
+	x, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return x, false // malformed or overflow
+	}
+
+	// split lit into mantissa and exponent
+	mant, exp := lit, 0
+	if i := strings.IndexAny(lit, "eE"); i >= 0 {
+		mant = lit[:i]
+		exp, err = strconv.Atoi(lit[i+1:])
+		if err != nil {
+			exp = math.MaxInt32 // exponent out of range
+		}
+	}
+	if strings.Trim(mant, "0.") == "" {
+		return x, true // value is 0
+	}
+
+	// Beyond this exponent range, a non-zero decimal value
+	// cannot be represented exactly (it overflowed above, or
+	// underflows); don't bother computing the exact value.
+	if exp < -1100 || exp > 1100 {
+		return x, false
+	}
+	r, ok := new(big.Rat).SetString(lit)
+	if !ok {
+		return x, false
+	}
+	return x, new(big.Rat).SetFloat64(x).Cmp(r) == 0
 }
 
 func (s *scanner) rune() {
@@ -513,6 +1294,16 @@ func (s *scanner) rune() {
 	s.tok = _Literal
 }
 
+// checkCR reports an error if the '\r' just read is not followed by a '\n'.
+// It must be followed by a call of getr.
+func (s *scanner) checkCR() {
+	line, col := s.source.line0, s.source.col0
+	if s.getr() != '\n' {
+		s.errh(line, col, "carriage return not followed by newline")
+	}
+	s.ungetr()
+}
+
 func (s *scanner) stdString() {
 	s.startLit()
 
@@ -534,6 +1325,9 @@ func (s *scanner) stdString() {
 			s.errh(s.line, s.col, "string not terminated")
 			break
 		}
+		if r == '\r' && s.mode&loneCR != 0 {
+			s.checkCR()
+		}
 	}
 
 	s.nlsemi = true
@@ -578,11 +1372,11 @@ func (s *scanner) skipLine(r rune) {
 func (s *scanner) lineComment() {
 	r := s.getr()
 	// directives must start at the beginning of the line (s.col == colbase)
-	if s.col != colbase || s.pragh == nil || (r != 'g' && r != 'l') {
+	if s.col != colbase || s.pragh == nil && s.genh == nil && s.embedh == nil && s.buildh == nil || (r != 'g' && r != 'l') {
 		s.skipLine(r)
 		return
 	}
-	// s.col == colbase && s.pragh != nil && (r == 'g' || r == 'l')
+	// s.col == colbase && (s.pragh != nil || s.genh != nil || s.embedh != nil || s.buildh != nil) && (r == 'g' || r == 'l')
 
 	// recognize directives
 	prefix := "go:"
@@ -605,7 +1399,125 @@ func (s *scanner) lineComment() {
 		text = text[:i]
 	}
 
-	s.pragh(s.line, s.col+2, prefix+string(text)) // +2 since directive text starts after //
+	if s.genh != nil && prefix == "go:" {
+		s.generate(text)
+	}
+	if s.embedh != nil && prefix == "go:" {
+		s.embed(text)
+	}
+	if s.buildh != nil && prefix == "go:" {
+		s.build(text)
+	}
+	if s.pragh != nil && (prefix != "go:" || s.wantDirective(text)) {
+		s.pragh(s.line, s.col+2, prefix+string(text)) // +2 since directive text starts after //
+	}
+}
+
+// wantDirective reports whether the //go: directive with the given
+// text (excluding the leading "//go:") is to be passed to pragh.
+func (s *scanner) wantDirective(text []byte) bool {
+	if len(s.directives) == 0 {
+		return true
+	}
+	for _, d := range s.directives {
+		name := strings.TrimPrefix(d, "go:")
+		if len(text) >= len(name) && string(text[:len(name)]) == name &&
+			(len(text) == len(name) || text[len(name)] == ' ' || text[len(name)] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+// generate calls genh if text is the text of a //go:generate
+// directive (excluding the leading "//go:"). The command is
+// reported verbatim, without interpretation.
+func (s *scanner) generate(text []byte) {
+	const prefix = "generate"
+	if len(text) < len(prefix) || string(text[:len(prefix)]) != prefix {
+		return
+	}
+	cmd := text[len(prefix):]
+	if len(cmd) > 0 && cmd[0] != ' ' && cmd[0] != '\t' {
+		return // some other directive, e.g. //go:generated
+	}
+	for len(cmd) > 0 && (cmd[0] == ' ' || cmd[0] == '\t') {
+		cmd = cmd[1:]
+	}
+	s.genh(s.line, s.col, string(cmd), s.depth <= 0)
+}
+
+// embed calls embedh if text is the text of a //go:embed directive
+// (excluding the leading "//go:"). The patterns are separated by
+// white space; a pattern containing spaces may be written as a Go
+// string literal ("..." or `...`). The patterns are not validated.
+func (s *scanner) embed(text []byte) {
+	const prefix = "embed"
+	if len(text) < len(prefix) || string(text[:len(prefix)]) != prefix {
+		return
+	}
+	args := string(text[len(prefix):])
+	if len(args) > 0 && args[0] != ' ' && args[0] != '\t' {
+		return // some other directive
+	}
+
+	var patterns []string
+	for args = strings.TrimLeft(args, " \t"); args != ""; args = strings.TrimLeft(args, " \t") {
+		var pattern string
+		switch args[0] {
+		case '"', '`':
+			// find the end of the literal
+			i := 1
+			for ; i < len(args) && args[i] != args[0]; i++ {
+				if args[0] == '"' && args[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(args) {
+				s.errh(s.line, s.col, "invalid quoted string in //go:embed: "+args)
+				return
+			}
+			var err error
+			if pattern, err = strconv.Unquote(args[:i+1]); err != nil {
+				s.errh(s.line, s.col, "invalid quoted string in //go:embed: "+args[:i+1])
+				return
+			}
+			args = args[i+1:]
+			if args != "" && args[0] != ' ' && args[0] != '\t' {
+				s.errh(s.line, s.col, "invalid quoted string in //go:embed: "+args)
+				return
+			}
+		default:
+			i := strings.IndexAny(args, " \t")
+			if i < 0 {
+				i = len(args)
+			}
+			pattern, args = args[:i], args[i:]
+		}
+		patterns = append(patterns, pattern)
+	}
+	s.embedh(s.line, s.col, patterns)
+}
+
+// build calls buildh if text is the text of a //go:build directive
+// (excluding the leading "//go:"). Invalid expressions are reported
+// to errh instead. It is up to the caller to check that the directive
+// precedes the package clause, as required for build constraints.
+func (s *scanner) build(text []byte) {
+	const prefix = "build"
+	if len(text) < len(prefix) || string(text[:len(prefix)]) != prefix {
+		return
+	}
+	expr := string(text[len(prefix):])
+	if len(expr) > 0 && expr[0] != ' ' && expr[0] != '\t' {
+		return // some other directive, e.g. //go:buildx
+	}
+	x, err := parseConstraint(expr)
+	if err != nil {
+		s.errh(s.line, s.col, "invalid //go:build line: "+err.Error())
+		return
+	}
+	s.buildh(s.line, s.col, x)
 }
 
 func (s *scanner) fullComment() {
@@ -629,6 +1541,7 @@ func (s *scanner) escape(quote rune) bool {
 	var base, max uint32
 
 	c := s.getr()
+	line, col := s.line0, s.col0-1 // position of \
 	switch c {
 	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', quote:
 		return true
@@ -680,9 +1593,14 @@ func (s *scanner) escape(quote rune) bool {
 	}
 	s.ungetr()
 
-	if x > max && base == 8 {
-		s.error(fmt.Sprintf("octal escape value > 255: %d", x))
-		return false
+	if base == 8 {
+		if x > max {
+			s.error(fmt.Sprintf("octal escape value > 255: %d", x))
+			return false
+		}
+		if s.octh != nil {
+			s.octh(line, col, byte(x))
+		}
 	}
 
 	if x > max || 0xD800 <= x && x < 0xE000 /* surrogate range */ {
@@ -5,10 +5,16 @@
 package syntax
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestScanner(t *testing.T) {
@@ -23,7 +29,7 @@ func TestScanner(t *testing.T) {
 	defer src.Close()
 
 	var s scanner
-	s.init(src, nil, nil)
+	s.init(src, nil, nil, 0)
 	for {
 		s.next()
 		if s.tok == _EOF {
@@ -52,7 +58,7 @@ func TestTokens(t *testing.T) {
 
 	// scan source
 	var got scanner
-	got.init(&bytesReader{buf}, nil, nil)
+	got.init(&bytesReader{buf}, nil, nil, 0)
 	got.next()
 	for i, want := range sampleTokens {
 		nlsemi := false
@@ -277,13 +283,19 @@ func TestScanErrors(t *testing.T) {
 		{"foo\n\n\xff    ", "invalid UTF-8 encoding", 2, 0},
 
 		// token-level errors
-		{"\u00BD" /* ½ */, "invalid identifier character U+00BD '½'", 0, 0},
-		{"\U0001d736\U0001d737\U0001d738_½" /* 𝜶𝜷𝜸_½ */, "invalid identifier character U+00BD '½'", 0, 13 /* byte offset */},
-		{"\U0001d7d8" /* 𝟘 */, "identifier cannot begin with digit U+1D7D8 '𝟘'", 0, 0},
-		{"foo\U0001d7d8_½" /* foo𝟘_½ */, "invalid identifier character U+00BD '½'", 0, 8 /* byte offset */},
+		{"\u00BD" /* ½ */, "invalid identifier character U+00BD '½' at offset 0", 0, 0},
+		{"\U0001d736\U0001d737\U0001d738_½" /* 𝜶𝜷𝜸_½ */, "invalid identifier character U+00BD '½' at offset 13", 0, 13 /* byte offset */},
+		{"\U0001d7d8" /* 𝟘 */, "identifier cannot begin with digit U+1D7D8 '𝟘' at offset 0", 0, 0},
+		{"foo\U0001d7d8_½" /* foo𝟘_½ */, "invalid identifier character U+00BD '½' at offset 8", 0, 8 /* byte offset */},
 
 		{"x + ~y", "bitwise complement operator is ^", 0, 4},
-		{"foo$bar = 0", "invalid character U+0024 '$'", 0, 3},
+		{"a..b", "invalid token ..", 0, 2},
+		{"foo$bar = 0", "invalid character U+0024 '$' at offset 3", 0, 3},
+		{"x = \a", "invalid character U+0007 at offset 4", 0, 4},      // control characters are not printed
+		{"x = \x1b[0m", "invalid character U+001B at offset 4", 0, 4}, // nor are escape sequences
+		{"x := 1\ny = \a", "invalid character U+0007 at offset 11", 1, 4},
+		{"a := 'ä'\nx §= 1", "invalid identifier character U+00A7 '§' at offset 12", 1, 2},
+		{"\U0001F600", "invalid identifier character U+1F600 '😀' at offset 0", 0, 0},
 		{"const x = 0xyz", "malformed hex constant", 0, 12},
 		{"0123456789", "malformed octal constant", 0, 10},
 		{"0123456789. /* foobar", "comment not terminated", 0, 12},   // valid float constant
@@ -354,7 +366,7 @@ func TestScanErrors(t *testing.T) {
 				// TODO(gri) make this use position info
 				t.Errorf("%q: got unexpected %q at line = %d", test.src, msg, line)
 			}
-		}, nil)
+		}, nil, 0)
 
 		for {
 			s.next()
@@ -373,10 +385,1942 @@ func TestIssue21938(t *testing.T) {
 	s := "/*" + strings.Repeat(" ", 4089) + "*/ .5"
 
 	var got scanner
-	got.init(strings.NewReader(s), nil, nil)
+	got.init(strings.NewReader(s), nil, nil, 0)
 	got.next()
 
 	if got.tok != _Literal || got.lit != ".5" {
 		t.Errorf("got %s %q; want %s %q", got.tok, got.lit, _Literal, ".5")
 	}
 }
+
+func TestMixedIndentation(t *testing.T) {
+	const src = "package p\n" +
+		"\tvar a int\n" + // tabs only
+		"    var b int\n" + // spaces only
+		"\t  var c int\n" + // mixed
+		" \t\n" + // mixed but blank
+		"x := 1 \t+ 2\n" + // not indentation
+		"  \tvar d int" // mixed, at EOF
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	var got []uint
+	s.mixh = func(line uint) {
+		got = append(got, line)
+	}
+	for {
+		s.next()
+		if s.tok == _EOF {
+			break
+		}
+	}
+
+	if want := []uint{4, 7}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got lines %v; want %v", got, want)
+	}
+}
+
+func TestLiteralValues(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		kind  LitKind
+		val   string // integer value, or float value formatted with %g
+		exact bool   // for floats
+	}{
+		{"0", IntLit, "0", false},
+		{"12345", IntLit, "12345", false},
+		{"0755", IntLit, "493", false},
+		{"0xcafebabe", IntLit, "3405691582", false},
+		{"0XCAFEBABE", IntLit, "3405691582", false},
+		{"123456789012345678901234567890", IntLit, "123456789012345678901234567890", false},
+
+		{"0.", FloatLit, "0", true},
+		{"0.e-1000", FloatLit, "0", true},
+		{"0.5", FloatLit, "0.5", true},
+		{".25e1", FloatLit, "2.5", true},
+		{"0.1", FloatLit, "0.1", false},
+		{"3.14159265", FloatLit, "3.14159265", false},
+		{"1e308", FloatLit, "1e+308", false},
+		{"1e400", FloatLit, "+Inf", false},
+		{"1e-400", FloatLit, "0", false},
+		{"1e+12345678901234567890", FloatLit, "+Inf", false},
+
+		{"2i", ImagLit, "2", true},
+		{"0123i", ImagLit, "123", true}, // decimal, not octal
+		{"0.1i", ImagLit, "0.1", false},
+	} {
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			t.Errorf("%s: %s", test.src, msg)
+		}, nil, 0)
+		s.next()
+		if s.tok != _Literal || s.kind != test.kind {
+			t.Errorf("%s: got %s (kind %d); want literal (kind %d)", test.src, s.tok, s.kind, test.kind)
+			continue
+		}
+
+		if s.kind == IntLit {
+			if got := s.intVal(); got == nil || got.String() != test.val {
+				t.Errorf("%s: got %v; want %s", test.src, got, test.val)
+			}
+			continue
+		}
+
+		got, exact := s.floatVal()
+		if fmt.Sprintf("%g", got) != test.val || exact != test.exact {
+			t.Errorf("%s: got %g (exact = %v); want %s (exact = %v)", test.src, got, exact, test.val, test.exact)
+		}
+	}
+}
+
+func TestGenerateDirectives(t *testing.T) {
+	const src = `package p
+
+//go:generate stringer -type=Kind
+//go:generated not a generate directive
+//go:noinline
+func f() {
+//go:generate echo "inside f"
+	// go:generate not a directive
+	/* //go:generate not a directive */
+}
+//go:generate
+//go:generate	 go run gen.go -- -out x.go
+`
+
+	type gen struct {
+		line, col uint
+		cmd       string
+		toplevel  bool
+	}
+	var got []gen
+	var pragmas int
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, func(line, col uint, text string) {
+		pragmas++
+	}, 0)
+	s.genh = func(line, col uint, cmd string, toplevel bool) {
+		got = append(got, gen{line, col, cmd, toplevel})
+	}
+	for {
+		s.next()
+		if s.tok == _EOF {
+			break
+		}
+	}
+
+	want := []gen{
+		{3, 1, "stringer -type=Kind", true},
+		{7, 1, `echo "inside f"`, false},
+		{11, 1, "", true},
+		{12, 1, "go run gen.go -- -out x.go", true},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	// all //go: directives are still passed to the pragma handler
+	if pragmas != 6 {
+		t.Errorf("got %d pragmas; want 6", pragmas)
+	}
+}
+
+func TestKeywordsOperators(t *testing.T) {
+	keywords := Keywords()
+	if len(keywords) != 25 {
+		t.Errorf("got %d keywords; want 25", len(keywords))
+	}
+	for _, kw := range keywords {
+		var s scanner
+		s.init(strings.NewReader(kw), nil, nil, 0)
+		s.next()
+		if s.tok == _Name || s.tok.String() != kw {
+			t.Errorf("keyword %s: got %s", kw, s.tok)
+		}
+	}
+
+	operators := Operators()
+	if len(operators) != 47 { // see spec section on operators and punctuation
+		t.Errorf("got %d operators; want 47", len(operators))
+	}
+	seen := make(map[string]bool)
+	for _, op := range operators {
+		if seen[op] {
+			t.Errorf("duplicate operator %s", op)
+		}
+		seen[op] = true
+	}
+	for _, op := range []string{"+", "&^=", "<-", "++", ":=", "...", "(", "}", ";", "!", "%"} {
+		if !seen[op] {
+			t.Errorf("missing operator %s", op)
+		}
+	}
+}
+
+// A scanned token records the scanner state after a call of next.
+type scanned struct {
+	tok       token
+	lit       string
+	op        Operator
+	prec      int
+	line, col uint
+}
+
+// scanAll scans src and returns all tokens before EOF.
+// It reports an error for any scanner error.
+func scanAll(t *testing.T, src string) []scanned {
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%q: %d:%d: %s", src, line, col, msg)
+	}, nil, 0)
+	var list []scanned
+	for {
+		s.next()
+		if s.tok == _EOF {
+			return list
+		}
+		list = append(list, scanned{s.tok, s.lit, s.op, s.prec, s.line, s.col})
+	}
+}
+
+func TestArrowOperator(t *testing.T) {
+	for _, test := range []struct {
+		src string
+		tok token // token at index 1
+		op  Operator
+	}{
+		{"c <- x", _Arrow, 0},
+		{"c<-x", _Arrow, 0},
+		{"a < b", _Operator, Lss},
+		{"a <= b", _Operator, Leq},
+		{"a << b", _Operator, Shl},
+		{"a <<= b", _AssignOp, Shl},
+		{"a < -b", _Operator, Lss},
+	} {
+		list := scanAll(t, test.src)
+		if len(list) < 3 {
+			t.Errorf("%q: got %d tokens; want at least 3", test.src, len(list))
+			continue
+		}
+		if got := list[1]; got.tok != test.tok || got.op != test.op {
+			t.Errorf("%q: got %s (op %s); want %s (op %s)", test.src, got.tok, got.op, test.tok, test.op)
+		}
+	}
+
+	// receive operation
+	list := scanAll(t, "<-c")
+	if len(list) != 3 || list[0].tok != _Arrow || list[1].tok != _Name {
+		t.Errorf("<-c: got %v; want <- name ;", list)
+	}
+}
+
+func TestTokenText(t *testing.T) {
+	long := strings.Repeat("x", 10000) // longer than the source buffer
+	for _, test := range []struct {
+		src  string
+		want []string // token texts, in order
+	}{
+		{"a <<= b", []string{"a", "<<=", "b", ""}},
+		{"x.y...", []string{"x", ".", "y", "..."}},
+		{"f(1e3, 0x1F, .5i)", []string{"f", "(", "1e3", ",", "0x1F", ",", ".5i", ")", ""}},
+		{"'a' `b\r\n` \"c\"", []string{"'a'", "`b\r\n`", `"c"`, ""}},
+		{"x /* comment */ ++\n", []string{"x", "++", "\n"}},
+		{"a; b", []string{"a", ";", "b", ""}},
+		{long + " " + long + "++", []string{long, long, "++", ""}},
+		{`"` + long + `"`, []string{`"` + long + `"`, ""}},
+	} {
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			t.Errorf("%q: %d:%d: %s", test.src, line, col, msg)
+		}, nil, 0)
+
+		var got []string
+		for {
+			s.next()
+			if s.tok == _EOF {
+				break
+			}
+			text := string(s.tokenText())
+			// the token text must match the source at the token position
+			if s.line == linebase {
+				if start := s.col - colbase; !strings.HasPrefix(test.src[start:], text) {
+					t.Errorf("%q: token text %q not found at column %d", test.src, text, s.col)
+				}
+			}
+			got = append(got, text)
+		}
+
+		if len(got) != len(test.want) {
+			t.Errorf("%q: got %d tokens; want %d", test.src, len(got), len(test.want))
+			continue
+		}
+		for i, text := range got {
+			if text != test.want[i] {
+				t.Errorf("%q: token %d: got %q; want %q", test.src, i, shorten(text), shorten(test.want[i]))
+			}
+		}
+	}
+}
+
+func shorten(s string) string {
+	if len(s) > 20 {
+		return s[:10] + "..." + s[len(s)-10:]
+	}
+	return s
+}
+
+func TestPackageFirst(t *testing.T) {
+	for _, test := range []struct {
+		src, err string // err is the expected error, if any
+	}{
+		{"package p", ""},
+		{"// comment\n/* comment */ package p", ""},
+		{"\n\n  package p", ""},
+		{"import \"fmt\"", "1:1: expected 'package', found import"},
+		{"// comment\n  foo", "2:3: expected 'package', found foo"},
+		{"\"go\"", "1:1: expected 'package', found \"go\""},
+		{";", "1:1: expected 'package', found semicolon"},
+		{"", "1:1: expected 'package', found EOF"},
+	} {
+		var errs []string
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+		}, nil, packageFirst)
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+
+		var want []string
+		if test.err != "" {
+			want = []string{test.err}
+		}
+		if fmt.Sprint(errs) != fmt.Sprint(want) {
+			t.Errorf("%q: got errors %q; want %q", test.src, errs, want)
+		}
+	}
+}
+
+func TestTabWidth(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		tabwidth uint
+		col      uint // column of x
+	}{
+		{"\tx", 0, 2},
+		{"\tx", 1, 2},
+		{"\t\tx", 1, 3},
+		{"\tx", 8, 9},
+		{"\t\tx", 8, 17},
+		{"\t  x", 8, 11},
+		{"ab\tx", 8, 9},
+		{"abcdefgh\tx", 8, 17},
+		{"a\t\tx", 4, 9},
+		{"y\n\tx", 8, 9},
+		{"/* \t */\tx", 8, 17},
+	} {
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			t.Errorf("%q: %d:%d: %s", test.src, line, col, msg)
+		}, nil, 0)
+		s.tabwidth = test.tabwidth
+		for s.next(); s.tok != _EOF && s.lit != "x"; s.next() {
+		}
+		if s.col != test.col {
+			t.Errorf("%q (tab width %d): got column %d; want %d", test.src, test.tabwidth, s.col, test.col)
+		}
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tx := \"\u00e9\" // longest\n}\n"
+	for _, test := range []struct {
+		src      string
+		tabwidth uint
+		want     int
+	}{
+		{"", 1, 0},
+		{"\n\n", 1, 0},
+		{src, 1, 21},
+		{src, 8, 28},
+		{"x\r\nxyz\r\n", 1, 3},
+		{"x\nxyz", 1, 3}, // no final newline
+		{"x\n\t\ty", 4, 9},
+	} {
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			t.Errorf("%q: %d:%d: %s", test.src, line, col, msg)
+		}, nil, 0)
+		s.tabwidth = test.tabwidth
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+		if got := s.maxLineLength(); got != test.want {
+			t.Errorf("%q (tab width %d): got max line length %d; want %d", test.src, test.tabwidth, got, test.want)
+		}
+	}
+}
+
+func TestEmbedDirectives(t *testing.T) {
+	const src = `package p
+
+//go:embed hello.txt
+//go:embed *.html static/*.css  images
+//go:embed "my file.txt" ` + "`raw name`" + ` plain
+//go:embedded not an embed directive
+//go:embed
+//go:embed "unterminated
+`
+
+	type embed struct {
+		line, col uint
+		patterns  []string
+	}
+	var got []embed
+	var errs []string
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+	}, nil, 0)
+	s.embedh = func(line, col uint, patterns []string) {
+		got = append(got, embed{line, col, patterns})
+	}
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+
+	want := []embed{
+		{3, 1, []string{"hello.txt"}},
+		{4, 1, []string{"*.html", "static/*.css", "images"}},
+		{5, 1, []string{"my file.txt", "raw name", "plain"}},
+		{7, 1, nil},
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	wantErrs := []string{`8:1: invalid quoted string in //go:embed: "unterminated`}
+	if fmt.Sprint(errs) != fmt.Sprint(wantErrs) {
+		t.Errorf("got errors %q; want %q", errs, wantErrs)
+	}
+}
+
+func TestBuildDirectives(t *testing.T) {
+	const src = `//go:build go1.22 && linux
+//go:build !(windows || plan9) && cgo
+//go:buildx not a build directive
+ //go:build not at line start
+//go:build linux &&
+package p
+`
+
+	type build struct {
+		line, col uint
+		expr      string
+	}
+	var got []build
+	var errs []string
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+	}, nil, scanComments)
+	s.buildh = func(line, col uint, x constraint) {
+		got = append(got, build{line, col, x.String()})
+		if line == 1 {
+			// go1.22 && linux
+			and, ok := x.(*andConstraint)
+			if !ok {
+				t.Fatalf("got %T; want *andConstraint", x)
+			}
+			if v, ok := and.x.(*tagConstraint); !ok {
+				t.Errorf("got %s; want version tag", and.x)
+			} else if minor, ok := v.goVersion(); !ok || minor != 22 {
+				t.Errorf("%s: goVersion() = %d, %v; want 22, true", v, minor, ok)
+			}
+			if os, ok := and.y.(*tagConstraint); !ok || os.tag != "linux" {
+				t.Errorf("got %s; want tag linux", and.y)
+			}
+		}
+	}
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+
+	want := []build{
+		{1, 1, "go1.22 && linux"},
+		{2, 1, "!(windows || plan9) && cgo"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	wantErrs := []string{"5:1: invalid //go:build line: unexpected end of expression"}
+	if fmt.Sprint(errs) != fmt.Sprint(wantErrs) {
+		t.Errorf("got errors %q; want %q", errs, wantErrs)
+	}
+}
+
+func TestLintComments(t *testing.T) {
+	const src = `package p
+
+var x = 1 //nolint
+var y = 2 //nolint:govet,staticcheck // reason
+//nolint:errcheck
+func f() {} /*nolint*/
+// nolint: not a suppression comment
+//nolintx
+var s = "//nolint"
+//nolint:unused` + "\r" + `
+`
+
+	type lint struct {
+		line, col uint
+		linters   []string
+	}
+	var got []lint
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.linth = func(line, col uint, linters []string) {
+		got = append(got, lint{line, col, linters})
+	}
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+
+	want := []lint{
+		{3, 11, nil},
+		{4, 11, []string{"govet", "staticcheck"}},
+		{5, 1, []string{"errcheck"}},
+		{10, 1, []string{"unused"}},
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// custom prefix
+	got = nil
+	s.init(strings.NewReader("x //lint:ignore U1000\ny //nolint\n"), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, scanComments)
+	s.linth = func(line, col uint, linters []string) {
+		got = append(got, lint{line, col, linters})
+	}
+	s.lintPrefix = "lint"
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+	want = []lint{{1, 3, []string{"ignore"}}}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Errorf("custom prefix: got %q; want %q", got, want)
+	}
+}
+
+func TestBitClearOperator(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		tok  token    // token at index 1
+		op   Operator // operator of token at index 1
+		prec int
+		next string // name at index 2
+	}{
+		{"a &^ b", _Operator, AndNot, precMul, "b"},
+		{"a &^= b", _AssignOp, AndNot, precMul, "b"},
+		{"a&^b", _Operator, AndNot, precMul, "b"},
+		{"a &^^b", _Operator, AndNot, precMul, ""}, // &^ followed by unary ^
+		{"a & b", _Operator, And, precMul, "b"},
+		{"a &= b", _AssignOp, And, precMul, "b"},
+		{"a && b", _Operator, AndAnd, precAndAnd, "b"},
+		{"a & ^b", _Operator, And, precMul, ""},
+		{"a ^ b", _Operator, Xor, precAdd, "b"},
+		{"a ^= b", _AssignOp, Xor, precAdd, "b"},
+	} {
+		list := scanAll(t, test.src)
+		if len(list) < 3 {
+			t.Errorf("%q: got %d tokens; want at least 3", test.src, len(list))
+			continue
+		}
+		if got := list[1]; got.tok != test.tok || got.op != test.op || got.prec != test.prec {
+			t.Errorf("%q: got %s (op %s, prec %d); want %s (op %s, prec %d)", test.src, got.tok, got.op, got.prec, test.tok, test.op, test.prec)
+		}
+		if test.next != "" && (list[2].tok != _Name || list[2].lit != test.next) {
+			t.Errorf("%q: got %s %q after operator; want name %s", test.src, list[2].tok, list[2].lit, test.next)
+		}
+	}
+}
+
+func TestMultiLineRawString(t *testing.T) {
+	const src = "x := `line 1\nline 2\r\nline 3`\ny = 1"
+	list := scanAll(t, src)
+
+	want := []scanned{
+		{_Name, "x", 0, 0, 1, 1},
+		{_Define, "", 0, 0, 1, 3},
+		{_Literal, "`line 1\nline 2\r\nline 3`", 0, 0, 1, 6},
+		{_Semi, "newline", 0, 0, 3, 8},
+		{_Name, "y", 0, 0, 4, 1},
+		{_Assign, "", 0, 0, 4, 3},
+		{_Literal, "1", 0, 0, 4, 5},
+		{_Semi, "EOF", 0, 0, 4, 6},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d tokens; want %d", len(list), len(want))
+	}
+	for i, got := range list {
+		if got.tok != _Name && got.tok != _Literal && got.tok != _Semi {
+			got.lit = "" // lit is not valid
+		}
+		if got != want[i] {
+			t.Errorf("token %d: got %v; want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestKeywordCase(t *testing.T) {
+	const src = "func Func iF IF Return return funcs Fallthrough fallThrough İf"
+
+	type advisory struct {
+		col            uint
+		ident, keyword string
+	}
+	var got []advisory
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.caseh = func(line, col uint, ident, keyword string) {
+		got = append(got, advisory{col, ident, keyword})
+	}
+
+	var toks []string
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Name {
+			toks = append(toks, s.lit)
+		} else {
+			toks = append(toks, s.tok.String())
+		}
+	}
+
+	// keywords are case-sensitive
+	const wantToks = "[func Func iF IF Return return funcs Fallthrough fallThrough İf ;]"
+	if fmt.Sprint(toks) != wantToks {
+		t.Errorf("got tokens %v; want %s", toks, wantToks)
+	}
+
+	want := []advisory{
+		{6, "Func", "func"},
+		{11, "iF", "if"},
+		{14, "IF", "if"},
+		{17, "Return", "return"},
+		{37, "Fallthrough", "fallthrough"},
+		{49, "fallThrough", "fallthrough"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestIncDecOperators(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		toks []token // all tokens
+		op   Operator
+	}{
+		{"x++\n", []token{_Name, _IncOp, _Semi}, Add},
+		{"x--\n", []token{_Name, _IncOp, _Semi}, Sub},
+		{"x++", []token{_Name, _IncOp, _Semi}, Add},
+		{"a + b", []token{_Name, _Operator, _Name, _Semi}, Add},
+		{"a += b", []token{_Name, _AssignOp, _Name, _Semi}, Add},
+		{"a - b", []token{_Name, _Operator, _Name, _Semi}, Sub},
+		{"a -= b", []token{_Name, _AssignOp, _Name, _Semi}, Sub},
+		{"a + +b", []token{_Name, _Operator, _Operator, _Name, _Semi}, Add},
+		{"a+++b", []token{_Name, _IncOp, _Operator, _Name, _Semi}, Add},
+	} {
+		list := scanAll(t, test.src)
+		var toks []token
+		for _, s := range list {
+			toks = append(toks, s.tok)
+		}
+		if fmt.Sprint(toks) != fmt.Sprint(test.toks) {
+			t.Errorf("%q: got %v; want %v", test.src, toks, test.toks)
+			continue
+		}
+		if op := list[1].op; op != test.op {
+			t.Errorf("%q: got op %s; want %s", test.src, op, test.op)
+		}
+	}
+
+	// a semicolon is inserted after ++ and -- at the end of a line
+	list := scanAll(t, "x++\ny--\n")
+	if len(list) != 6 || list[2].lit != "newline" || list[2].line != 1 || list[5].lit != "newline" || list[5].line != 2 {
+		t.Errorf("got %v; want semicolons after x++ and y--", list)
+	}
+}
+
+func TestColonTokens(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		toks string // all tokens
+	}{
+		{"a[i:j:k]", "name [ name : name : name ] ;"},
+		{"a[:j:k]", "name [ : name : name ] ;"},
+		{"a[i:]", "name [ name : ] ;"},
+		{"a[:]=b", "name [ : ] = name ;"},
+		{"m[k] = v", "name [ name ] = name ;"},
+		{"m[k]=v", "name [ name ] = name ;"},
+		{"a[i: =", "name [ name : ="},
+		{"x := y", "name := name ;"},
+		{"x:=y", "name := name ;"},
+		{"x :== y", "name := = name ;"},
+	} {
+		var toks []string
+		for _, s := range scanAll(t, test.src) {
+			toks = append(toks, s.tok.String())
+		}
+		if got := strings.Join(toks, " "); got != test.toks {
+			t.Errorf("%q: got %s; want %s", test.src, got, test.toks)
+		}
+	}
+}
+
+func TestScanModes(t *testing.T) {
+	const src = "// doc\npackage p /* c */\n\n//go:noinline\nfunc f() { x /* multi\nline */ y }"
+
+	for _, test := range []struct {
+		mode scanMode
+		want string // tokens
+	}{
+		{0, `package p ; func f ( ) { x ; y } ;`},
+		{scanComments | packageFirst,
+			`"// doc" package p "/* c */" ; "//go:noinline" func f ( ) { x "/* multi\nline */" ; y } ;`},
+		{scanWhitespace,
+			`"\n" package " " p " " ; "\n" "\n" func " " f ( ) " " { " " x " " ; " " y " " } ;`},
+		{scanComments | scanWhitespace,
+			`"// doc" "\n" package " " p " " "/* c */" ; "\n" "//go:noinline" "\n" func " " f ( ) " " {` +
+				` " " x " " "/* multi\nline */" ; " " y " " } ;`},
+	} {
+		var directives []string
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, func(line, col uint, text string) {
+			directives = append(directives, fmt.Sprintf("%d:%d: %s", line, col, text))
+		}, test.mode)
+
+		var toks []string
+		for s.next(); s.tok != _EOF; s.next() {
+			switch s.tok {
+			case _Name:
+				toks = append(toks, s.lit)
+			case _Comment, _Whitespace:
+				if s.lit != string(s.tokenText()) {
+					t.Errorf("mode %d: lit = %q, tokenText = %q", test.mode, s.lit, s.tokenText())
+				}
+				toks = append(toks, strconv.Quote(s.lit))
+			default:
+				toks = append(toks, s.tok.String())
+			}
+		}
+		if got := strings.Join(toks, " "); got != test.want {
+			t.Errorf("mode %d:\ngot  %s\nwant %s", test.mode, got, test.want)
+		}
+
+		// directives are reported independent of the mode
+		if got := fmt.Sprint(directives); got != "[4:3: go:noinline]" {
+			t.Errorf("mode %d: got directives %s", test.mode, got)
+		}
+	}
+}
+
+func TestScanPastEOF(t *testing.T) {
+	for _, test := range []struct {
+		src     string
+		ioerr   error
+		errs    int
+		line    uint
+		lastTok token
+	}{
+		{"", nil, 0, 1, _EOF},
+		{"x", nil, 0, 1, _Semi},
+		{"x\n\n", nil, 0, 3, _Semi},
+		{"x /* comment */", nil, 0, 1, _Semi},
+		{"x", io.ErrUnexpectedEOF, 1, 1, _Semi},
+	} {
+		var r io.Reader = strings.NewReader(test.src)
+		if test.ioerr != nil {
+			r = &errReader{r, test.ioerr}
+		}
+		var errs int
+		var s scanner
+		s.init(r, func(line, col uint, msg string) { errs++ }, nil, 0)
+
+		var last token
+		for s.next(); s.tok != _EOF; s.next() {
+			last = s.tok
+		}
+		if test.lastTok != _EOF && last != test.lastTok {
+			t.Errorf("%q: got last token %s; want %s", test.src, last, test.lastTok)
+		}
+		line, col := s.line, s.col
+		for i := 0; i < 5; i++ {
+			s.next()
+			if s.tok != _EOF || s.line != line || s.col != col {
+				t.Errorf("%q: got %s at %d:%d after EOF; want EOF at %d:%d", test.src, s.tok, s.line, s.col, line, col)
+			}
+		}
+		if line != test.line {
+			t.Errorf("%q: got EOF on line %d; want %d", test.src, line, test.line)
+		}
+		if errs != test.errs {
+			t.Errorf("%q: got %d errors; want %d", test.src, errs, test.errs)
+		}
+	}
+}
+
+// An errReader returns the data from r followed by err.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
+
+func TestSetLine(t *testing.T) {
+	var s scanner
+	s.init(strings.NewReader("\tx\n\ny /* a\nb */ z"), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.setLine(100)
+
+	var got []string
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Name {
+			got = append(got, fmt.Sprintf("%s@%d:%d", s.lit, s.line, s.col))
+		}
+	}
+	if got, want := strings.Join(got, " "), "x@100:2 y@102:1 z@103:6"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestBlankIdent(t *testing.T) {
+	const src = "_, _foo := __, _\nvar _ = x_"
+
+	var blanks []string
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.blankh = func(line, col uint) {
+		blanks = append(blanks, fmt.Sprintf("%d:%d", line, col))
+	}
+
+	var names []string
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Name {
+			names = append(names, s.lit)
+		}
+	}
+
+	// _ is an ordinary identifier
+	if got, want := strings.Join(names, " "), "_ _foo __ _ _ x_"; got != want {
+		t.Errorf("got names %s; want %s", got, want)
+	}
+	if got, want := strings.Join(blanks, " "), "1:1 1:16 2:5"; got != want {
+		t.Errorf("got blanks at %s; want %s", got, want)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	var buf strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&buf, "x%d = f(%d, \"%d\") /* c\n */\n", i, i, i)
+	}
+	src := buf.String()
+
+	type tok struct {
+		tok       token
+		lit       string
+		line, col uint
+	}
+	scan := func(s *scanner, n int) []tok {
+		var list []tok
+		for i := 0; i < n && s.tok != _EOF; i++ {
+			s.next()
+			list = append(list, tok{s.tok, s.lit, s.line, s.col})
+		}
+		return list
+	}
+
+	for _, oneByte := range []bool{false, true} {
+		// skip, lookahead: tokens to scan before and after the checkpoint
+		for _, n := range [][2]int{{0, 1}, {3, 10}, {100, 5000}, {17000, 100}, {10, 1e6}} {
+			var r io.Reader = strings.NewReader(src)
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+			var s scanner
+			s.init(r, func(line, col uint, msg string) {
+				t.Fatalf("%d:%d: %s", line, col, msg)
+			}, nil, 0)
+
+			scan(&s, n[0])
+			cp := s.checkpoint()
+			want := scan(&s, n[1])
+			s.restore(cp)
+			got := scan(&s, n[1])
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%v, %v: got different tokens after restore", oneByte, n)
+			}
+
+			// the rest of the source is scanned normally
+			rest := scan(&s, 1e6)
+			if len(rest) > 0 && rest[len(rest)-1].line != 4001 || s.tok != _EOF {
+				t.Errorf("%v, %v: got %s at line %d at end", oneByte, n, s.tok, s.line)
+			}
+		}
+	}
+}
+
+func TestBigIntLits(t *testing.T) {
+	const src = "123456789012345678901234567890 + 42 - 18446744073709551615 * 18446744073709551616 + 0xffffffffffffffffff + 1e30 + 0777777777777777777777777"
+
+	var big []string
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.bigh = func(line, col uint, lit string) {
+		big = append(big, fmt.Sprintf("%d:%d: %s", line, col, lit))
+	}
+
+	var lits int
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Literal {
+			lits++
+		}
+	}
+
+	if lits != 7 {
+		t.Errorf("got %d literals; want 7", lits)
+	}
+	want := "[1:1: 123456789012345678901234567890 1:62: 18446744073709551616 1:85: 0xffffffffffffffffff 1:115: 0777777777777777777777777]"
+	if got := fmt.Sprint(big); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestCRLines(t *testing.T) {
+	const src = "a\nb\r\nc\rd // comment\r\r\n`raw\r\nstring`\re\r"
+
+	for _, crlines := range []bool{false, true} {
+		var s scanner
+		s.init(iotest.OneByteReader(strings.NewReader(src)), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, 0)
+		s.crlines = crlines
+
+		var toks []string
+		for s.next(); s.tok != _EOF; s.next() {
+			switch s.tok {
+			case _Name:
+				toks = append(toks, fmt.Sprintf("%s@%d:%d", s.lit, s.line, s.col))
+			case _Literal:
+				toks = append(toks, fmt.Sprintf("lit@%d:%d", s.line, s.col))
+			}
+		}
+		toks = append(toks, fmt.Sprintf("EOF@%d:%d", s.line, s.col))
+
+		want := "a@1:1 b@2:1 c@3:1 d@3:3 lit@4:1 e@5:9 EOF@5:11"
+		if crlines {
+			want = "a@1:1 b@2:1 c@3:1 d@4:1 lit@6:1 e@8:1 EOF@9:1"
+		}
+		if got := strings.Join(toks, " "); got != want {
+			t.Errorf("crlines = %v:\ngot  %s\nwant %s", crlines, got, want)
+		}
+	}
+}
+
+func TestNextSignificant(t *testing.T) {
+	const src = "/* a */ x // b\n\t/* c */ /* d */ + /* e\n */ y"
+
+	for _, mode := range []scanMode{0, scanComments, scanWhitespace, scanComments | scanWhitespace} {
+		var comments int
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+		s.commh = func(line, col uint, text string) { comments++ }
+
+		var toks []string
+		for s.nextSignificant(); s.tok != _EOF; s.nextSignificant() {
+			toks = append(toks, fmt.Sprintf("%s@%d:%d", s.tok, s.line, s.col))
+		}
+		if got, want := strings.Join(toks, " "), "name@1:9 ;@1:15 op@2:18 name@3:5 ;@3:6"; got != want {
+			t.Errorf("mode %d:\ngot  %s\nwant %s", mode, got, want)
+		}
+		if comments != 5 {
+			t.Errorf("mode %d: got %d comments; want 5", mode, comments)
+		}
+	}
+}
+
+func TestLoneCR(t *testing.T) {
+	const src = "x := 1\r\ny++\rz--\r\ns := \"a\rb\" // \r\n`raw\r` + \"c\"\r"
+
+	for _, mode := range []scanMode{0, loneCR, loneCR | scanWhitespace} {
+		var errs []string
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+		}, nil, mode)
+
+		var toks []string
+		for s.next(); s.tok != _EOF; s.next() {
+			if s.tok != _Whitespace {
+				toks = append(toks, s.tok.String())
+			}
+		}
+		if got, want := strings.Join(toks, " "), "name := literal ; name opop name opop ; name := literal ; literal op literal ;"; got != want {
+			t.Errorf("mode %d: got %s; want %s", mode, got, want)
+		}
+
+		var want []string
+		if mode&loneCR != 0 {
+			want = []string{
+				"2:4: carriage return not followed by newline",
+				"3:8: carriage return not followed by newline",
+				"4:13: carriage return not followed by newline",
+			}
+		}
+		if fmt.Sprint(errs) != fmt.Sprint(want) {
+			t.Errorf("mode %d: got errors %q; want %q", mode, errs, want)
+		}
+	}
+}
+
+func TestSemiCause(t *testing.T) {
+	const src = "x++\ny\nf(a,\nb)\nc := d /* multi-\nline */ e // comment\nreturn"
+
+	for _, mode := range []scanMode{semiCause, semiCause | scanComments | scanWhitespace} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+
+		var got []string
+		for s.next(); s.tok != _EOF; s.next() {
+			if s.tok == _Semi && s.lit != "semicolon" {
+				cause := s.semiTok.String()
+				if s.semiTok == _IncOp {
+					cause = s.semiOp.String() + s.semiOp.String()
+				}
+				got = append(got, cause)
+			}
+		}
+		if got, want := strings.Join(got, " "), "++ name ) name name return"; got != want {
+			t.Errorf("mode %d: got %s; want %s", mode, got, want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	const src = "package p\n\nfunc f(x int) { x += 1 } // comment\n"
+
+	// collect the tokens the way a range-over-func loop would
+	var got []Token
+	Tokens([]byte(src), nil)(func(tok Token) bool {
+		got = append(got, tok)
+		return true
+	})
+
+	want := Tokenize([]byte(src), nil)
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens; want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %v; want %v", i, got[i], want[i])
+		}
+	}
+
+	// spot-check the result
+	if n := len(want); n != 15 {
+		t.Errorf("got %d tokens; want 15", n)
+	}
+	if tok := want[0]; tok.Tok != _Package || tok.Pos.Line() != 1 || tok.Pos.Col() != 1 {
+		t.Errorf("first token: got %s at %s; want package at 1:1", tok.Tok, tok.Pos)
+	}
+	if tok := want[len(want)-1]; tok.Tok != _Semi || tok.Lit != "newline" {
+		t.Errorf("last token: got %s %q; want newline", tok.Tok, tok.Lit)
+	}
+
+	// classifying tokens
+	var kinds []string
+	for _, tok := range Tokenize([]byte("if x != 0 { y = 'a' }"), nil) {
+		switch {
+		case tok.IsName():
+			kinds = append(kinds, "name")
+		case tok.IsLiteral():
+			kinds = append(kinds, "literal")
+		case tok.IsKeyword():
+			kinds = append(kinds, "keyword")
+		case tok.IsOperator():
+			kinds = append(kinds, "operator")
+		default:
+			kinds = append(kinds, "-")
+		}
+	}
+	if got, want := strings.Join(kinds, " "), "keyword name operator literal - name operator literal - -"; got != want {
+		t.Errorf("got kinds %s; want %s", got, want)
+	}
+
+	// stopping early
+	n := 0
+	Tokens([]byte(src), nil)(func(Token) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("got %d calls to yield; want 3", n)
+	}
+
+	// errors are reported with positions
+	var errs []string
+	Tokenize([]byte("x := 'ab'"), func(err error) {
+		errs = append(errs, err.Error())
+	})
+	if len(errs) != 1 || errs[0] != ":1:6: invalid character literal (more than one character)" {
+		t.Errorf("got errors %q", errs)
+	}
+}
+
+func TestNULBytes(t *testing.T) {
+	const src = "x :=\x00 1\nfo\x00o := \"a\x00b\" + 1\x002 // c\x00\n\x00y"
+
+	var errs []string
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+	}, nil, 0)
+
+	var toks []string
+	for s.next(); s.tok != _EOF; s.next() {
+		switch s.tok {
+		case _Name, _Literal:
+			toks = append(toks, s.lit)
+		default:
+			toks = append(toks, s.tok.String())
+		}
+	}
+	if got, want := strings.Join(toks, " "), `x := 1 ; foo := "ab" op 12 ; y ;`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	want := []string{
+		"1:5: invalid NUL character",
+		"2:3: invalid NUL character",
+		"2:11: invalid NUL character",
+		"2:18: invalid NUL character",
+		"2:25: invalid NUL character",
+		"3:1: invalid NUL character",
+	}
+	if fmt.Sprint(errs) != fmt.Sprint(want) {
+		t.Errorf("got errors %q; want %q", errs, want)
+	}
+}
+
+func TestAtLineStart(t *testing.T) {
+	const src = "x := `a\nb` + y /* c */\n\t/* d */ f(a,\n\tb); g()\n"
+
+	for _, mode := range []scanMode{0, scanComments | scanWhitespace} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+
+		var first []string
+		for s.next(); s.tok != _EOF; s.next() {
+			if s.atLineStart() && s.tok != _Comment && s.tok != _Whitespace {
+				first = append(first, fmt.Sprintf("%d:%s", s.line, s.tok))
+			}
+		}
+		// y follows the raw string on line 2, so it is not at the line start
+		if got, want := strings.Join(first, " "), "1:name 3:name 4:name"; got != want {
+			t.Errorf("mode %d: got %s; want %s", mode, got, want)
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	const src = `// Copyright notice.
+
+// Package foo does things.
+package /* name: */ foo // import "example.com/foo"
+
+import "fmt"
+
+var x = fmt.Sprint(package_)
+`
+
+	for _, mode := range []scanMode{0, scanComments} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+		var got []string
+		s.pkgh = func(line, col uint, name string) {
+			got = append(got, fmt.Sprintf("%d:%d: %s", line, col, name))
+		}
+
+		// stop as soon as the package name is known
+		for s.next(); s.tok != _EOF && len(got) == 0; s.next() {
+		}
+		if want := "4:21: foo"; fmt.Sprint(got) != "["+want+"]" {
+			t.Errorf("mode %d: got %q; want %q", mode, got, want)
+		}
+		if s.tok != _Name || s.lit != "foo" {
+			t.Errorf("mode %d: stopped at %s; want name foo", mode, s.tok)
+		}
+
+		// the rest of the file doesn't report anything
+		got = nil
+		for ; s.tok != _EOF; s.next() {
+		}
+		if len(got) != 0 {
+			t.Errorf("mode %d: got %q after the package clause", mode, got)
+		}
+	}
+}
+
+func TestAdjacentStrings(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		want string // lit@col of each token
+	}{
+		{`"a" "b"`, `"a"@1 "b"@5`},
+		{`"a""b"`, `"a"@1 "b"@4`},
+		{"`a` `b`", "`a`@1 `b`@5"},
+		{"\"a\"`b`'c'", "\"a\"@1 `b`@4 'c'@7"},
+	} {
+		var got []string
+		for _, tok := range scanAll(t, test.src) {
+			if tok.tok != _Literal {
+				continue
+			}
+			got = append(got, fmt.Sprintf("%s@%d", tok.lit, tok.col))
+		}
+		if got := strings.Join(got, " "); got != test.want {
+			t.Errorf("%s: got %s; want %s", test.src, got, test.want)
+		}
+	}
+
+	// the parser reports the error
+	_, err := ParseBytes(nil, []byte(`package p; var _ = "a" "b"`), nil, nil, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "unexpected literal") {
+		t.Errorf("got %v; want syntax error for adjacent literals", err)
+	}
+}
+
+func TestReservedWords(t *testing.T) {
+	const src = "macro m(x) { macros }\nmacro\n"
+
+	for _, reserved := range []map[string]bool{nil, {}, {"macro": true}} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, 0)
+		s.reserved = reserved
+
+		var toks []string
+		for s.next(); s.tok != _EOF; s.next() {
+			switch s.tok {
+			case _Name, _Reserved:
+				toks = append(toks, s.tok.String()+" "+s.lit)
+			default:
+				toks = append(toks, s.tok.String())
+			}
+		}
+
+		want := "name macro name m ( name x ) { name macros } ; name macro ;"
+		if reserved["macro"] {
+			// no ';' after a reserved word
+			want = "reserved word macro name m ( name x ) { name macros } ; reserved word macro"
+		}
+		if got := strings.Join(toks, " "); got != want {
+			t.Errorf("%v: got %s\nwant %s", reserved, got, want)
+		}
+	}
+}
+
+func TestDirectiveFilter(t *testing.T) {
+	const src = `//go:build linux
+package p
+
+//go:embed a.txt
+//go:embedded
+//go:noinline
+//line x.go:10
+//go:embed
+var x string
+`
+
+	for _, test := range []struct {
+		directives []string
+		want       string
+	}{
+		{nil, "go:build linux|go:embed a.txt|go:embedded|go:noinline|line x.go:10|go:embed"},
+		{[]string{}, "go:build linux|go:embed a.txt|go:embedded|go:noinline|line x.go:10|go:embed"},
+		{[]string{"go:embed"}, "go:embed a.txt|line x.go:10|go:embed"},
+		{[]string{"go:embed", "go:build"}, "go:build linux|go:embed a.txt|line x.go:10|go:embed"},
+	} {
+		var got []string
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, func(line, col uint, text string) {
+			got = append(got, text)
+		}, 0)
+		s.directives = test.directives
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+		if got := strings.Join(got, "|"); got != test.want {
+			t.Errorf("%q: got %s\nwant %s", test.directives, got, test.want)
+		}
+	}
+}
+
+func TestCommentStats(t *testing.T) {
+	const src = "// Package p.\npackage p /* inline */\n\n/*\nblock\n*/\nvar x = 1 // trailing\n"
+	const count, bytes = 4, len("// Package p.") + len("/* inline */") + len("/*\nblock\n*/") + len("// trailing")
+
+	for _, mode := range []scanMode{0, scanComments} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+		if mode == 0 {
+			s.commh = func(line, col uint, text string) {}
+		}
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+		if n, size := s.commentStats(); n != count || size != bytes {
+			t.Errorf("mode %d: got %d comments, %d bytes; want %d, %d", mode, n, size, count, bytes)
+		}
+	}
+
+	// no statistics are collected if comments are not needed
+	var s scanner
+	s.init(strings.NewReader(src), nil, nil, 0)
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+	if n, size := s.commentStats(); n != 0 || size != 0 {
+		t.Errorf("got %d comments, %d bytes without comment mode; want 0, 0", n, size)
+	}
+}
+
+func TestDots(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+		err       string
+	}{
+		{"func(x ...int)", "func ( name ... name )", ""},
+		{"[...]int{}", "[ ... ] name { }", ""},
+		{"a.b", "name . name", ""},
+		{"x.(T)", "name . ( name )", ""},
+		{"a..b", "name . name", "1:3: invalid token .."},
+		{"a.. b", "name . name", "1:3: invalid token .."},
+		{"a.....b", "name ... . name", "1:6: invalid token .."},
+		{"f..3", "name . literal", ""},
+		{"f...3", "name ... literal", ""},
+	} {
+		var s scanner
+		var errs []string
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+		}, nil, 0)
+		var toks []string
+		for s.next(); s.tok != _EOF; s.next() {
+			if s.tok == _Semi {
+				continue // automatically inserted
+			}
+			toks = append(toks, s.tok.String())
+		}
+		if got := strings.Join(toks, " "); got != test.want {
+			t.Errorf("%q: got %s; want %s", test.src, got, test.want)
+		}
+		if got := strings.Join(errs, "; "); got != test.err {
+			t.Errorf("%q: got errors %q; want %q", test.src, got, test.err)
+		}
+	}
+}
+
+func TestPredeclared(t *testing.T) {
+	const src = "x := len(foo) + cap(nil)\nvar _ error = Len(true)"
+
+	var s scanner
+	var got []string
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.predh = func(line, col uint, name string) {
+		if s.tok != _Name {
+			t.Errorf("%s: got token %s; want name", name, s.tok)
+		}
+		got = append(got, fmt.Sprintf("%d:%d %s", line, col, name))
+	}
+	var names int
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Name {
+			names++
+		}
+	}
+
+	want := "1:6 len 1:17 cap 1:21 nil 2:7 error 2:19 true"
+	if got := strings.Join(got, " "); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if names != 9 {
+		t.Errorf("got %d names; want 9", names)
+	}
+
+	for _, name := range []string{"len", "nil", "int", "iota", "println"} {
+		if !IsPredeclared(name) {
+			t.Errorf("IsPredeclared(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"foo", "Len", "func", "_", ""} {
+		if IsPredeclared(name) {
+			t.Errorf("IsPredeclared(%q) = true", name)
+		}
+	}
+}
+
+func TestPrevTok(t *testing.T) {
+	const src = "a b ++ /* comment */\nc; d"
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, scanComments)
+	var got []string
+	for s.next(); s.tok != _EOF; s.next() {
+		got = append(got, fmt.Sprintf("%s(%s)", s.tok, s.prevTok()))
+	}
+
+	const want = "name(<tok-0>) name(name) opop(name) comment(opop) ;(opop) name(opop) ;(name) name(;) ;(name)"
+	if got := strings.Join(got, " "); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestRangeKeyword(t *testing.T) {
+	// range is always scanned as a keyword, independent of its
+	// context; only the parser decides whether it is valid
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"for range x {}", "for range name { }"},
+		{"for i := range x {}", "for name := range name { }"},
+		{"for k, v = range m {}", "for name , name = range name { }"},
+		{"range := 3", "range := literal"},
+		{"x.range", "name . range"},
+	} {
+		var toks []string
+		for _, tok := range scanAll(t, test.src) {
+			if tok.tok != _Semi {
+				toks = append(toks, tok.tok.String())
+			}
+		}
+		if got := strings.Join(toks, " "); got != test.want {
+			t.Errorf("%q: got %s; want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestEOFSemi(t *testing.T) {
+	for _, test := range []struct {
+		src, want string // want lists the tokens, with the lit of semicolons
+	}{
+		{"", ""},
+		{"x", "name ;EOF"},
+		{"return x", "return name ;EOF"},
+		{"return", "return ;EOF"},
+		{"x++", "name opop ;EOF"},
+		{"}", "} ;EOF"},
+		{"x /* comment */", "name ;EOF"},
+		{"x // comment", "name ;EOF"},
+		{"x\n", "name ;newline"},
+		{"x;", "name ;semicolon"},
+		{"{", "{"},
+		{"x +", "name op"},
+		{"func f(", "func name ("},
+	} {
+		var toks []string
+		for _, tok := range scanAll(t, test.src) {
+			s := tok.tok.String()
+			if tok.tok == _Semi {
+				s += tok.lit
+			}
+			toks = append(toks, s)
+		}
+		if got := strings.Join(toks, " "); got != test.want {
+			t.Errorf("%q: got %s; want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestIndentation(t *testing.T) {
+	const src = "package p\n" +
+		"\n" +
+		"func f() {\n" +
+		"\tx := 1\n" +
+		"    y := 2\n" +
+		"\t  \t// comment\n" +
+		"   \n" +
+		"\t/* multi-line\n" +
+		"   comment */ z()\n" +
+		"}\r\n" +
+		"  "
+
+	for _, mode := range []scanMode{0, scanComments | scanWhitespace} {
+		var s scanner
+		var got []string
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+		s.indh = func(line uint, indent []byte) {
+			got = append(got, fmt.Sprintf("%d:%q", line, indent))
+		}
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+
+		// line 9 starts inside a comment
+		const want = `1:"" 2:"" 3:"" 4:"\t" 5:"    " 6:"\t  \t" 7:"" 8:"\t" 10:"" 11:""`
+		if got := strings.Join(got, " "); got != want {
+			t.Errorf("mode %d:\ngot  %s\nwant %s", mode, got, want)
+		}
+	}
+}
+
+func TestOctalEscapes(t *testing.T) {
+	// octal escapes are valid; octh only reports them
+	const src = `"\101" "\x41" "\u0041" '\101' "a\000b\377" '\\' "\\101" ` + "`\\101`"
+
+	var s scanner
+	var got []string
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, 0)
+	s.octh = func(line, col uint, value byte) {
+		got = append(got, fmt.Sprintf("%d:%d %#x", line, col, value))
+	}
+	var lits int
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Literal {
+			lits++
+		}
+	}
+
+	const want = "1:2 0x41 1:25 0x41 1:33 0x0 1:38 0xff"
+	if got := strings.Join(got, " "); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if lits != 8 {
+		t.Errorf("got %d literals; want 8", lits)
+	}
+}
+
+func TestMixedLineEndings(t *testing.T) {
+	for _, test := range []struct {
+		src   string
+		mixed bool
+	}{
+		{"", false},
+		{"package p", false},
+		{"package p\n\nvar x int\n", false},
+		{"package p\r\n\r\nvar x int\r\n", false},
+		{"package p\r\nvar x int\n", true},
+		{"package p\n// comment\r\n", true},
+		{"package p\nvar s = `a\r\nb`", true},
+		{"package p\n/* a\r\nb */", true},
+		{"package p\r\rvar x\n", false}, // lone CRs don't end lines
+	} {
+		var s scanner
+		s.init(strings.NewReader(test.src), func(line, col uint, msg string) {
+			t.Errorf("%q: %d:%d: %s", test.src, line, col, msg)
+		}, nil, lineEndings)
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+		if got := s.mixedLineEndings(); got != test.mixed {
+			t.Errorf("%q: got mixed = %v; want %v", test.src, got, test.mixed)
+		}
+	}
+}
+
+func TestLineText(t *testing.T) {
+	src := "package p\n\nvar x = 1\r\n//line foo.go:100\nfunc f() {\n\treturn\n}"
+	// make sure the source doesn't fit into the source buffer
+	src = strings.Repeat("// comment\n", 1000) + src
+	const offset = 1000 // lines before package clause
+
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, keepLines)
+	if s.lineText(1) != nil {
+		t.Error("got text for line 1 before scanning")
+	}
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+
+	for _, test := range []struct {
+		line uint
+		want string
+	}{
+		{1, "// comment"},
+		{offset + 1, "package p"},
+		{offset + 2, ""},
+		{offset + 3, "var x = 1"},
+		{offset + 5, "func f() {"},
+		{offset + 6, "\treturn"},
+		{offset + 7, "}"},
+	} {
+		got := s.lineText(test.line)
+		if got == nil || string(got) != test.want {
+			t.Errorf("line %d: got %q; want %q", test.line, got, test.want)
+		}
+	}
+	for _, line := range []uint{0, offset + 8, 1 << 20} {
+		if got := s.lineText(line); got != nil {
+			t.Errorf("line %d: got %q; want nil", line, got)
+		}
+	}
+
+	// a final newline doesn't start another line
+	s.init(strings.NewReader("a\n"), nil, nil, keepLines)
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+	if got := s.lineText(1); string(got) != "a" {
+		t.Errorf("line 1: got %q; want %q", got, "a")
+	}
+	if got := s.lineText(2); got != nil {
+		t.Errorf("line 2: got %q; want nil", got)
+	}
+}
+
+func TestTopLevelDecls(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+import (
+	"os"
+)
+
+// A comment.
+const c = 0
+
+var (
+	f = func() {
+		var x int
+		type T struct{}
+		_ = func() {}
+	}
+	g func()
+	h = struct{ f func() }{}
+)
+
+type (
+	T func()
+	U interface{ m(func()) }
+)
+
+func F() { go func() {}() }; var x = T(func() {})
+
+func (T) M() {}
+`
+
+	var s scanner
+	var got []string
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, scanComments)
+	s.declh = func(line, col uint, keyword string) {
+		got = append(got, fmt.Sprintf("%d:%d %s", line, col, keyword))
+	}
+	for s.next(); s.tok != _EOF; s.next() {
+	}
+
+	const want = "3:1 import 5:1 import 10:1 const 12:1 var 22:1 type 27:1 func 27:30 var 29:1 func"
+	if got := strings.Join(got, " "); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMaxLit(t *testing.T) {
+	long := strings.Repeat("a", 10000) // longer than the source buffer
+	src := "x := \"" + long + "\" + y" + long + "\nz = 12345678901234567890 + `short`"
+
+	var s scanner
+	var errs []string
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+	}, nil, 0)
+	s.maxLit = 8
+	var toks []string
+	for s.next(); s.tok != _EOF; s.next() {
+		switch s.tok {
+		case _Name, _Literal:
+			toks = append(toks, s.lit)
+		default:
+			toks = append(toks, s.tok.String())
+		}
+	}
+
+	const want = "x := \"aaaaaaa op yaaaaaaa ; z = 12345678 op `short` ;"
+	if got := strings.Join(toks, " "); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	const msg = "name or literal too long (more than 8 bytes)"
+	if got, want := strings.Join(errs, "; "), "1:10007: "+msg+"; 1:20012: "+msg+"; 2:25: "+msg; got != want {
+		t.Errorf("got errors %s\nwant %s", got, want)
+	}
+
+	// a truncated name is a name even if it spells a keyword
+	errs = nil
+	s.init(strings.NewReader("functional := 1"), func(line, col uint, msg string) {
+		errs = append(errs, fmt.Sprintf("%d:%d: %s", line, col, msg))
+	}, nil, 0)
+	s.maxLit = 4
+	s.next()
+	if s.tok != _Name || s.lit != "func" {
+		t.Errorf("got %s %q; want name \"func\"", s.tok, s.lit)
+	}
+	if s.next(); s.tok != _Define {
+		t.Errorf("got %s after the name; want :=", s.tok)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got errors %v; want one", errs)
+	}
+}
+
+func TestAttachTrivia(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"  \n",
+		"// comment only",
+		"x",
+		"package p\n\nimport \"fmt\" // fmt\n",
+		"x /* a\n b */ y\n",
+		"x /* comment */ ++\n\n\t// trailing\n",
+		"\tf(a, /* b */ c)\r\n}\r\n",
+		"s := `raw\nstring` + \"\\n\"; _ = s",
+		"/* lead */\npackage p\n\nfunc f() {\n\treturn // done\n}",
+	} {
+		var s scanner
+		s.init(strings.NewReader(src), func(line, col uint, msg string) {
+			t.Errorf("%q: %d:%d: %s", src, line, col, msg)
+		}, nil, attachTrivia)
+
+		var buf []byte
+		for {
+			s.next()
+			if s.tok == _Comment || s.tok == _Whitespace {
+				t.Errorf("%q: got %s token in attachTrivia mode", src, s.tok)
+			}
+			for _, r := range s.trivia {
+				if r.tok != _Comment && r.tok != _Whitespace {
+					t.Errorf("%q: got %s trivia", src, r.tok)
+				}
+				if r.line > s.line || r.line == s.line && r.col >= s.col {
+					t.Errorf("%q: trivia %q at %d:%d follows token %s at %d:%d", src, r.lit, r.line, r.col, s.tok, s.line, s.col)
+				}
+				buf = append(buf, r.lit...)
+			}
+			buf = append(buf, s.tokenText()...)
+			if s.tok == _EOF {
+				break
+			}
+		}
+
+		if got := string(buf); got != src {
+			t.Errorf("reconstructed %q; want %q", got, src)
+		}
+	}
+}
+
+func TestTriviaRuns(t *testing.T) {
+	const src = "x  // c\n\n/* d */y"
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, attachTrivia)
+
+	want := []struct {
+		tok    token
+		trivia []triviaRun
+	}{
+		{_Name, nil},
+		{_Semi, []triviaRun{{1, 2, _Whitespace, "  "}, {1, 4, _Comment, "// c"}}},
+		{_Name, []triviaRun{{2, 1, _Whitespace, "\n"}, {3, 1, _Comment, "/* d */"}}},
+		{_Semi, nil},
+		{_EOF, nil},
+	}
+	for i, w := range want {
+		s.next()
+		if s.tok != w.tok {
+			t.Fatalf("token %d: got %s; want %s", i, s.tok, w.tok)
+		}
+		if !reflect.DeepEqual(s.trivia, w.trivia) {
+			t.Errorf("token %d (%s): got trivia %v; want %v", i, s.tok, s.trivia, w.trivia)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	const src = "package p // doc\nfunc f(s string) { x := `a` + \"b\"; x += 'c' | 0x1f; s.m(1.5, 2i...) }"
+	var s scanner
+	s.init(strings.NewReader(src), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, highlight)
+
+	var got []string
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.class == classNone {
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s %s", s.class, s.tokenText()))
+	}
+	want := []string{
+		"keyword package", "name p", "comment // doc",
+		"keyword func", "name f", "punctuation (", "name s", "name string", "punctuation )", "punctuation {",
+		"name x", "operator :=", "string `a`", "operator +", `string "b"`, "punctuation ;",
+		"name x", "operator +=", "string 'c'", "operator |", "number 0x1f", "punctuation ;",
+		"name s", "punctuation .", "name m", "punctuation (", "number 1.5", "punctuation ,", "number 2i", "punctuation ...", "punctuation )",
+		"punctuation }",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the automatically inserted semicolons have no category
+	s.init(strings.NewReader("x\n"), func(line, col uint, msg string) {
+		t.Errorf("%d:%d: %s", line, col, msg)
+	}, nil, highlight)
+	for _, want := range []struct {
+		tok   token
+		class tokenClass
+	}{{_Name, className}, {_Semi, classNone}, {_EOF, classNone}} {
+		s.next()
+		if s.tok != want.tok || s.class != want.class {
+			t.Errorf("got %s (%s); want %s (%s)", s.tok, s.class, want.tok, want.class)
+		}
+	}
+}
+
+func TestBlankImports(t *testing.T) {
+	for _, src := range []string{
+		`import _ "net/http/pprof"`,
+		`import _"net/http/pprof"`,
+		"import (\n\t\"fmt\"\n\t_ `net/http/pprof`\n)",
+		"import (\n\t_ /* side effect */ \"net/http/pprof\" // for profiling\n)",
+	} {
+		// find the _ name and check that the import path follows it
+		list := scanAll(t, src)
+		found := false
+		for i, tok := range list {
+			if tok.tok != _Name || tok.lit != "_" {
+				continue
+			}
+			found = true
+			if i+1 >= len(list) {
+				t.Errorf("%s: no token after _", src)
+				break
+			}
+			if next := list[i+1]; next.tok != _Literal || next.lit != `"net/http/pprof"` && next.lit != "`net/http/pprof`" {
+				t.Errorf("%s: got %s %q after _; want import path literal", src, next.tok, next.lit)
+			}
+		}
+		if !found {
+			t.Errorf("%s: no _ name found", src)
+		}
+	}
+}
+
+func TestInternNames(t *testing.T) {
+	const src = "x := y + x\nfunc x() { y, x = x, yy }"
+
+	var s scanner
+	s.init(strings.NewReader(src), nil, nil, internNames)
+	var names []string
+	for s.next(); s.tok != _EOF; s.next() {
+		if s.tok == _Name {
+			names = append(names, s.lit)
+		}
+	}
+
+	if got, want := strings.Join(names, " "), "x y x x y x x yy"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if len(s.names) != 3 {
+		t.Errorf("got %d interned names; want 3", len(s.names))
+	}
+}
+
+// BenchmarkScanner scans parser.go, a large and representative
+// Go source file, in various scanner modes.
+func BenchmarkScanner(b *testing.B) {
+	src, err := ioutil.ReadFile("parser.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("default", func(b *testing.B) { benchmarkScanner(b, src, 0, false) })
+	b.Run("comments", func(b *testing.B) { benchmarkScanner(b, src, scanComments, false) })
+	b.Run("commh", func(b *testing.B) { benchmarkScanner(b, src, 0, true) })
+	b.Run("intern", func(b *testing.B) { benchmarkScanner(b, src, internNames, false) })
+	b.Run("trivia", func(b *testing.B) { benchmarkScanner(b, src, attachTrivia, false) })
+}
+
+func benchmarkScanner(b *testing.B, src []byte, mode scanMode, commh bool) {
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s scanner
+		s.init(bytes.NewReader(src), func(line, col uint, msg string) {
+			b.Fatalf("%d:%d: %s", line, col, msg)
+		}, nil, mode)
+		if commh {
+			s.commh = func(line, col uint, text string) {}
+		}
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+	}
+}
+
+func BenchmarkScanNames(b *testing.B) {
+	b.Run("default", func(b *testing.B) { benchmarkScanNames(b, 0) })
+	b.Run("intern", func(b *testing.B) { benchmarkScanNames(b, internNames) })
+}
+
+func benchmarkScanNames(b *testing.B, mode scanMode) {
+	src := strings.Repeat("if err != nil { return nil, err }\nx, err := fmt.Sprintf(format, x)\n", 1000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s scanner
+		s.init(strings.NewReader(src), nil, nil, mode)
+		for s.next(); s.tok != _EOF; s.next() {
+		}
+	}
+}
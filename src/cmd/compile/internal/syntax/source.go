@@ -14,6 +14,7 @@
 package syntax
 
 import (
+	"fmt"
 	"io"
 	"unicode/utf8"
 )
@@ -28,20 +29,44 @@ const colbase = 1
 //        suf     r0  r            w
 
 type source struct {
-	src  io.Reader
-	errh func(line, pos uint, msg string)
+	src      io.Reader
+	errh     func(line, pos uint, msg string)
+	tabwidth uint // if > 1, a tab advances the column to the next multiple of tabwidth; may be set after init
+	crlines  bool // if set, a '\r' not followed by '\n' also ends a line (for positions only); may be set after init
+	eols     bool // if set, the line ending styles seen are recorded in lf and crlf
+	maxLit   int  // if > 0, longer names and literals are reported and truncated to maxLit bytes; may be set after init
+	lf, crlf bool // a line ending in "\n" ("\r\n") was seen (only recorded if eols is set)
 
 	// source buffer
 	buf         [4 << 10]byte
 	offs        int   // source offset of buf
 	r0, r, w    int   // previous/current read and write buf positions, excluding sentinel
 	line0, line uint  // previous/current line
-	col0, col   uint  // previous/current column (byte offsets from line start)
+	col0, col   uint  // previous/current column (byte offsets from line start, see also tabwidth)
+	maxLen      uint  // length of the longest line ended so far (see maxLineLength)
 	ioerr       error // pending io error
 
 	// literal buffer
 	lit []byte // literal prefix
 	suf int    // literal suffix; suf >= 0 means we are scanning a literal
+	nul bool   // the literal contains (invalid) NUL bytes
+	big bool   // the literal is longer than maxLit
+
+	// token text buffer
+	txt    []byte // token text prefix
+	txtpos int    // token text start; txtpos >= 0 means we are recording token text
+
+	// rewind support (see mark)
+	keep    []byte // source bytes slid out of buf since the mark
+	keeppos int    // mark position in buf; keeppos >= 0 means we are keeping source bytes
+	pending []byte // source bytes to be read again before reading from src
+	perr    error  // io error to restore once pending is exhausted
+
+	// source text support (see scanner.lineText)
+	keepSrc  bool   // if set, all source bytes read from src are kept in srcBytes
+	srcBytes []byte // source bytes read so far
+	lineOffs []int  // offsets of the line starts in srcBytes[:indexed]
+	indexed  int    // length of the prefix of srcBytes indexed in lineOffs
 }
 
 // init initializes source to read from src and to report errors via errh.
@@ -49,16 +74,66 @@ type source struct {
 func (s *source) init(src io.Reader, errh func(line, pos uint, msg string)) {
 	s.src = src
 	s.errh = errh
+	s.tabwidth = 1
+	s.crlines = false
+	s.eols = false
+	s.maxLit = 0
+	s.keepSrc = false
+	s.srcBytes = nil
+	s.lineOffs = nil
+	s.indexed = 0
+	s.lf, s.crlf = false, false
 
 	s.buf[0] = utf8.RuneSelf // terminate with sentinel
 	s.offs = 0
 	s.r0, s.r, s.w = 0, 0, 0
 	s.line0, s.line = 0, linebase
 	s.col0, s.col = 0, colbase
+	s.maxLen = 0
 	s.ioerr = nil
 
 	s.lit = s.lit[:0]
 	s.suf = -1
+
+	s.txt = s.txt[:0]
+	s.txtpos = -1
+
+	s.keep = nil
+	s.keeppos = -1
+	s.pending = nil
+	s.perr = nil
+}
+
+// setLine sets the line number of the first line of the source to line
+// (instead of 1), for instance if the source is a fragment of a larger
+// file. It must be called after init and before reading any runes.
+func (s *source) setLine(line uint) {
+	s.line = line
+}
+
+// endLine records the length of the line ended by the line
+// terminator just read, excluding the terminator.
+func (s *source) endLine() {
+	n := s.col - 1 - colbase // s.col includes the terminator
+	if !s.crlines && s.r >= 2 && s.buf[s.r-1] == '\n' && s.buf[s.r-2] == '\r' {
+		n-- // the line ends in "\r\n"
+	}
+	if n > s.maxLen {
+		s.maxLen = n
+	}
+}
+
+// maxLineLength returns the length of the longest line read so far,
+// excluding its line terminator; after EOF, it is the length of the
+// longest line of the source. The length is measured in bytes, with
+// tabs expanded to the next tab stop if tabwidth > 1 (the same unit
+// as the column of a position).
+func (s *source) maxLineLength() int {
+	n := s.maxLen
+	if last := s.col - colbase; last > n {
+		n = last // the line being read
+	}
+	return int(n)
 }
 
 // ungetr ungets the most recently read rune.
@@ -112,12 +187,34 @@ redo:
 		s.col++
 		if b == 0 {
 			s.error("invalid NUL character")
+			if s.suf >= 0 {
+				s.nul = true // remove it from the literal
+			}
 			goto redo
 		}
 		if b == '\n' {
+			if !s.crlines || s.r < 2 || s.buf[s.r-2] != '\r' {
+				s.line++
+			} // else the line was counted at the '\r' already
+			s.endLine()
+			s.col = colbase
+			if s.eols {
+				if s.r >= 2 && s.buf[s.r-2] == '\r' {
+					s.crlf = true
+				} else {
+					s.lf = true
+				}
+			}
+		}
+		if b == '\r' && s.crlines {
 			s.line++
+			s.endLine()
 			s.col = colbase
 		}
+		if b == '\t' && s.tabwidth > 1 {
+			// advance to the next tab stop
+			s.col = (s.col-1-colbase)/s.tabwidth*s.tabwidth + s.tabwidth + colbase
+		}
 		return rune(b)
 	}
 
@@ -125,6 +222,7 @@ redo:
 	if s.r == s.w {
 		if s.ioerr != io.EOF {
 			s.error(s.ioerr.Error())
+			s.ioerr = io.EOF // report only once
 		}
 		return -1
 	}
@@ -161,10 +259,28 @@ func (s *source) fill() {
 		// (We see at most one ungetr call while reading
 		// a literal, so make sure s.r0 remains in buf.)
 		if s.suf >= 0 {
-			s.lit = append(s.lit, s.buf[s.suf:s.r0]...)
+			s.lit = s.clip(s.lit, s.buf[s.suf:s.r0])
 			s.suf = 1 // == s.r0 after slide below
 		}
+		// same for the token text, if any
+		if s.txtpos >= 0 {
+			if s.suf >= 0 {
+				s.txt = s.clip(s.txt, s.buf[s.txtpos:s.r0])
+			} else {
+				s.txt = append(s.txt, s.buf[s.txtpos:s.r0]...)
+			}
+			s.txtpos = 1 // == s.r0 after slide below
+		}
 		n := s.r0 - 1
+		// same for the source bytes since the mark, if any
+		if s.keeppos >= 0 {
+			if s.keeppos < n {
+				s.keep = append(s.keep, s.buf[s.keeppos:n]...)
+				s.keeppos = 0
+			} else {
+				s.keeppos -= n
+			}
+		}
 		copy(s.buf[:], s.buf[n:s.w])
 		s.offs += n
 		s.r0 = 1 // eqv: s.r0 -= n
@@ -172,12 +288,28 @@ func (s *source) fill() {
 		s.w -= n
 	}
 
+	// read source bytes from a rewind again first
+	if len(s.pending) > 0 {
+		n := copy(s.buf[s.w:len(s.buf)-1], s.pending)
+		s.pending = s.pending[n:]
+		s.w += n
+		s.buf[s.w] = utf8.RuneSelf // sentinel
+		if len(s.pending) == 0 {
+			s.pending = nil
+			s.ioerr, s.perr = s.perr, nil
+		}
+		return
+	}
+
 	// read more data: try a limited number of times
 	for i := 100; i > 0; i-- {
 		n, err := s.src.Read(s.buf[s.w : len(s.buf)-1]) // -1 to leave space for sentinel
 		if n < 0 {
 			panic("negative read") // incorrect underlying io.Reader implementation
 		}
+		if s.keepSrc {
+			s.srcBytes = append(s.srcBytes, s.buf[s.w:s.w+n]...)
+		}
 		s.w += n
 		if n > 0 || err != nil {
 			s.buf[s.w] = utf8.RuneSelf // sentinel
@@ -191,16 +323,129 @@ func (s *source) fill() {
 	s.ioerr = io.ErrNoProgress
 }
 
+// mark marks the current reading position. Until the next call of
+// mark, unmark, or rewind, the source keeps all bytes read after the
+// mark so that rewind can return to it without reading from src again.
+func (s *source) mark() {
+	s.keep = nil // may be shared with pending
+	s.keeppos = s.r
+}
+
+// unmark discards the most recent mark.
+func (s *source) unmark() {
+	s.keep = nil
+	s.keeppos = -1
+}
+
+// offset returns the source offset of the current reading position.
+func (s *source) offset() int {
+	return s.offs + s.r
+}
+
+// offset0 returns the source offset of the most recently read rune.
+func (s *source) offset0() int {
+	return s.offs + s.r0
+}
+
+// markOffset returns the source offset of the current mark,
+// or -1 if there is none.
+func (s *source) markOffset() int {
+	if s.keeppos < 0 {
+		return -1
+	}
+	return s.offs + s.keeppos - len(s.keep)
+}
+
+// rewind continues reading at the most recent mark, which is
+// discarded. The caller is responsible for restoring line and col.
+func (s *source) rewind() {
+	if len(s.keep) == 0 {
+		// all bytes since the mark are still in buf
+		s.r0, s.r = s.keeppos, s.keeppos
+	} else {
+		// Move the bytes since the mark back into buf, after one
+		// byte standing in for the (no longer used) previous rune.
+		data := append(s.keep, s.buf[s.keeppos:s.w]...)
+		s.offs = s.markOffset() - 1
+		n := copy(s.buf[1:len(s.buf)-1], data)
+		if n < len(data) {
+			s.pending = append(data[n:], s.pending...)
+		}
+		s.r0, s.r, s.w = 1, 1, 1+n
+		s.buf[0] = 0               // not a '\r' (see crlines)
+		s.buf[s.w] = utf8.RuneSelf // sentinel
+	}
+	if len(s.pending) > 0 && s.ioerr != nil {
+		// report the io error only after reading the pending bytes
+		s.ioerr, s.perr = nil, s.ioerr
+	}
+	s.unmark()
+	s.suf = -1
+	s.txtpos = -1
+}
+
 func (s *source) startLit() {
 	s.suf = s.r0
 	s.lit = s.lit[:0] // reuse lit
+	s.nul = false
+	s.big = false
+}
+
+// clip appends b to the literal or token text prefix p while a literal
+// is scanned, but only up to maxLit bytes in all if maxLit > 0, so that
+// very long literals don't use unbounded memory.
+func (s *source) clip(p, b []byte) []byte {
+	if s.maxLit > 0 && len(p)+len(b) > s.maxLit {
+		if n := s.maxLit - len(p); n > 0 {
+			b = b[:n]
+		} else {
+			b = nil
+		}
+		s.big = true
+	}
+	return append(p, b...)
 }
 
 func (s *source) stopLit() []byte {
 	lit := s.buf[s.suf:s.r]
-	if len(s.lit) > 0 {
-		lit = append(s.lit, lit...)
+	if len(s.lit) > 0 || s.maxLit > 0 && len(lit) > s.maxLit {
+		lit = s.clip(s.lit, lit)
+	}
+	if s.big {
+		s.error(fmt.Sprintf("name or literal too long (more than %d bytes)", s.maxLit))
+	}
+	if s.nul {
+		// The NUL bytes have been reported already; drop them
+		// so that they don't end up in a name or literal value.
+		// lit may share memory with buf, so make a copy.
+		clean := make([]byte, 0, len(lit))
+		for _, b := range lit {
+			if b != 0 {
+				clean = append(clean, b)
+			}
+		}
+		lit = clean
 	}
 	s.suf = -1 // no pending literal
 	return lit
 }
+
+// startText starts recording the token text at the most recently read rune.
+func (s *source) startText() {
+	s.txtpos = s.r0
+	s.txt = s.txt[:0] // reuse txt
+}
+
+// text returns the source text recorded since the last call of startText,
+// up to the current reading position. The result is only valid until the
+// next call of startText or getr.
+func (s *source) text() []byte {
+	if s.txtpos < 0 {
+		return nil
+	}
+	txt := s.buf[s.txtpos:s.r]
+	if len(s.txt) > 0 {
+		txt = append(s.txt, txt...)
+	}
+	return txt
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import "cmd/internal/src"
+
+// A Symbol describes a name declared at package level.
+type Symbol struct {
+	Name     string
+	Kind     SymbolKind
+	Exported bool
+	Recv     string // receiver base type name for methods; "" otherwise
+	Pos      src.Pos
+}
+
+// A SymbolKind is the kind of a Symbol.
+type SymbolKind uint8
+
+const (
+	ConstSymbol SymbolKind = iota + 1
+	VarSymbol
+	TypeSymbol
+	FuncSymbol
+	MethodSymbol
+)
+
+var symbolKinds = [...]string{
+	ConstSymbol:  "const",
+	VarSymbol:    "var",
+	TypeSymbol:   "type",
+	FuncSymbol:   "func",
+	MethodSymbol: "method",
+}
+
+func (k SymbolKind) String() string {
+	if int(k) < len(symbolKinds) && symbolKinds[k] != "" {
+		return symbolKinds[k]
+	}
+	return "symbol"
+}
+
+// ParseSymbols parses the Go source file src and returns the names
+// declared at package level, in source order. Blank identifiers are
+// omitted. The filename is only used for position information.
+// ParseSymbols does not type-check the file; if src contains syntax
+// errors, the first error is returned.
+func ParseSymbols(filename string, src_ []byte) ([]Symbol, error) {
+	f, err := ParseBytes(src.NewFileBase(filename, filename), src_, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Symbol
+	add := func(name *Name, kind SymbolKind, recv string) {
+		if name.Value != "_" {
+			list = append(list, Symbol{name.Value, kind, isExported(name.Value), recv, name.Pos()})
+		}
+	}
+
+	for _, decl := range f.DeclList {
+		switch d := decl.(type) {
+		case *ConstDecl:
+			for _, name := range d.NameList {
+				add(name, ConstSymbol, "")
+			}
+		case *VarDecl:
+			for _, name := range d.NameList {
+				add(name, VarSymbol, "")
+			}
+		case *TypeDecl:
+			add(d.Name, TypeSymbol, "")
+		case *FuncDecl:
+			if d.Recv == nil {
+				add(d.Name, FuncSymbol, "")
+			} else {
+				add(d.Name, MethodSymbol, recvBaseName(d.Recv.Type))
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// recvBaseName returns the name of the base type of the receiver
+// type typ, or "" if typ is not a (possibly parenthesized) type
+// name or pointer to a type name.
+func recvBaseName(typ Expr) string {
+	for {
+		switch t := typ.(type) {
+		case *ParenExpr:
+			typ = t.X
+		case *Operation: // pointer type
+			if t.Op != Mul || t.Y != nil {
+				return ""
+			}
+			typ = t.X
+		case *Name:
+			return t.Value
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSymbols(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+const (
+	A = iota
+	b
+	_
+)
+
+var X, y int
+
+type (
+	T struct{}
+	u = T
+)
+
+func F() {}
+func (T) M() {}
+func (t *T) m() {}
+func ((*u)) N() {}
+func init() { fmt.Println() }
+`
+
+	list, err := ParseSymbols("x.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, s := range list {
+		got = append(got, fmt.Sprintf("%s:%d:%d %s %s recv=%q exported=%v", s.Pos.Base().Filename(), s.Pos.Line(), s.Pos.Col(), s.Kind, s.Name, s.Recv, s.Exported))
+	}
+	want := []string{
+		`x.go:6:2 const A recv="" exported=true`,
+		`x.go:7:2 const b recv="" exported=false`,
+		`x.go:11:5 var X recv="" exported=true`,
+		`x.go:11:8 var y recv="" exported=false`,
+		`x.go:14:2 type T recv="" exported=true`,
+		`x.go:15:2 type u recv="" exported=false`,
+		`x.go:18:6 func F recv="" exported=true`,
+		`x.go:19:10 method M recv="T" exported=true`,
+		`x.go:20:13 method m recv="T" exported=false`,
+		`x.go:21:13 method N recv="u" exported=true`,
+		`x.go:22:6 func init recv="" exported=false`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d symbols; want %d:\n%q", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got %s; want %s", got[i], want[i])
+		}
+	}
+
+	// syntax errors are reported
+	if _, err := ParseSymbols("x.go", []byte("package p; func")); err == nil {
+		t.Error("no error for invalid source")
+	}
+}
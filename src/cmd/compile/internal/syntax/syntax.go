@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // Mode describes the parser mode.
@@ -16,7 +17,18 @@ type Mode uint
 
 // Modes supported by the parser.
 const (
-	CheckBranches Mode = 1 << iota // check correct use of labels, break, continue, and goto statements
+	CheckBranches     Mode = 1 << iota // check correct use of labels, break, continue, and goto statements
+	AllowIncomplete                    // parse incomplete source as far as possible and record incomplete expressions in File.Incomplete
+	Experimental                       // accept experimental syntax (see experimental.go)
+	DeclarationErrors                  // report redeclared package-level names
+	ParseComments                      // record all comments in File.Comments
+	ImportsOnly                        // stop parsing after the import declarations
+	SkipFuncBodies                     // don't parse function bodies; FuncDecl.Body is an empty block
+	AllErrors                          // also report follow-up errors at the end of the file, which are normally dropped
+	DuplicateCases                     // report duplicate literal cases in switch statements and duplicate types in type switches
+	UnreachableCode                    // report statements following a return, branch, panic, or os.Exit in the same block
+	AllowGenerics                      // accept type arguments and type parameters (experimental)
+	AssignmentErrors                   // report assignments with mismatched numbers of values and invalid := variables
 )
 
 // Error describes a syntax error. Error implements the error interface.
@@ -48,6 +60,11 @@ type PragmaHandler func(pos src.Pos, text string) Pragma
 // in //line directives. The returned value is used as the absolute filename.
 type FilenameHandler func(name string) string
 
+// A ProgressHandler is called after each top-level declaration
+// is parsed, with the number of declarations parsed so far and the
+// current source line. Declaration groups count as one declaration.
+type ProgressHandler func(count int, line uint)
+
 // Parse parses a single Go source file from src and returns the corresponding
 // syntax tree. If there are errors, Parse will return the first error found,
 // and a possibly partially constructed syntax tree, or nil if no correct package
@@ -62,24 +79,79 @@ type FilenameHandler func(name string) string
 // If a FilenameHandler is provided, it is called to process each filename
 // encountered in //line directives.
 //
-// The Mode argument is currently ignored.
+// If the AllowIncomplete mode is set, Parse always processes as much
+// source as possible, even if errh is nil. Selector expressions with a
+// missing selector (x.) and calls with a missing closing parenthesis
+// (f(a,) are recorded in File.Incomplete; this is useful for tools
+// such as code completion that work on source which is being edited.
 func Parse(base *src.PosBase, src io.Reader, errh ErrorHandler, pragh PragmaHandler, fileh FilenameHandler, mode Mode) (_ *File, first error) {
-	defer func() {
-		if p := recover(); p != nil {
-			if err, ok := p.(Error); ok {
-				first = err
-				return
-			}
-			panic(p)
+	defer recoverError(&first)
+
+	var p parser
+	p.init(base, src, errh, pragh, fileh, mode)
+	p.next()
+	return p.fileOrNil(), p.first
+}
+
+// recoverError recovers from the panic raised by the parser for
+// the first error if there is no error handler, and records the
+// error in *first. It must be called directly by a deferred call.
+func recoverError(first *error) {
+	if p := recover(); p != nil {
+		if err, ok := p.(Error); ok {
+			*first = err
+			return
 		}
-	}()
+		panic(p)
+	}
+}
+
+// ParseWithProgress behaves like Parse but it also calls progh, if not nil,
+// after each top-level declaration, for coarse progress reporting.
+func ParseWithProgress(base *src.PosBase, src io.Reader, errh ErrorHandler, pragh PragmaHandler, fileh FilenameHandler, progh ProgressHandler, mode Mode) (_ *File, first error) {
+	defer recoverError(&first)
 
 	var p parser
 	p.init(base, src, errh, pragh, fileh, mode)
+	p.progh = progh
 	p.next()
 	return p.fileOrNil(), p.first
 }
 
+// ParseType parses a single Go type from src and returns the
+// corresponding syntax tree. It is an error if src contains
+// anything but the type.
+func ParseType(src string) (_ Expr, first error) {
+	defer recoverError(&first)
+
+	var p parser
+	p.init(nil, strings.NewReader(src), nil, nil, nil, 0)
+	p.next()
+	typ := p.type_()
+	p.got(_Semi)
+	if p.tok != _EOF {
+		p.syntax_error("after type")
+	}
+	return typ, p.first
+}
+
+// ParseStmts parses a list of Go statements from src, as in a function
+// body, and returns the corresponding syntax trees. It is an error if
+// src contains anything but the statements. Labels and branch
+// statements are not checked.
+func ParseStmts(src string) (_ []Stmt, first error) {
+	defer recoverError(&first)
+
+	var p parser
+	p.init(nil, strings.NewReader(src), nil, nil, nil, 0)
+	p.next()
+	list := p.stmtList()
+	if p.tok != _EOF {
+		p.syntax_error("after statement list")
+	}
+	return list, p.first
+}
+
 // ParseBytes behaves like Parse but it reads the source from the []byte slice provided.
 func ParseBytes(base *src.PosBase, src []byte, errh ErrorHandler, pragh PragmaHandler, fileh FilenameHandler, mode Mode) (*File, error) {
 	return Parse(base, &bytesReader{src}, errh, pragh, fileh, mode)
@@ -110,3 +182,60 @@ func ParseFile(filename string, errh ErrorHandler, pragh PragmaHandler, mode Mod
 	defer f.Close()
 	return Parse(src.NewFileBase(filename, filename), f, errh, pragh, nil, mode)
 }
+
+// A Token is a single lexical token as returned by Tokenize.
+// The token kind is not exported; use the Is methods to classify
+// tokens and Tok.String() for a description.
+type Token struct {
+	Pos src.Pos
+	Tok token    // token kind
+	Lit string   // valid if IsName or IsLiteral, or for a ";" token
+	Op  Operator // valid if IsOperator, except for "=", ":=", and "<-"
+}
+
+// IsName reports whether t is an identifier.
+func (t Token) IsName() bool { return t.Tok == _Name }
+
+// IsLiteral reports whether t is a basic literal.
+func (t Token) IsLiteral() bool { return t.Tok == _Literal }
+
+// IsKeyword reports whether t is a Go keyword.
+func (t Token) IsKeyword() bool { return _Break <= t.Tok && t.Tok <= _Var }
+
+// IsOperator reports whether t is an operator, including assignment
+// operators, "++" and "--", "=", ":=", "<-", and "*".
+func (t Token) IsOperator() bool { return _Operator <= t.Tok && t.Tok <= _Star }
+
+// Tokens returns an iterator over the tokens of src, stopping before
+// the final EOF token. It has the shape of a range-over-func iterator
+// (func(yield func(Token) bool)) but is not declared as an iter.Seq,
+// which is not available in this tree; call it with a yield function
+// directly. Iteration stops early if yield returns false. Positions
+// have no base. If errh != nil, it is called with each scanning error;
+// otherwise errors are ignored.
+func Tokens(src_ []byte, errh ErrorHandler) func(yield func(Token) bool) {
+	return func(yield func(Token) bool) {
+		var s scanner
+		s.init(&bytesReader{src_}, func(line, col uint, msg string) {
+			if errh != nil {
+				errh(Error{src.MakePos(nil, line, col), msg})
+			}
+		}, nil, 0)
+		for s.next(); s.tok != _EOF; s.next() {
+			if !yield(Token{src.MakePos(nil, s.line, s.col), s.tok, s.lit, s.op}) {
+				return
+			}
+		}
+	}
+}
+
+// Tokenize returns the tokens of src as a slice; it is equivalent
+// to collecting the tokens produced by Tokens(src, errh).
+func Tokenize(src []byte, errh ErrorHandler) []Token {
+	var list []Token
+	Tokens(src, errh)(func(t Token) bool {
+		list = append(list, t)
+		return true
+	})
+	return list
+}
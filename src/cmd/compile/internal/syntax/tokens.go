@@ -12,6 +12,10 @@ const (
 	_ token = iota
 	_EOF
 
+	// comments and white space (only reported in scanComments and scanWhitespace mode)
+	_Comment
+	_Whitespace
+
 	// names and literals
 	_Name
 	_Literal
@@ -65,6 +69,9 @@ const (
 	_Type
 	_Var
 
+	// additional reserved words (see scanner.reserved)
+	_Reserved
+
 	tokenCount
 )
 
@@ -84,6 +91,10 @@ var tokstrings = [...]string{
 	// source control
 	_EOF: "EOF",
 
+	// comments and white space
+	_Comment:    "comment",
+	_Whitespace: "whitespace",
+
 	// names and literals
 	_Name:    "name",
 	_Literal: "literal",
@@ -136,6 +147,9 @@ var tokstrings = [...]string{
 	_Switch:      "switch",
 	_Type:        "type",
 	_Var:         "var",
+
+	// additional reserved words
+	_Reserved: "reserved word",
 }
 
 func (tok token) String() string {
@@ -149,6 +163,96 @@ func (tok token) String() string {
 	return s
 }
 
+// Keywords returns the list of Go keywords recognized by the scanner.
+func Keywords() []string {
+	var list []string
+	for tok := _Break; tok <= _Var; tok++ {
+		list = append(list, tokstrings[tok])
+	}
+	return list
+}
+
+// IsPredeclared reports whether name is a predeclared identifier, such
+// as a basic type (int), a constant (true), the zero value nil, or a
+// built-in function (len). Unlike keywords, predeclared identifiers are
+// scanned as names, and they may be redeclared.
+func IsPredeclared(name string) bool {
+	return predeclared[name]
+}
+
+var predeclared = map[string]bool{
+	// types
+	"bool":       true,
+	"byte":       true,
+	"complex64":  true,
+	"complex128": true,
+	"error":      true,
+	"float32":    true,
+	"float64":    true,
+	"int":        true,
+	"int8":       true,
+	"int16":      true,
+	"int32":      true,
+	"int64":      true,
+	"rune":       true,
+	"string":     true,
+	"uint":       true,
+	"uint8":      true,
+	"uint16":     true,
+	"uint32":     true,
+	"uint64":     true,
+	"uintptr":    true,
+
+	// constants
+	"true":  true,
+	"false": true,
+	"iota":  true,
+
+	// zero value
+	"nil": true,
+
+	// functions
+	"append":  true,
+	"cap":     true,
+	"close":   true,
+	"complex": true,
+	"copy":    true,
+	"delete":  true,
+	"imag":    true,
+	"len":     true,
+	"make":    true,
+	"new":     true,
+	"panic":   true,
+	"print":   true,
+	"println": true,
+	"real":    true,
+	"recover": true,
+}
+
+// Operators returns the list of operators and punctuation
+// recognized by the scanner.
+func Operators() []string {
+	var list []string
+	for op := Not; int(op) < len(opstrings); op++ {
+		if op == Tilde {
+			continue // only recognized in type constraints (AllowGenerics mode)
+		}
+		list = append(list, opstrings[op])
+		if op >= Add {
+			// arithmetic operators have an assignment form
+			list = append(list, opstrings[op]+"=")
+		}
+	}
+	list = append(list, opstrings[Add]+opstrings[Add], opstrings[Sub]+opstrings[Sub]) // ++, --
+	for tok := _Assign; tok <= _DotDotDot; tok++ {
+		if tok == _Arrow || tok == _Star {
+			continue // already included as Recv and Mul
+		}
+		list = append(list, tokstrings[tok])
+	}
+	return list
+}
+
 // Make sure we have at most 64 tokens so we can use them in a set.
 const _ uint64 = 1 << (tokenCount - 1)
 
@@ -170,10 +274,11 @@ const (
 type Operator uint
 
 const (
-	_    Operator = iota
-	Def           // :=
-	Not           // !
-	Recv          // <-
+	_     Operator = iota
+	Def            // :=
+	Not            // !
+	Recv           // <-
+	Tilde          // ~
 
 	// precOrOr
 	OrOr // ||
@@ -207,9 +312,10 @@ const (
 
 var opstrings = [...]string{
 	// prec == 0
-	Def:  ":", // : in :=
-	Not:  "!",
-	Recv: "<-",
+	Def:   ":", // : in :=
+	Not:   "!",
+	Recv:  "<-",
+	Tilde: "~",
 
 	// precOrOr
 	OrOr: "||",
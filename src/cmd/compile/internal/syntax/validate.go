@@ -0,0 +1,144 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"cmd/internal/src"
+)
+
+// Validate checks the syntax tree f for structural errors that are
+// not caught by the grammar and returns them sorted by position.
+// It reports:
+//    - constant declarations with missing or extra init expressions
+//    - constant declarations with a type but no init expressions
+//    - the label and branch errors reported in CheckBranches mode
+//
+// Validate is meant for trees parsed without CheckBranches; for trees
+// parsed with it, branch errors are reported twice.
+func Validate(f *File) []error {
+	var v validator
+	v.inspect(reflect.ValueOf(f), make(map[Node]bool))
+	sort.Stable(byPos(v.errs))
+	return v.errs
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) err(pos src.Pos, format string, args ...interface{}) {
+	v.errs = append(v.errs, Error{pos, fmt.Sprintf(format, args...)})
+}
+
+// inspect calls v.node for each node reachable from x, in depth-first order.
+// Nodes shared by several parents (such as the type of a field group) are
+// only visited once.
+func (v *validator) inspect(x reflect.Value, seen map[Node]bool) {
+	switch x.Kind() {
+	case reflect.Interface:
+		if !x.IsNil() {
+			v.inspect(x.Elem(), seen)
+		}
+
+	case reflect.Ptr:
+		if x.IsNil() {
+			return
+		}
+		if n, ok := x.Interface().(Node); ok {
+			if seen[n] {
+				return
+			}
+			seen[n] = true
+			v.node(n)
+		}
+		v.inspect(x.Elem(), seen)
+
+	case reflect.Struct:
+		typ := x.Type()
+		for i, nf := 0, x.NumField(); i < nf; i++ {
+			if typ.Field(i).PkgPath == "" { // exported
+				v.inspect(x.Field(i), seen)
+			}
+		}
+
+	case reflect.Slice:
+		for i, n := 0, x.Len(); i < n; i++ {
+			v.inspect(x.Index(i), seen)
+		}
+	}
+}
+
+func (v *validator) node(n Node) {
+	switch n := n.(type) {
+	case *File:
+		v.constDecls(n.DeclList)
+	case *DeclStmt:
+		v.constDecls(n.DeclList)
+	case *FuncDecl:
+		v.branches(n.Body)
+	case *FuncLit:
+		v.branches(n.Body)
+	}
+}
+
+// constDecls checks the constant declarations in list. A constant
+// declaration without init expressions repeats the init expressions
+// of the most recent declaration with init expressions in the same
+// group ("implicit repetition").
+func (v *validator) constDecls(list []Decl) {
+	var group *Group
+	var last *ConstDecl // most recent declaration with values in group
+	for _, decl := range list {
+		d, ok := decl.(*ConstDecl)
+		if !ok {
+			continue
+		}
+		if d.Group == nil || d.Group != group {
+			group = d.Group
+			last = nil
+		}
+
+		switch {
+		case d.Values != nil:
+			last = d
+		case d.Type != nil:
+			v.err(d.Pos(), "const declaration cannot have type without expression")
+			continue
+		case last == nil:
+			v.err(d.Pos(), "missing init expr for const declaration")
+			continue
+		}
+
+		nvals := 1
+		if l, ok := last.Values.(*ListExpr); ok {
+			nvals = len(l.ElemList)
+		}
+		switch {
+		case len(d.NameList) > nvals:
+			v.err(d.NameList[nvals].Pos(), "missing init expr for const declaration")
+		case len(d.NameList) < nvals && d == last:
+			v.err(d.Values.(*ListExpr).ElemList[len(d.NameList)].Pos(), "extra init expr")
+		case len(d.NameList) < nvals:
+			v.err(d.Pos(), "extra init expr") // repeated from last
+		}
+	}
+}
+
+func (v *validator) branches(body *BlockStmt) {
+	checkBranches(body, func(err error) {
+		v.errs = append(v.errs, err)
+	})
+}
+
+// byPos sorts a list of Errors by position.
+type byPos []error
+
+func (a byPos) Len() int           { return len(a) }
+func (a byPos) Less(i, j int) bool { return a[i].(Error).Pos.Before(a[j].(Error).Pos) }
+func (a byPos) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
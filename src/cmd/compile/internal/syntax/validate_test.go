@@ -0,0 +1,52 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		errs string // "line:col: msg" entries separated by "; "
+	}{
+		{"const c = 0", ""},
+		{"const (a = iota; b; c)", ""},
+		{"const (a, b = 1, 2; c, d)", ""},
+		{"func f() { goto L; L: }", ""},
+
+		{"const c int", "1:18: const declaration cannot have type without expression"},
+		{"const c", "1:18: missing init expr for const declaration"},
+		{"const (a; b = 0)", "1:19: missing init expr for const declaration"},
+		{"const (a = 0); const (b)", "1:34: missing init expr for const declaration"},
+		{"const (a, b = 0)", "1:22: missing init expr for const declaration"},
+		{"const (a = 0, 1)", "1:26: extra init expr"},
+		{"const (a, b = 0, 1; c)", "1:32: extra init expr"},
+		{"func f() { const c }", "1:29: missing init expr for const declaration"},
+
+		{"func f() { goto L }", "1:28: label L not defined"},
+		{"var f = func() { goto L }", "1:34: label L not defined"},
+		{"func f() { goto L; const c }\nfunc g() { M: }",
+			"1:28: label L not defined; 1:37: missing init expr for const declaration; 2:12: label M defined and not used"},
+	} {
+		src := "package p; " + test.src
+		f, err := Parse(nil, strings.NewReader(src), nil, nil, nil, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		var list []string
+		for _, err := range Validate(f) {
+			err := err.(Error)
+			list = append(list, fmt.Sprintf("%d:%d: %s", err.Pos.Line(), err.Pos.Col(), err.Msg))
+		}
+		if got := strings.Join(list, "; "); got != test.errs {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.src, got, test.errs)
+		}
+	}
+}
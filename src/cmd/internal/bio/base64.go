@@ -0,0 +1,54 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+)
+
+// WriteBase64 writes data to w encoded with enc, including any padding,
+// and returns the number of encoded bytes written. The encoded data is
+// streamed into the buffer of w; it is not built up in memory first.
+func (w *Writer) WriteBase64(data []byte, enc *base64.Encoding) (int, error) {
+	cw := countWriter{w: w.Writer}
+	e := base64.NewEncoder(enc, &cw)
+	if _, err := e.Write(data); err != nil {
+		return cw.n, err
+	}
+	err := e.Close() // write the final partial group
+	return cw.n, err
+}
+
+// ReadBase64 reads n bytes of data encoded with enc from r and returns
+// the decoded data. The encoded data is decoded as it is read; it is
+// not read into memory first. If fewer than n bytes remain, the error
+// is io.ErrUnexpectedEOF; invalid input is reported by a
+// base64.CorruptInputError. The offset of r advances by the number of
+// encoded bytes consumed, n if there is no error.
+func (r *Reader) ReadBase64(enc *base64.Encoding, n int) ([]byte, error) {
+	lr := &io.LimitedReader{R: r.Reader, N: int64(n)}
+	data, err := ioutil.ReadAll(base64.NewDecoder(enc, lr))
+	if err != nil {
+		return nil, err
+	}
+	if lr.N > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// A countWriter counts the bytes written through it.
+type countWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+func TestWriterWriteBase64(t *testing.T) {
+	data := make([]byte, 5000) // larger than the buffer when encoded
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		for _, n := range []int{0, 1, 2, 3, 4, 5, 6, 100, len(data)} {
+			w := NewMemWriter()
+			w.WriteByte('[')
+			size, err := w.WriteBase64(data[:n], enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.WriteByte(']')
+
+			want := enc.EncodeToString(data[:n])
+			if got := string(w.Bytes()); got != "["+want+"]" {
+				t.Errorf("%d bytes: got %q; want %q", n, got, "["+want+"]")
+			}
+			if size != len(want) {
+				t.Errorf("%d bytes: got size %d; want %d", n, size, len(want))
+			}
+		}
+	}
+}
+
+func TestReaderReadBase64(t *testing.T) {
+	var blobs [][]byte
+	w := NewMemWriter()
+	var sizes []int
+	for _, n := range []int{0, 1, 2, 3, 10, 4000} {
+		blob := make([]byte, n)
+		for i := range blob {
+			blob[i] = byte(i * 13)
+		}
+		size, err := w.WriteBase64(blob, base64.StdEncoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteByte('\n')
+		blobs = append(blobs, blob)
+		sizes = append(sizes, size)
+	}
+
+	r := NewMemReader(w.Bytes())
+	var off int64
+	for i, blob := range blobs {
+		data, err := r.ReadBase64(base64.StdEncoding, sizes[i])
+		if err != nil {
+			t.Fatalf("blob %d: %v", i, err)
+		}
+		if string(data) != string(blob) {
+			t.Errorf("blob %d: got %d bytes %q; want %d bytes", i, len(data), data, len(blob))
+		}
+		off += int64(sizes[i])
+		if got := r.Offset(); got != off {
+			t.Errorf("blob %d: got offset %d; want %d", i, got, off)
+		}
+		r.ReadByte() // newline
+		off++
+	}
+}
+
+func TestReaderReadBase64Errors(t *testing.T) {
+	// invalid input
+	r := NewMemReader([]byte("aGVs!G8="))
+	if _, err := r.ReadBase64(base64.StdEncoding, 8); err == nil {
+		t.Error("invalid input: no error")
+	} else if _, ok := err.(base64.CorruptInputError); !ok {
+		t.Errorf("invalid input: got %T; want base64.CorruptInputError", err)
+	}
+
+	// truncated input
+	r = NewMemReader([]byte("aGVsbG8h"))
+	if _, err := r.ReadBase64(base64.StdEncoding, 12); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated input: got %v; want io.ErrUnexpectedEOF", err)
+	}
+	r = NewMemReader([]byte("aGVsbG8"))
+	if _, err := r.ReadBase64(base64.StdEncoding, 8); err == nil {
+		t.Error("truncated group: no error")
+	}
+}
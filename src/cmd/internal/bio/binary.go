@@ -0,0 +1,208 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteUint16 writes v to w as 2 bytes in the given byte order.
+func (w *Writer) WriteUint16(order binary.ByteOrder, v uint16) error {
+	order.PutUint16(w.scratch[:2], v)
+	_, err := w.Write(w.scratch[:2])
+	return err
+}
+
+// WriteUint32 writes v to w as 4 bytes in the given byte order.
+func (w *Writer) WriteUint32(order binary.ByteOrder, v uint32) error {
+	order.PutUint32(w.scratch[:4], v)
+	_, err := w.Write(w.scratch[:4])
+	return err
+}
+
+// WriteUint64 writes v to w as 8 bytes in the given byte order.
+func (w *Writer) WriteUint64(order binary.ByteOrder, v uint64) error {
+	order.PutUint64(w.scratch[:8], v)
+	_, err := w.Write(w.scratch[:8])
+	return err
+}
+
+// WriteInt16 writes v to w as 2 bytes in the given byte order.
+func (w *Writer) WriteInt16(order binary.ByteOrder, v int16) error {
+	return w.WriteUint16(order, uint16(v))
+}
+
+// WriteInt32 writes v to w as 4 bytes in the given byte order.
+func (w *Writer) WriteInt32(order binary.ByteOrder, v int32) error {
+	return w.WriteUint32(order, uint32(v))
+}
+
+// WriteInt64 writes v to w as 8 bytes in the given byte order.
+func (w *Writer) WriteInt64(order binary.ByteOrder, v int64) error {
+	return w.WriteUint64(order, uint64(v))
+}
+
+// WriteCString writes s followed by a NUL byte. It returns the
+// number of bytes written, including the NUL. It is an error if
+// s contains a NUL byte; in that case nothing is written.
+func (w *Writer) WriteCString(s string) (int, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return 0, fmt.Errorf("C string %q contains NUL byte", s)
+	}
+	n, err := w.WriteString(s)
+	if err != nil {
+		return n, err
+	}
+	if err := w.WriteByte(0); err != nil {
+		return n, err
+	}
+	return n + 1, nil
+}
+
+// WriteField writes s as a field of exactly width bytes, padded on
+// the right with pad, as used by fixed-width formats such as the
+// headers of ar archives. It is an error if s is longer than width;
+// s is not truncated and nothing is written in that case.
+func (w *Writer) WriteField(s string, width int, pad byte) error {
+	if len(s) > width {
+		return fmt.Errorf("field %q does not fit into %d bytes", s, width)
+	}
+	if _, err := w.WriteString(s); err != nil {
+		return err
+	}
+	for i := len(s); i < width; i++ {
+		if err := w.WriteByte(pad); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVarint writes v to w as a signed varint, as encoded by
+// binary.PutVarint, and returns the number of bytes written.
+func (w *Writer) WriteVarint(v int64) (int, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return w.Write(buf[:n])
+}
+
+// WriteStruct writes v to w in the given byte order, like binary.Write.
+// v must be a fixed-size value or a pointer to one, such as a struct of
+// fixed-size fields; otherwise, nothing is written and an error is
+// returned. It writes binary.Size(v) bytes.
+func (w *Writer) WriteStruct(order binary.ByteOrder, v interface{}) error {
+	if binary.Size(v) < 0 {
+		return fmt.Errorf("cannot write %T: not a fixed-size value", v)
+	}
+	return binary.Write(w.Writer, order, v)
+}
+
+// ReadUint16 reads 2 bytes from r and decodes them in the given byte order.
+// If r is at EOF, the error is io.EOF; if fewer than 2 bytes remain,
+// it is io.ErrUnexpectedEOF.
+func (r *Reader) ReadUint16(order binary.ByteOrder) (uint16, error) {
+	if _, err := io.ReadFull(r.Reader, r.scratch[:2]); err != nil {
+		return 0, err
+	}
+	return order.Uint16(r.scratch[:2]), nil
+}
+
+// ReadUint32 is like ReadUint16 but reads 4 bytes.
+func (r *Reader) ReadUint32(order binary.ByteOrder) (uint32, error) {
+	if _, err := io.ReadFull(r.Reader, r.scratch[:4]); err != nil {
+		return 0, err
+	}
+	return order.Uint32(r.scratch[:4]), nil
+}
+
+// ReadUint64 is like ReadUint16 but reads 8 bytes.
+func (r *Reader) ReadUint64(order binary.ByteOrder) (uint64, error) {
+	if _, err := io.ReadFull(r.Reader, r.scratch[:8]); err != nil {
+		return 0, err
+	}
+	return order.Uint64(r.scratch[:8]), nil
+}
+
+// ReadInt16 is like ReadUint16 but returns a signed value.
+func (r *Reader) ReadInt16(order binary.ByteOrder) (int16, error) {
+	v, err := r.ReadUint16(order)
+	return int16(v), err
+}
+
+// ReadInt32 is like ReadUint32 but returns a signed value.
+func (r *Reader) ReadInt32(order binary.ByteOrder) (int32, error) {
+	v, err := r.ReadUint32(order)
+	return int32(v), err
+}
+
+// ReadInt64 is like ReadUint64 but returns a signed value.
+func (r *Reader) ReadInt64(order binary.ByteOrder) (int64, error) {
+	v, err := r.ReadUint64(order)
+	return int64(v), err
+}
+
+// ReadCString reads bytes up to and including the next NUL byte and
+// returns them as a string, without the NUL. If r is at EOF, the error
+// is io.EOF; if EOF is reached before the NUL, it is io.ErrUnexpectedEOF.
+func (r *Reader) ReadCString() (string, error) {
+	s, err := r.ReadString(0)
+	if err != nil {
+		if err == io.EOF && s != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return s[:len(s)-1], nil
+}
+
+var errVarintOverflow = errors.New("varint overflows a 64-bit integer")
+
+// ReadVarint reads a signed varint, as encoded by binary.PutVarint,
+// from r. If r is at EOF, the error is io.EOF; if EOF is reached within
+// the varint, it is io.ErrUnexpectedEOF.
+func (r *Reader) ReadVarint() (int64, error) {
+	var x uint64
+	var s uint
+	for i := 0; ; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if i == binary.MaxVarintLen64-1 && c > 1 {
+			return 0, errVarintOverflow
+		}
+		if c < 0x80 {
+			x |= uint64(c) << s
+			break
+		}
+		x |= uint64(c&0x7f) << s
+		s += 7
+	}
+	v := int64(x >> 1)
+	if x&1 != 0 {
+		v = ^v
+	}
+	return v, nil
+}
+
+// ReadStruct reads binary.Size(v) bytes from r and decodes them in the
+// given byte order into v, like binary.Read. v must be a pointer to a
+// fixed-size value, such as a struct of fixed-size fields; otherwise,
+// nothing is read and an error is returned. If r is at EOF, the error
+// is io.EOF; if fewer than binary.Size(v) bytes remain, it is
+// io.ErrUnexpectedEOF.
+func (r *Reader) ReadStruct(order binary.ByteOrder, v interface{}) error {
+	if binary.Size(v) < 0 {
+		return fmt.Errorf("cannot read %T: not a pointer to a fixed-size value", v)
+	}
+	return binary.Read(r.Reader, order, v)
+}
@@ -0,0 +1,394 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriterInts(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		name, cleanup := tempFile(t, "")
+		defer cleanup()
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteByte('x')
+		w.WriteUint16(order, 0x1234)
+		w.WriteUint32(order, 0x12345678)
+		w.WriteUint64(order, 0x123456789abcdef0)
+		w.WriteInt16(order, -2)
+		w.WriteInt32(order, -3)
+		w.WriteInt64(order, -4)
+		if off := w.Offset(); off != 1+2*(2+4+8) {
+			t.Errorf("%v: got offset %d; want %d", order, off, 1+2*(2+4+8))
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := data[1:]
+		if v := order.Uint16(b); v != 0x1234 {
+			t.Errorf("%v: got uint16 %#x", order, v)
+		}
+		b = b[2:]
+		if v := order.Uint32(b); v != 0x12345678 {
+			t.Errorf("%v: got uint32 %#x", order, v)
+		}
+		b = b[4:]
+		if v := order.Uint64(b); v != 0x123456789abcdef0 {
+			t.Errorf("%v: got uint64 %#x", order, v)
+		}
+		b = b[8:]
+		if v := int16(order.Uint16(b)); v != -2 {
+			t.Errorf("%v: got int16 %d", order, v)
+		}
+		b = b[2:]
+		if v := int32(order.Uint32(b)); v != -3 {
+			t.Errorf("%v: got int32 %d", order, v)
+		}
+		b = b[4:]
+		if v := int64(order.Uint64(b)); v != -4 {
+			t.Errorf("%v: got int64 %d", order, v)
+		}
+	}
+}
+
+func TestReaderInts(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		name, cleanup := tempFile(t, "")
+		defer cleanup()
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteUint16(order, 0xfedc)
+		w.WriteUint32(order, 0xfedcba98)
+		w.WriteUint64(order, 0xfedcba9876543210)
+		w.WriteInt16(order, -1<<15)
+		w.WriteInt32(order, -1<<31)
+		w.WriteInt64(order, -1<<63)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		if v, err := r.ReadUint16(order); v != 0xfedc || err != nil {
+			t.Errorf("%v: ReadUint16 = %#x, %v", order, v, err)
+		}
+		if v, err := r.ReadUint32(order); v != 0xfedcba98 || err != nil {
+			t.Errorf("%v: ReadUint32 = %#x, %v", order, v, err)
+		}
+		if v, err := r.ReadUint64(order); v != 0xfedcba9876543210 || err != nil {
+			t.Errorf("%v: ReadUint64 = %#x, %v", order, v, err)
+		}
+		if v, err := r.ReadInt16(order); v != -1<<15 || err != nil {
+			t.Errorf("%v: ReadInt16 = %d, %v", order, v, err)
+		}
+		if v, err := r.ReadInt32(order); v != -1<<31 || err != nil {
+			t.Errorf("%v: ReadInt32 = %d, %v", order, v, err)
+		}
+		if v, err := r.ReadInt64(order); v != -1<<63 || err != nil {
+			t.Errorf("%v: ReadInt64 = %d, %v", order, v, err)
+		}
+		if _, err := r.ReadUint16(order); err != io.EOF {
+			t.Errorf("%v: got %v at EOF; want io.EOF", order, err)
+		}
+	}
+}
+
+func TestReaderIntsTruncated(t *testing.T) {
+	r, cleanup := openTemp(t, "abc")
+	defer cleanup()
+	if _, err := r.ReadUint32(binary.LittleEndian); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v; want io.ErrUnexpectedEOF", err)
+	}
+
+	r, cleanup = openTemp(t, "abcdefg")
+	defer cleanup()
+	if _, err := r.ReadInt64(binary.BigEndian); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v; want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	values := []int64{0, 1, -1, 63, -64, 64, -65, 1 << 40, -1 << 40, 1<<63 - 1, -1 << 63}
+
+	w := NewMemWriter()
+	var size int
+	for _, v := range values {
+		n, err := w.WriteVarint(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf [binary.MaxVarintLen64]byte
+		if want := binary.PutVarint(buf[:], v); n != want {
+			t.Errorf("WriteVarint(%d) wrote %d bytes; want %d", v, n, want)
+		}
+		size += n
+	}
+	data := w.Bytes()
+	if len(data) != size {
+		t.Errorf("got %d bytes; want %d", len(data), size)
+	}
+
+	r := NewMemReader(data)
+	for _, want := range values {
+		if v, err := r.ReadVarint(); v != want || err != nil {
+			t.Errorf("ReadVarint() = %d, %v; want %d, nil", v, err, want)
+		}
+	}
+	if _, err := r.ReadVarint(); err != io.EOF {
+		t.Errorf("got %v at EOF; want io.EOF", err)
+	}
+}
+
+func TestReaderVarintErrors(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		err  string
+	}{
+		{"\x80", io.ErrUnexpectedEOF.Error()},
+		{"\xff\xff\xff", io.ErrUnexpectedEOF.Error()},
+		{"\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01", ""}, // largest 10-byte varint
+		{"\xff\xff\xff\xff\xff\xff\xff\xff\xff\x02", "varint overflows a 64-bit integer"},
+		{"\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01", "varint overflows a 64-bit integer"},
+	} {
+		r := NewMemReader([]byte(test.data))
+		_, err := r.ReadVarint()
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q: got error %q; want %q", test.data, got, test.err)
+		}
+	}
+}
+
+func TestWriterCString(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"main", "", "runtime.gopanic", "ä世"} {
+		if n, err := w.WriteCString(s); n != len(s)+1 || err != nil {
+			t.Errorf("WriteCString(%q) = %d, %v; want %d, nil", s, n, err, len(s)+1)
+		}
+	}
+	if n, err := w.WriteCString("a\x00b"); n != 0 || err == nil {
+		t.Errorf("WriteCString with NUL = %d, %v; want 0, error", n, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "main\x00\x00runtime.gopanic\x00ä世\x00"
+	if data, _ := ioutil.ReadFile(name); string(data) != want {
+		t.Errorf("got %q; want %q", data, want)
+	}
+
+	// read them back
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, want := range []string{"main", "", "runtime.gopanic", "ä世"} {
+		if s, err := r.ReadCString(); s != want || err != nil {
+			t.Errorf("ReadCString() = %q, %v; want %q, nil", s, err, want)
+		}
+	}
+	if _, err := r.ReadCString(); err != io.EOF {
+		t.Errorf("got %v at EOF; want io.EOF", err)
+	}
+}
+
+func TestWriterField(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		s     string
+		width int
+		pad   byte
+	}{
+		{"__.PKGDEF", 16, ' '},
+		{"644", 8, ' '},
+		{"", 4, '0'},
+		{"exact", 5, ' '},
+	} {
+		if err := w.WriteField(f.s, f.width, f.pad); err != nil {
+			t.Errorf("WriteField(%q, %d) = %v", f.s, f.width, err)
+		}
+	}
+	if err := w.WriteField("1234567890", 6, ' '); err == nil {
+		t.Error("WriteField with over-long value succeeded")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "__.PKGDEF       644     0000exact"
+	if data, _ := ioutil.ReadFile(name); string(data) != want {
+		t.Errorf("got %q; want %q", data, want)
+	}
+}
+
+func TestReaderCString(t *testing.T) {
+	r, cleanup := openTemp(t, "abc\x00\x00unterminated")
+	defer cleanup()
+
+	for _, test := range []struct {
+		want string
+		err  error
+		off  int64 // offset after the read
+	}{
+		{"abc", nil, 4},
+		{"", nil, 5},
+		{"", io.ErrUnexpectedEOF, 17},
+		{"", io.EOF, 17},
+	} {
+		s, err := r.ReadCString()
+		if s != test.want || err != test.err {
+			t.Errorf("ReadCString() = %q, %v; want %q, %v", s, err, test.want, test.err)
+		}
+		if off := r.Offset(); off != test.off {
+			t.Errorf("got offset %d; want %d", off, test.off)
+		}
+	}
+}
+
+// header is a fixed-size file header, for testing ReadStruct and WriteStruct.
+type header struct {
+	Magic   [4]byte
+	Version uint16
+	Flags   uint16
+	Size    int64
+	Offsets [2]uint32
+}
+
+func TestReaderReadStruct(t *testing.T) {
+	data := []byte{
+		'o', 'b', 'j', 0,
+		0x01, 0x02,
+		0x03, 0x04,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		0, 0, 0, 1,
+		0, 0, 1, 0,
+		'x', // trailing data
+	}
+	for _, test := range []struct {
+		order binary.ByteOrder
+		want  header
+	}{
+		{binary.BigEndian, header{[4]byte{'o', 'b', 'j', 0}, 0x0102, 0x0304, -2, [2]uint32{1, 256}}},
+		{binary.LittleEndian, header{[4]byte{'o', 'b', 'j', 0}, 0x0201, 0x0403, -1<<56 - 1, [2]uint32{1 << 24, 1 << 16}}},
+	} {
+		r := NewMemReader(data)
+		var h header
+		if err := r.ReadStruct(test.order, &h); err != nil {
+			t.Fatalf("%v: %v", test.order, err)
+		}
+		if h != test.want {
+			t.Errorf("%v: got %+v; want %+v", test.order, h, test.want)
+		}
+		if off := r.Offset(); off != int64(binary.Size(h)) {
+			t.Errorf("%v: got offset %d; want %d", test.order, off, binary.Size(h))
+		}
+	}
+}
+
+func TestReaderReadStructErrors(t *testing.T) {
+	var h header
+	r := NewMemReader(make([]byte, binary.Size(h)-1))
+	if err := r.ReadStruct(binary.LittleEndian, &h); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated: got %v; want io.ErrUnexpectedEOF", err)
+	}
+	r = NewMemReader(nil)
+	if err := r.ReadStruct(binary.LittleEndian, &h); err != io.EOF {
+		t.Errorf("at EOF: got %v; want io.EOF", err)
+	}
+
+	// variable-size types
+	r = NewMemReader(make([]byte, 100))
+	for _, v := range []interface{}{
+		&struct{ S []byte }{},
+		&struct{ N int }{},
+		new(string),
+	} {
+		if err := r.ReadStruct(binary.LittleEndian, v); err == nil {
+			t.Errorf("%T: got no error", v)
+		}
+	}
+	if off := r.Offset(); off != 0 {
+		t.Errorf("got offset %d after errors; want 0", off)
+	}
+}
+
+func TestWriterWriteStruct(t *testing.T) {
+	h := header{[4]byte{'o', 'b', 'j', 0}, 3, 0x8001, -12345678901, [2]uint32{1 << 31, 42}}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		w := NewMemWriter()
+		w.WriteByte('!')
+		if err := w.WriteStruct(order, h); err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if err := w.WriteStruct(order, &h); err != nil { // pointers work, too
+			t.Fatalf("%v: %v", order, err)
+		}
+		size := binary.Size(h)
+		if off := w.Offset(); off != int64(1+2*size) {
+			t.Errorf("%v: got offset %d; want %d", order, off, 1+2*size)
+		}
+
+		r := NewMemReader(w.Bytes())
+		r.ReadByte()
+		for i := 0; i < 2; i++ {
+			var got header
+			if err := r.ReadStruct(order, &got); err != nil {
+				t.Fatalf("%v: %v", order, err)
+			}
+			if got != h {
+				t.Errorf("%v: read %+v; want %+v", order, got, h)
+			}
+		}
+		if eof, _ := r.AtEOF(); !eof {
+			t.Errorf("%v: extra data after structs", order)
+		}
+	}
+
+	// variable-size types
+	w := NewMemWriter()
+	for _, v := range []interface{}{
+		struct{ S []byte }{[]byte("abc")},
+		&struct{ N int }{1},
+		"string",
+	} {
+		if err := w.WriteStruct(binary.LittleEndian, v); err == nil {
+			t.Errorf("%T: got no error", v)
+		}
+	}
+	if off := w.Offset(); off != 0 {
+		t.Errorf("got offset %d after errors; want 0", off)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+// BeginBlock, EndBlock, Key, and KeyValue write structured, JSON-like
+// text such as
+//
+//	{
+//		"name": "p",
+//		"files": [
+//			"a.go",
+//			"b.go"
+//		]
+//	}
+//
+// They take care of the separators between the items of a block and
+// of the indentation, one tab per level. The keys and values are
+// written as given; in particular, they are not quoted. Write errors
+// are recorded by w and reported by Flush or Close.
+
+// BeginBlock starts a block with the given opening byte, typically '{'
+// or '['. Inside another block, the new block is an item of it.
+func (w *Writer) BeginBlock(open byte) {
+	w.beginItem()
+	w.WriteByte(open)
+	w.blocks = append(w.blocks, false)
+}
+
+// EndBlock ends the innermost open block with the given closing byte.
+// It panics if there is no open block.
+func (w *Writer) EndBlock(close byte) {
+	if len(w.blocks) == 0 {
+		panic("bio: EndBlock without BeginBlock")
+	}
+	if w.blockKey {
+		panic("bio: Key not followed by BeginBlock")
+	}
+	hasItems := w.blocks[len(w.blocks)-1]
+	w.blocks = w.blocks[:len(w.blocks)-1]
+	if hasItems {
+		w.newline()
+	}
+	w.WriteByte(close)
+}
+
+// KeyValue writes the item k: v, or only v if k is empty, for instance
+// for the elements of a '[' block.
+func (w *Writer) KeyValue(k, v string) {
+	w.item()
+	if k != "" {
+		w.WriteString(k)
+		w.WriteString(": ")
+	}
+	w.WriteString(v)
+}
+
+// Key writes the key k of an item whose value is a block. The block
+// must be started by the next call, which must be BeginBlock; the
+// other methods panic otherwise.
+func (w *Writer) Key(k string) {
+	w.item()
+	w.WriteString(k)
+	w.WriteString(": ")
+	w.blockKey = true
+}
+
+// item starts a new item in the innermost open block, if any.
+func (w *Writer) item() {
+	if w.blockKey {
+		panic("bio: Key not followed by BeginBlock")
+	}
+	n := len(w.blocks)
+	if n == 0 {
+		return
+	}
+	if w.blocks[n-1] {
+		w.WriteByte(',')
+	}
+	w.blocks[n-1] = true
+	w.newline()
+}
+
+// beginItem is like item but continues the item started by Key, if any.
+func (w *Writer) beginItem() {
+	if w.blockKey {
+		w.blockKey = false
+		return
+	}
+	w.item()
+}
+
+// newline starts a new line indented by the number of open blocks.
+func (w *Writer) newline() {
+	w.WriteByte('\n')
+	for range w.blocks {
+		w.WriteByte('\t')
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import "testing"
+
+func TestWriterBlocks(t *testing.T) {
+	w := NewMemWriter()
+	w.BeginBlock('{')
+	w.KeyValue(`"name"`, `"p"`)
+	w.Key(`"files"`)
+	w.BeginBlock('[')
+	w.KeyValue("", `"a.go"`)
+	w.KeyValue("", `"b.go"`)
+	w.EndBlock(']')
+	w.Key(`"deps"`)
+	w.BeginBlock('[')
+	w.EndBlock(']')
+	w.BeginBlock('{')
+	w.KeyValue(`"x"`, "1")
+	w.EndBlock('}')
+	w.EndBlock('}')
+	w.WriteByte('\n')
+
+	const want = `{
+	"name": "p",
+	"files": [
+		"a.go",
+		"b.go"
+	],
+	"deps": [],
+	{
+		"x": 1
+	}
+}
+`
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriterEndBlockPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("EndBlock without BeginBlock didn't panic")
+		}
+	}()
+	w := NewMemWriter()
+	w.EndBlock('}')
+}
+
+func TestWriterEmptyValue(t *testing.T) {
+	w := NewMemWriter()
+	w.BeginBlock('{')
+	w.KeyValue("a", "")
+	w.KeyValue("b", "2")
+	w.EndBlock('}')
+
+	const want = "{\n\ta: ,\n\tb: 2\n}"
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestWriterKeyPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Key not followed by BeginBlock didn't panic")
+		}
+	}()
+	w := NewMemWriter()
+	w.BeginBlock('{')
+	w.Key("a")
+	w.KeyValue("b", "2")
+}
@@ -7,20 +7,96 @@ package bio
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 )
 
 // Reader implements a seekable buffered io.Reader.
+//
+// The methods of the embedded bufio.Reader are available on a Reader.
+// For instance, ReadRune reads a UTF-8 encoded rune and reports its
+// size in bytes, and UnreadRune pushes it back. Offset accounts for
+// the buffered data and is correct after any of these methods.
 type Reader struct {
-	f *os.File
+	f    input
+	name string // file name if the Reader was created by Open
 	*bufio.Reader
+
+	seekChecked bool            // seekable is valid
+	seekable    bool            // f supports seeking
+	progress    *progressReader // if set, the Reader reads from f through progress (see SetProgress)
+	gz          *gzip.Reader    // if set, the Reader decompresses f (see OpenCompressed)
+	bufSize     int             // buffer size if set by Grow; 0 means the bufio default
+	tee         io.Writer       // if set, data read into the buffer is also written to tee (see Tee)
+	marks       []int64         // offsets saved by PushMark
+	long        []byte          // reused by EachLine for lines longer than the buffer
+	dec         *gob.Decoder    // created by the first ReadGob
+
+	scratch [8]byte // for reading fixed-size integers
 }
 
 // Writer implements a seekable buffered io.Writer.
+//
+// The methods of the embedded bufio.Writer are available on a Writer.
+// In particular, WriteString and WriteRune write directly into the
+// buffer; there is no need to convert their arguments to []byte first.
+//
+// Errors are sticky: once writing to the underlying file fails, all
+// subsequent writes and flushes return the same error without writing
+// any more data, and Err reports the error. Thus a caller may ignore
+// the errors of individual writes and check Err (or the result of
+// Close) at the end.
 type Writer struct {
-	f *os.File
+	f file
 	*bufio.Writer
+
+	name    string       // if set, f is a temporary file to be renamed to name (see CreateTemp)
+	aborted bool         // Abort was called
+	sync    bool         // if set, Close syncs f and the directory of name to disk (see CreateAtomic)
+	gz      *gzip.Writer // if set, the Writer compresses the output written to f (see CreateCompressed)
+	lazy    int          // if > 0, Flush only flushes if at least lazy bytes are buffered (see SetLazyFlush)
+	rec     *recordSink  // if set, the bufio.Writer writes through rec (see SetRecordBoundary)
+	crc     *crcWriter   // if set, the bufio.Writer writes through crc (see SetCRCTrailer)
+	out     io.Writer    // if set, the bufio.Writer writes to out instead of gz or f (see setDst)
+	enc     *gob.Encoder // created by the first WriteGob
+	sink    bufSink      // the bufio.Writer writes through sink (see OverwriteBuffered)
+
+	// block structure (see BeginBlock)
+	blocks   []bool // for each open block, whether it has items yet
+	blockKey bool   // a key was written, its block value follows
+
+	scratch [8]byte // for writing fixed-size integers
+	tmp     []byte  // reused for building output (see WriteGoString and WriteRepeat)
+}
+
+// An input is the input of a Reader. It is an *os.File, or a memReader
+// for Readers created by NewMemReader.
+type input interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+	io.Closer
+}
+
+// A file is the output of a Writer. It is an *os.File, or a memFile
+// for Writers created by NewMemWriter.
+type file interface {
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Truncate(size int64) error
+	Sync() error
+	Name() string
 }
 
 // Create creates the file named name and returns a Writer
@@ -30,7 +106,43 @@ func Create(name string) (*Writer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Writer{f: f, Writer: bufio.NewWriter(f)}, nil
+	return NewWriter(f), nil
+}
+
+// CreateTemp is like Create but the output is written to a temporary
+// file in the same directory as name. Close renames the temporary file
+// to name if all output was written successfully. If an error occurred
+// or Abort was called, Close removes the temporary file instead and
+// leaves any existing file named name untouched.
+func CreateTemp(name string) (*Writer, error) {
+	for i := 0; ; i++ {
+		tmp := fmt.Sprintf("%s.%d.%d.tmp", name, os.Getpid(), i)
+		f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		w := NewWriter(f)
+		w.name = name
+		return w, nil
+	}
+}
+
+// CreateAtomic is like CreateTemp, but Close also makes sure that the
+// output survives a system crash: It syncs the temporary file to disk
+// before renaming it to name, and it syncs the directory containing
+// name afterwards (on systems which support syncing directories).
+// After a crash, the file named name either has its old content or
+// the complete new content.
+func CreateAtomic(name string) (*Writer, error) {
+	w, err := CreateTemp(name)
+	if err != nil {
+		return nil, err
+	}
+	w.sync = true
+	return w, nil
 }
 
 // Open returns a Reader for the file named name.
@@ -39,10 +151,76 @@ func Open(name string) (*Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Reader{f: f, Reader: bufio.NewReader(f)}, nil
+	r := NewReader(f)
+	r.name = name
+	return r, nil
+}
+
+// Reopen closes the file underlying r and opens the file with the same
+// name again, for instance after the file was replaced. Reading continues
+// at the beginning of the new file. Reopen fails for Readers that were
+// not created by Open. If the file cannot be opened, r continues to read
+// from the old file, which is not closed.
+func (r *Reader) Reopen() error {
+	if r.name == "" {
+		return fmt.Errorf("reopen: file name unknown")
+	}
+	f, err := os.Open(r.name)
+	if err != nil {
+		return err
+	}
+	if r.gz != nil {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		err = r.f.Close()
+		r.f = f
+		r.gz = gz
+		r.Reset(r.src())
+		return err
+	}
+	err = r.f.Close()
+	r.f = f
+	r.seekChecked = false
+	if r.progress != nil {
+		r.progress.f = f
+		r.progress.seek(0)
+	}
+	r.Reset(r.src())
+	return err
+}
+
+// NewReader returns a Reader for the open file f.
+// Closing the Reader closes f.
+func NewReader(f *os.File) *Reader {
+	r := &Reader{Reader: bufio.NewReader(f)}
+	if f != nil {
+		r.f = f // r.f must be nil (not a nil *os.File) if there is no file
+	}
+	return r
+}
+
+// NewWriter returns a Writer for the open file f.
+// Closing the Writer flushes it and closes f.
+func NewWriter(f *os.File) *Writer {
+	return newWriter(f, f)
+}
+
+// newWriter returns a Writer for f whose buffer is written to dst,
+// which is f or writes to f.
+func newWriter(f file, dst io.Writer) *Writer {
+	w := &Writer{f: f}
+	w.sink.w = dst
+	w.Writer = bufio.NewWriter(&w.sink)
+	return w
 }
 
 func (r *Reader) Seek(offset int64, whence int) int64 {
+	if r.gz != nil {
+		log.Fatalf("seeking in input: %v", errCompressed)
+	}
 	if whence == 1 {
 		offset -= int64(r.Buffered())
 	}
@@ -50,12 +228,21 @@ func (r *Reader) Seek(offset int64, whence int) int64 {
 	if err != nil {
 		log.Fatalf("seeking in output: %v", err)
 	}
-	r.Reset(r.f)
+	if r.progress != nil {
+		r.progress.seek(off)
+	}
+	r.Reset(r.src())
 	return off
 }
 
 func (w *Writer) Seek(offset int64, whence int) int64 {
-	if err := w.Flush(); err != nil {
+	if w.gz != nil {
+		log.Fatalf("seeking in output: %v", errCompressed)
+	}
+	if w.crc != nil {
+		log.Fatalf("seeking in output: %v", errCRCTrailer)
+	}
+	if err := w.FlushNow(); err != nil {
 		log.Fatalf("writing output: %v", err)
 	}
 	off, err := w.f.Seek(offset, whence)
@@ -65,7 +252,190 @@ func (w *Writer) Seek(offset int64, whence int) int64 {
 	return off
 }
 
+// Seekable reports whether the underlying file supports seeking.
+// It returns false for pipes and terminals, for instance, in which case
+// Seek and Offset must not be used. It also returns false for Readers
+// created by OpenCompressed. The result is computed only once.
+func (r *Reader) Seekable() bool {
+	if !r.seekChecked {
+		_, err := r.f.Seek(0, 1)
+		r.seekable = err == nil && r.gz == nil
+		r.seekChecked = true
+	}
+	return r.seekable
+}
+
+// PushMark saves the current offset of r on a stack of marks, from
+// which PopMark returns to it. Marks can be nested, for instance for
+// backtracking within nested sections of a file. Like Offset,
+// PushMark requires r to be seekable.
+func (r *Reader) PushMark() {
+	r.marks = append(r.marks, r.Offset())
+}
+
+// PopMark seeks back to the offset saved by the most recent PushMark
+// and removes that mark.
+func (r *Reader) PopMark() error {
+	n := len(r.marks)
+	if n == 0 {
+		return fmt.Errorf("pop mark: no mark")
+	}
+	if !r.Seekable() {
+		return fmt.Errorf("pop mark: input is not seekable")
+	}
+	off := r.marks[n-1]
+	r.marks = r.marks[:n-1]
+	r.Seek(off, 0)
+	return nil
+}
+
+// Grow makes sure that the buffer of r can hold at least size bytes,
+// replacing it with a larger buffer if necessary. Buffered data is
+// moved to the new buffer, so reading continues at the same offset.
+// Grow never shrinks the buffer.
+func (r *Reader) Grow(size int) {
+	if size > r.size() {
+		r.replaceBuffer(size)
+	}
+}
+
+// SetReadahead prepares r for reading the next n bytes sequentially.
+// It grows the buffer of r to n bytes, so that the data is read with
+// fewer, larger reads (see Grow), and where supported, it advises the
+// operating system that this part of the file will be needed soon
+// (on Linux, using posix_fadvise with POSIX_FADV_WILLNEED). The hint
+// is not given for files that are not seekable or for Readers created
+// by OpenCompressed.
+func (r *Reader) SetReadahead(n int) {
+	if n <= 0 {
+		return
+	}
+	if f, ok := r.f.(*os.File); ok && r.gz == nil && r.Seekable() {
+		fadviseWillNeed(f, r.Offset(), int64(n))
+	}
+	r.Grow(n)
+}
+
+// size returns the buffer size of r.
+func (r *Reader) size() int {
+	if r.bufSize == 0 {
+		return 4096 // bufio default
+	}
+	return r.bufSize
+}
+
+// replaceBuffer replaces the buffer of r with a new buffer of the given
+// size which is filled from r.src(). The data that is already buffered is
+// moved to the new buffer.
+func (r *Reader) replaceBuffer(size int) {
+	src := r.src()
+	buffered, _ := r.Peek(r.Buffered())
+	if len(buffered) > 0 {
+		data := make([]byte, len(buffered))
+		copy(data, buffered)
+		src = io.MultiReader(bytes.NewReader(data), src)
+	}
+	br := bufio.NewReaderSize(src, size)
+	br.Peek(len(buffered)) // move the buffered data into the new buffer so that Offset remains correct
+	r.Reader = br
+	r.bufSize = size
+}
+
+// src returns the reader from which the buffer of r is filled.
+func (r *Reader) src() io.Reader {
+	var src io.Reader = r.f
+	switch {
+	case r.gz != nil:
+		src = r.gz
+	case r.progress != nil:
+		src = r.progress
+	}
+	if r.tee != nil {
+		src = io.TeeReader(src, r.tee)
+	}
+	return src
+}
+
+// Tee arranges for all data read into the buffer of r to be written
+// to w as well, which is useful to debug readers of file formats.
+// Data that is already buffered is written to w immediately. Because
+// r is buffered, the data written to w may run ahead of the data
+// consumed from r by up to the buffer size. Seeking discards the
+// buffer, so data may be written to w again after a Seek. If w is
+// nil, the tee is removed.
+func (r *Reader) Tee(w io.Writer) {
+	if w != nil {
+		buffered, _ := r.Peek(r.Buffered())
+		w.Write(buffered)
+	}
+	r.tee = w
+	r.replaceBuffer(r.size())
+}
+
+// Stat returns the FileInfo structure describing the underlying file.
+func (r *Reader) Stat() (os.FileInfo, error) {
+	f, ok := r.f.(*os.File)
+	if !ok {
+		return nil, fmt.Errorf("stat: no underlying file")
+	}
+	return f.Stat()
+}
+
+// SetProgress arranges for f to be called with the current offset
+// each time another every bytes have been read from the underlying
+// file. Because r is buffered, the bytes consumed from r may lag
+// behind the reported offset by up to the buffer size. SetProgress
+// must be called before reading from r, or immediately after a Seek.
+// If f is nil, progress reporting is turned off. SetProgress must not
+// be used with Readers created by OpenCompressed.
+func (r *Reader) SetProgress(every int64, f func(offset int64)) {
+	if r.Buffered() > 0 {
+		panic("bio: SetProgress called with buffered data")
+	}
+	if r.gz != nil {
+		panic("bio: SetProgress called for compressed input")
+	}
+	if f == nil || every <= 0 {
+		r.progress = nil
+		r.Reset(r.src())
+		return
+	}
+	off, _ := r.f.Seek(0, 1) // assume offset 0 if f is not seekable
+	r.progress = &progressReader{f: r.f, every: every, report: f}
+	r.progress.seek(off)
+	r.Reset(r.src())
+}
+
+// A progressReader reads from f and calls report each time
+// the file offset crosses a multiple of every.
+type progressReader struct {
+	f      io.Reader
+	every  int64
+	report func(offset int64)
+
+	off  int64 // current offset of f
+	next int64 // offset at which report is called next
+}
+
+func (p *progressReader) seek(off int64) {
+	p.off = off
+	p.next = (off/p.every + 1) * p.every
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.f.Read(b)
+	p.off += int64(n)
+	if p.off >= p.next {
+		p.next = (p.off/p.every + 1) * p.every
+		p.report(p.off)
+	}
+	return n, err
+}
+
 func (r *Reader) Offset() int64 {
+	if r.gz != nil {
+		log.Fatalf("seeking in input: %v", errCompressed)
+	}
 	off, err := r.f.Seek(0, 1)
 	if err != nil {
 		log.Fatalf("seeking in output [0, 1]: %v", err)
@@ -75,7 +445,10 @@ func (r *Reader) Offset() int64 {
 }
 
 func (w *Writer) Offset() int64 {
-	if err := w.Flush(); err != nil {
+	if w.gz != nil {
+		log.Fatalf("seeking in output: %v", errCompressed)
+	}
+	if err := w.FlushNow(); err != nil {
 		log.Fatalf("writing output: %v", err)
 	}
 	off, err := w.f.Seek(0, 1)
@@ -85,15 +458,674 @@ func (w *Writer) Offset() int64 {
 	return off
 }
 
+// Barrier flushes w and returns the resulting offset, which may serve
+// as a checkpoint, for instance to compute the size of a segment of the
+// output. Unlike Offset, it returns an error instead of exiting if the
+// flush or the offset computation fails. Writes after a barrier continue
+// appending at the returned offset.
+func (w *Writer) Barrier() (offset int64, err error) {
+	if err := w.FlushNow(); err != nil {
+		return 0, err
+	}
+	if w.gz != nil {
+		return 0, errCompressed
+	}
+	return w.f.Seek(0, 1)
+}
+
+// EnsureTrailingNewline writes a newline to w unless the output written
+// so far is empty or already ends in a newline. It flushes w to inspect
+// the last byte written, so the underlying file must be readable, as it
+// is for Writers created by Create or CreateTemp.
+func (w *Writer) EnsureTrailingNewline() error {
+	off, err := w.Barrier()
+	if err != nil || off == 0 {
+		return err
+	}
+	var last [1]byte
+	if _, err := w.f.ReadAt(last[:], off-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		return w.WriteByte('\n')
+	}
+	return nil
+}
+
+// SetLazyFlush enables lazy flushing if threshold > 0: Flush does
+// nothing unless at least threshold bytes are buffered, so that many
+// small flushes are coalesced into fewer writes to the underlying file.
+// FlushNow and the methods which need the current file offset, such as
+// Offset or Seek, always flush. Close always flushes the buffer.
+// If threshold <= 0, lazy flushing is disabled.
+func (w *Writer) SetLazyFlush(threshold int) {
+	w.lazy = threshold
+}
+
+// Flush writes any buffered data to the underlying file.
+// If lazy flushing is enabled (see SetLazyFlush), Flush writes the
+// data only if enough of it has accumulated.
+func (w *Writer) Flush() error {
+	if w.lazy > 0 && w.Buffered() < w.lazy {
+		return w.Err()
+	}
+	return w.Writer.Flush()
+}
+
+// FlushNow writes any buffered data to the underlying file,
+// even if lazy flushing is enabled.
+func (w *Writer) FlushNow() error {
+	return w.Writer.Flush()
+}
+
+// SetRecordBoundary marks the end of the data written to w so far as
+// the end of a complete record, up to which FlushToBoundary may flush.
+// Only the most recent boundary is remembered.
+func (w *Writer) SetRecordBoundary() {
+	if w.rec == nil {
+		// Everything buffered so far ends at the new boundary,
+		// so it may be flushed before the buffer writes through
+		// w.rec. If the flush fails, the error is reported by the
+		// next write or flush.
+		if w.FlushNow() != nil {
+			return
+		}
+		w.rec = &recordSink{w: w.dst(), limit: -1}
+		w.setDst(w.rec)
+	}
+	w.rec.boundary = w.rec.n + int64(w.Buffered())
+}
+
+// FlushToBoundary writes the buffered data up to the last boundary
+// marked by SetRecordBoundary to the underlying file and keeps the rest,
+// which belongs to an incomplete record, in the buffer. Records larger
+// than the buffer are written through when the buffer fills up, as usual.
+func (w *Writer) FlushToBoundary() error {
+	if w.rec == nil {
+		return w.Err()
+	}
+	n := w.rec.boundary - w.rec.n // number of buffered bytes to flush
+	if n <= 0 {
+		return w.Err()
+	}
+	if n >= int64(w.Buffered()) {
+		return w.FlushNow()
+	}
+	w.rec.limit = n
+	err := w.Writer.Flush()
+	rest := w.rec.rest
+	w.rec.limit, w.rec.rest = -1, nil
+	if err != nil {
+		return err
+	}
+	// rest came from the buffer and fits into it again;
+	// any edits by OverwriteBuffered were applied already
+	w.sink.flushed -= len(rest)
+	_, err = w.Writer.Write(rest)
+	return err
+}
+
+// dst returns the writer the bufio.Writer of w writes to.
+func (w *Writer) dst() io.Writer {
+	switch {
+	case w.out != nil:
+		return w.out
+	case w.gz != nil:
+		return w.gz
+	}
+	return w.f
+}
+
+// setDst makes the bufio.Writer of w write to out, which typically
+// wraps the previous dst. The buffer must be empty.
+func (w *Writer) setDst(out io.Writer) {
+	w.out = out
+	w.sink.w = out
+	w.Writer.Reset(&w.sink)
+}
+
+// A recordSink counts the bytes written through it. While limit >= 0,
+// it writes only limit bytes and keeps the rest in rest instead.
+type recordSink struct {
+	w        io.Writer
+	n        int64 // bytes written to w
+	boundary int64 // n at the last record boundary
+	limit    int64
+	rest     []byte
+}
+
+func (s *recordSink) Write(p []byte) (int, error) {
+	if s.limit < 0 || int64(len(p)) <= s.limit {
+		n, err := s.w.Write(p)
+		s.n += int64(n)
+		return n, err
+	}
+	n, err := s.w.Write(p[:s.limit])
+	s.n += int64(n)
+	if err != nil {
+		return n, err
+	}
+	s.rest = append(s.rest, p[n:]...)
+	return len(p), nil
+}
+
+// Err returns the first error that occurred while writing to w, if any.
+func (w *Writer) Err() error {
+	// An empty write doesn't touch the buffer and
+	// returns the error recorded by bufio.Writer.
+	_, err := w.Writer.Write(nil)
+	return err
+}
+
+// WriteBuffers writes the contents of bufs to w, in order, as if by
+// a sequence of calls to Write. It returns the total number of bytes
+// written. If a write fails, WriteBuffers stops and returns the number
+// of bytes written so far, including those of the partially written
+// buffer, and the error.
+func (w *Writer) WriteBuffers(bufs ...[]byte) (int64, error) {
+	var total int64
+	for _, b := range bufs {
+		n, err := w.Write(b)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// WriteJoined writes the elements of items to w, separated by sep,
+// like WriteString(strings.Join(items, sep)) but without building the
+// joined string. It returns the total number of bytes written.
+func (w *Writer) WriteJoined(items []string, sep string) (int, error) {
+	var total int
+	for i, s := range items {
+		if i > 0 {
+			n, err := w.WriteString(sep)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		n, err := w.WriteString(s)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// maxRepeatChunk is the maximum number of bytes WriteRepeat writes at once.
+const maxRepeatChunk = 512
+
+// WriteRepeat writes count copies of b to w and returns the number of
+// bytes written. The bytes are written in chunks from a small buffer
+// that is reused by later calls, so large counts don't allocate.
+func (w *Writer) WriteRepeat(b byte, count int) (int, error) {
+	if count < 0 {
+		return 0, fmt.Errorf("negative repeat count %d", count)
+	}
+	chunk := count
+	if chunk > maxRepeatChunk {
+		chunk = maxRepeatChunk
+	}
+	w.tmp = w.tmp[:0]
+	for i := 0; i < chunk; i++ {
+		w.tmp = append(w.tmp, b)
+	}
+	var total int
+	for total < count {
+		p := w.tmp
+		if rest := count - total; rest < len(p) {
+			p = p[:rest]
+		}
+		n, err := w.Write(p)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// WriteDirect writes p to w like Write, but if p does not fit into
+// the buffer, it flushes the buffer and writes p directly to the
+// underlying file instead of copying it through the buffer. This is
+// useful for large payloads. Smaller p are buffered as usual.
+func (w *Writer) WriteDirect(p []byte) (int, error) {
+	if len(p) <= w.Available() {
+		return w.Write(p)
+	}
+	if err := w.FlushNow(); err != nil {
+		return 0, err
+	}
+	// With an empty buffer, bufio.Writer writes data that doesn't
+	// fit into the buffer directly, and it records any error.
+	return w.Writer.Write(p)
+}
+
+// A Patch describes space reserved in the output of a Writer
+// to be filled in later (see Reserve).
+type Patch struct {
+	off int64 // offset of the reserved space
+	n   int   // size of the reserved space
+}
+
+// Reserve writes n zero bytes to w and returns a Patch
+// for filling them in later, for instance with the length
+// of the data that follows.
+func (w *Writer) Reserve(n int) (Patch, error) {
+	off, err := w.Barrier()
+	if err != nil {
+		return Patch{}, err
+	}
+	for i := 0; i < n; i++ {
+		if err := w.WriteByte(0); err != nil {
+			return Patch{}, err
+		}
+	}
+	return Patch{off, n}, nil
+}
+
+// Fill writes data into the space reserved by p in the output of w.
+// Data must not be longer than the reserved space. The current offset
+// of w is not changed.
+func (p *Patch) Fill(w *Writer, data []byte) error {
+	if len(data) > p.n {
+		return fmt.Errorf("patch data too long: %d bytes, %d reserved", len(data), p.n)
+	}
+	_, err := w.WriteAt(data, p.off)
+	return err
+}
+
+// BufOffset returns the buffer offset of the next byte written to w:
+// the number of bytes written to the buffer of w so far. Unlike Offset,
+// it doesn't flush w, and it is not affected by Seek.
+func (w *Writer) BufOffset() int {
+	return w.sink.flushed + w.Buffered()
+}
+
+// OverwriteBuffered replaces the bytes at buffer offset bufOffset (see
+// BufOffset) with data if they are still buffered, without writing to
+// the underlying file. It is a cheaper alternative to WriteAt for
+// filling in a placeholder, such as the length of a small body, written
+// moments ago. If some of the bytes have been flushed already, or not
+// written yet, OverwriteBuffered returns an error and changes nothing.
+func (w *Writer) OverwriteBuffered(bufOffset int, data []byte) error {
+	if bufOffset < w.sink.flushed {
+		return fmt.Errorf("overwriting buffer offset %d: flushed already", bufOffset)
+	}
+	if end := w.BufOffset(); bufOffset+len(data) > end {
+		return fmt.Errorf("overwriting %d bytes at buffer offset %d: only %d bytes written", len(data), bufOffset, end)
+	}
+	w.sink.edits = append(w.sink.edits, bufEdit{bufOffset, append([]byte(nil), data...)})
+	return nil
+}
+
+// A bufSink is the writer the bufio.Writer of a Writer writes to.
+// The bufio.Writer doesn't provide access to its buffer, so the edits
+// of OverwriteBuffered are recorded in the sink and applied to the
+// buffered data when it is flushed.
+type bufSink struct {
+	w       io.Writer
+	flushed int // bytes written to w, that is, the buffer offset of the buffered data
+	edits   []bufEdit
+	tmp     []byte // the buffered data with the edits applied
+}
+
+// A bufEdit replaces the bytes at buffer offset off with data.
+type bufEdit struct {
+	off  int
+	data []byte
+}
+
+func (s *bufSink) Write(p []byte) (int, error) {
+	if len(s.edits) > 0 {
+		// Edits are only recorded for buffered data, and the
+		// bufio.Writer writes its buffer from the start, so p
+		// contains all edited bytes. p must not be modified;
+		// edit a copy.
+		s.tmp = append(s.tmp[:0], p...)
+		for _, e := range s.edits {
+			copy(s.tmp[e.off-s.flushed:], e.data)
+		}
+		s.edits = s.edits[:0]
+		p = s.tmp
+	}
+	n, err := s.w.Write(p)
+	s.flushed += n
+	return n, err
+}
+
+// WriteAt writes data at offset off of the output of w, after flushing
+// w. It does not change the current offset of w; the next write will
+// continue where the last write ended.
+func (w *Writer) WriteAt(data []byte, off int64) (int, error) {
+	if err := w.FlushNow(); err != nil { // the bytes at off may still be buffered
+		return 0, err
+	}
+	if w.gz != nil {
+		return 0, errCompressed
+	}
+	if w.crc != nil {
+		return 0, errCRCTrailer
+	}
+	return w.f.WriteAt(data, off)
+}
+
+// Truncate flushes w and changes the size of its output to size bytes.
+// Like os.File.Truncate, it does not change the current offset of w;
+// use Seek to continue writing at the new end of the output.
+func (w *Writer) Truncate(size int64) error {
+	if err := w.FlushNow(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return errCompressed
+	}
+	if w.crc != nil {
+		return errCRCTrailer
+	}
+	return w.f.Truncate(size)
+}
+
+// Section returns a reader for the n bytes of the underlying file
+// starting at offset off. It reads the file directly and has its own
+// position, so using it does not affect the position of r.
+func (r *Reader) Section(off, n int64) (*io.SectionReader, error) {
+	if r.f == nil {
+		return nil, fmt.Errorf("section: no underlying file")
+	}
+	if r.gz != nil {
+		return nil, fmt.Errorf("section: %v", errCompressed)
+	}
+	if off < 0 || n < 0 {
+		return nil, fmt.Errorf("section: invalid range [%d, %d+%d)", off, off, n)
+	}
+	return io.NewSectionReader(r.f, off, n), nil
+}
+
+// ContentHash writes the entire contents of the underlying file to h,
+// from the beginning of the file, regardless of the offset of r. Like
+// Section, it reads the file directly, so the offset of r is unchanged
+// and buffered data is kept. ContentHash fails if the file is not
+// seekable or r was created by OpenCompressed.
+func (r *Reader) ContentHash(h hash.Hash) error {
+	if r.f == nil {
+		return fmt.Errorf("content hash: no underlying file")
+	}
+	if r.gz != nil {
+		return fmt.Errorf("content hash: %v", errCompressed)
+	}
+	if !r.Seekable() {
+		return fmt.Errorf("content hash: input is not seekable")
+	}
+	_, err := io.Copy(h, io.NewSectionReader(r.f, 0, 1<<63-1))
+	return err
+}
+
+// SkipTo discards bytes up to and including the next occurrence of b.
+// It reports whether b was found; at EOF, found is false and err is nil.
+// Unlike ReadBytes or ReadString, SkipTo does not allocate.
+func (r *Reader) SkipTo(b byte) (found bool, err error) {
+	for {
+		_, err := r.ReadSlice(b)
+		switch err {
+		case nil:
+			return true, nil
+		case bufio.ErrBufferFull:
+			// b is not in the buffer; keep going
+		case io.EOF:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+// SkipBOM discards a UTF-8 byte order mark (U+FEFF) at the current
+// offset of r, typically the beginning of a file, and reports whether
+// it did so. If the next bytes are not a byte order mark, nothing is
+// consumed; this includes the case of fewer than 3 remaining bytes.
+func (r *Reader) SkipBOM() (bool, error) {
+	b, err := r.Peek(3)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if len(b) < 3 || b[0] != 0xef || b[1] != 0xbb || b[2] != 0xbf {
+		return false, nil
+	}
+	// not r.Discard, which is not available in Go 1.4
+	var bom [3]byte
+	io.ReadFull(r, bom[:]) // cannot fail, the bytes are buffered
+	return true, nil
+}
+
+// AtEOF reports whether r is at the end of the input. It does not
+// consume any input.
+func (r *Reader) AtEOF() (bool, error) {
+	_, err := r.Peek(1)
+	switch err {
+	case nil:
+		return false, nil
+	case io.EOF:
+		return true, nil
+	}
+	return false, err
+}
+
+// maxPeekLine is the buffer size up to which PeekLineLength grows
+// the buffer of a Reader.
+const maxPeekLine = 1 << 20
+
+// PeekLineLength returns the length of the current line, that is, the
+// number of bytes before the next '\n', without consuming any input.
+// If the line doesn't fit into the buffer of r, the buffer is grown
+// (see Grow), up to a size of 1MB. If EOF is reached before a newline,
+// PeekLineLength returns the number of remaining bytes and io.EOF. If
+// the line is longer than the maximum buffer size, it returns the
+// maximum buffer size and bufio.ErrBufferFull.
+func (r *Reader) PeekLineLength() (int, error) {
+	n := 0 // length of the line prefix known not to contain '\n'
+	for {
+		b, _ := r.Peek(r.Buffered()) // cannot fail
+		if i := bytes.IndexByte(b[n:], '\n'); i >= 0 {
+			return n + i, nil
+		}
+		n = len(b)
+		if n >= r.size() {
+			if n >= maxPeekLine {
+				return n, bufio.ErrBufferFull
+			}
+			size := 2 * n
+			if size > maxPeekLine {
+				size = maxPeekLine
+			}
+			r.Grow(size)
+		}
+		if _, err := r.Peek(n + 1); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Expect consumes magic if the next bytes of r are magic, as at the
+// start of many file formats. Otherwise it returns an error which shows
+// the bytes found instead, and nothing is consumed. magic must fit into
+// the buffer of r.
+func (r *Reader) Expect(magic []byte) error {
+	b, err := r.Peek(len(magic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(b) < len(magic) {
+		return fmt.Errorf("bad magic: found %q at EOF, want %q", b, magic)
+	}
+	if !bytes.Equal(b, magic) {
+		return fmt.Errorf("bad magic: found %q, want %q", b, magic)
+	}
+	// not r.Discard, which is not available in Go 1.4
+	io.ReadFull(r, make([]byte, len(magic))) // cannot fail, the bytes are buffered
+	return nil
+}
+
+// ReadUntilAny reads until the first occurrence of any of the bytes
+// in delims and returns the data before it and the delimiter found.
+// The delimiter is consumed but not included in data. If the end of
+// the file is reached before any delimiter, ReadUntilAny returns the
+// data read and io.EOF.
+func (r *Reader) ReadUntilAny(delims []byte) (data []byte, delim byte, err error) {
+	var isDelim [256]bool
+	for _, c := range delims {
+		isDelim[c] = true
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return data, 0, err
+		}
+		if isDelim[c] {
+			return data, c, nil
+		}
+		data = append(data, c)
+	}
+}
+
+// ReadFull reads exactly len(p) bytes from r into p, like io.ReadFull.
+// The error is io.EOF only if no bytes were read; if EOF is reached
+// after reading some but not all the bytes, ReadFull returns
+// io.ErrUnexpectedEOF.
+func (r *Reader) ReadFull(p []byte) (int, error) {
+	return io.ReadFull(r.Reader, p)
+}
+
+// ReadAll reads from r until EOF and returns the data read, like
+// ioutil.ReadAll. It advances the offset of r to the end of the file.
+func (r *Reader) ReadAll() ([]byte, error) {
+	return ioutil.ReadAll(r.Reader)
+}
+
+// ReadAllAt returns the contents of the underlying file from offset off
+// to the end. Like Section, it reads the file directly, so the offset
+// of r is unchanged and buffered data is kept.
+func (r *Reader) ReadAllAt(off int64) ([]byte, error) {
+	sec, err := r.Section(off, 1<<63-1-off)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(sec)
+}
+
+// Align writes pad bytes until the offset of w is a multiple of
+// boundary, which must be a power of two. If the offset is already
+// aligned, Align writes nothing.
+func (w *Writer) Align(boundary int, pad byte) error {
+	if boundary <= 0 || boundary&(boundary-1) != 0 {
+		return fmt.Errorf("alignment %d is not a power of two", boundary)
+	}
+	off, err := w.Barrier()
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteRepeat(pad, int(-off&int64(boundary-1)))
+	return err
+}
+
+// SkipToAlign discards bytes until the offset of r is a multiple of
+// boundary, which must be a power of two. If the end of the file is
+// reached first, SkipToAlign returns io.ErrUnexpectedEOF.
+func (r *Reader) SkipToAlign(boundary int) error {
+	if boundary <= 0 || boundary&(boundary-1) != 0 {
+		return fmt.Errorf("alignment %d is not a power of two", boundary)
+	}
+	if r.gz != nil {
+		return errCompressed
+	}
+	off, err := r.f.Seek(0, 1)
+	if err != nil {
+		return err
+	}
+	off -= int64(r.Buffered())
+	for n := -off & int64(boundary-1); n > 0; n-- {
+		if _, err := r.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Reader) Close() error {
 	return r.f.Close()
 }
 
+// Close flushes w and closes the underlying file. If both
+// flushing and closing fail, the returned error reports both,
+// the flush error first. For a Writer created by CreateCompressed,
+// flushing includes writing the end of the compressed stream.
 func (w *Writer) Close() error {
-	err := w.Flush()
+	err := w.FlushNow()
+	if w.crc != nil && err == nil && !w.aborted {
+		err = w.crc.writeTrailer()
+	}
+	if w.gz != nil && err == nil {
+		err = w.gz.Close() // write the end of the compressed stream
+	}
+	if w.sync && err == nil && !w.aborted {
+		err = w.f.Sync()
+	}
 	err1 := w.f.Close()
-	if err == nil {
+	switch {
+	case err == nil:
 		err = err1
+	case err1 != nil:
+		err = &closeError{err, err1}
+	}
+
+	if w.name != "" {
+		// temporary file created by CreateTemp
+		if err == nil && !w.aborted {
+			err = os.Rename(w.f.Name(), w.name)
+			if err == nil && w.sync {
+				syncDir(filepath.Dir(w.name))
+			}
+		}
+		if err != nil || w.aborted {
+			os.Remove(w.f.Name())
+		}
 	}
+
 	return err
 }
+
+// syncDir syncs the directory dir to disk, so that a preceding rename
+// of a file in dir is durable. Not all systems support syncing a
+// directory, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// Abort arranges for Close to discard the output of a Writer
+// created by CreateTemp or CreateAtomic. It has no effect on other
+// Writers.
+func (w *Writer) Abort() {
+	w.aborted = true
+}
+
+// A closeError is returned by Writer.Close if both
+// flushing and closing the underlying file failed.
+type closeError struct {
+	flush, close error
+}
+
+func (e *closeError) Error() string {
+	return e.flush.Error() + "; " + e.close.Error()
+}
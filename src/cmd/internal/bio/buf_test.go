@@ -0,0 +1,1571 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// tempFile writes data to a new file in a temporary directory
+// and returns the file name and a function to remove it again.
+func tempFile(t *testing.T, data string) (name string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "bio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name = filepath.Join(dir, "data")
+	if err := ioutil.WriteFile(name, []byte(data), 0666); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return name, func() { os.RemoveAll(dir) }
+}
+
+// openTemp is like tempFile but returns a Reader for the file.
+func openTemp(t *testing.T, data string) (r *Reader, cleanup func()) {
+	name, remove := tempFile(t, data)
+	r, err := Open(name)
+	if err != nil {
+		remove()
+		t.Fatal(err)
+	}
+	return r, func() {
+		r.Close()
+		remove()
+	}
+}
+
+func TestSkipTo(t *testing.T) {
+	long := strings.Repeat("x", 5000) // longer than the default buffer
+	for _, test := range []struct {
+		data  string
+		found bool
+		off   int64 // offset after SkipTo
+		rest  string
+	}{
+		{"abc\ndef", true, 4, "def"},
+		{"\n", true, 1, ""},
+		{long + "\nrest", true, 5001, "rest"},
+		{"abc", false, 3, ""},
+		{long, false, 5000, ""},
+		{"", false, 0, ""},
+	} {
+		r, cleanup := openTemp(t, test.data)
+		found, err := r.SkipTo('\n')
+		if err != nil {
+			t.Errorf("%.10q: %v", test.data, err)
+		}
+		if found != test.found {
+			t.Errorf("%.10q: got found = %v; want %v", test.data, found, test.found)
+		}
+		if off := r.Offset(); off != test.off {
+			t.Errorf("%.10q: got offset %d; want %d", test.data, off, test.off)
+		}
+		rest, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%.10q: %v", test.data, err)
+		}
+		if string(rest) != test.rest {
+			t.Errorf("%.10q: got rest %q; want %q", test.data, rest, test.rest)
+		}
+		cleanup()
+	}
+}
+
+func TestReaderSkipBOM(t *testing.T) {
+	for _, test := range []struct {
+		data    string
+		skipped bool
+		rest    string
+	}{
+		{"\ufeffpackage p", true, "package p"},
+		{"\ufeff", true, ""},
+		{"package p", false, "package p"},
+		{"\xef\xbb", false, "\xef\xbb"},
+		{"a", false, "a"},
+		{"", false, ""},
+		{"\xef\xbb\xbe", false, "\xef\xbb\xbe"},
+	} {
+		r, cleanup := openTemp(t, test.data)
+		skipped, err := r.SkipBOM()
+		if skipped != test.skipped || err != nil {
+			t.Errorf("%q: SkipBOM() = %v, %v; want %v, nil", test.data, skipped, err, test.skipped)
+		}
+		if off, want := r.Offset(), int64(len(test.data)-len(test.rest)); off != want {
+			t.Errorf("%q: got offset %d; want %d", test.data, off, want)
+		}
+		rest, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Errorf("%q: %v", test.data, err)
+		}
+		if string(rest) != test.rest {
+			t.Errorf("%q: got rest %q; want %q", test.data, rest, test.rest)
+		}
+		cleanup()
+	}
+}
+
+func TestReaderAtEOF(t *testing.T) {
+	r, cleanup := openTemp(t, "ab")
+	defer cleanup()
+	for i, want := range []bool{false, false, true} {
+		eof, err := r.AtEOF()
+		if eof != want || err != nil {
+			t.Errorf("offset %d: AtEOF() = %v, %v; want %v, nil", i, eof, err, want)
+		}
+		if off := r.Offset(); off != int64(i) {
+			t.Errorf("got offset %d after AtEOF; want %d", off, i)
+		}
+		r.ReadByte()
+	}
+
+	r, cleanup = openTemp(t, "")
+	defer cleanup()
+	if eof, err := r.AtEOF(); !eof || err != nil {
+		t.Errorf("empty file: AtEOF() = %v, %v; want true, nil", eof, err)
+	}
+}
+
+func TestReaderPeekLineLength(t *testing.T) {
+	long := strings.Repeat("x", 10000) // longer than the default buffer
+	for _, test := range []struct {
+		data string
+		want int
+		err  error
+	}{
+		{"", 0, io.EOF},
+		{"\n", 0, nil},
+		{"short line\nnext\n", 10, nil},
+		{"no newline", 10, io.EOF},
+		{long + "\nnext", len(long), nil},
+		{long, len(long), io.EOF},
+		{strings.Repeat("y", maxPeekLine+10) + "\n", maxPeekLine, bufio.ErrBufferFull},
+	} {
+		r := NewMemReader([]byte(test.data))
+		r.ReadByte() // start with something buffered
+		r.UnreadByte()
+		n, err := r.PeekLineLength()
+		if n != test.want || err != test.err {
+			t.Errorf("%s: got %d, %v; want %d, %v", shortData(test.data), n, err, test.want, test.err)
+		}
+		if off := r.Offset(); off != 0 {
+			t.Errorf("%s: got offset %d after PeekLineLength; want 0", shortData(test.data), off)
+		}
+		// the input must still be there
+		rest, err := r.ReadAll()
+		if err != nil || string(rest) != test.data {
+			t.Errorf("%s: input changed by PeekLineLength", shortData(test.data))
+		}
+	}
+}
+
+func shortData(s string) string {
+	if len(s) > 20 {
+		return fmt.Sprintf("%q... (%d bytes)", s[:20], len(s))
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+func TestReaderExpect(t *testing.T) {
+	const magic = "!<arch>\n"
+	for _, test := range []struct {
+		data string
+		err  string
+	}{
+		{"!<arch>\nfile", ""},
+		{"!<arch>\n", ""},
+		{"!<arch>.file", `bad magic: found "!<arch>.", want "!<arch>\n"`},
+		{"!<ar", `bad magic: found "!<ar" at EOF, want "!<arch>\n"`},
+		{"", `bad magic: found "" at EOF, want "!<arch>\n"`},
+	} {
+		r, cleanup := openTemp(t, test.data)
+		err := r.Expect([]byte(magic))
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q: got error %q; want %q", test.data, got, test.err)
+		}
+		want := int64(0) // nothing is consumed on error
+		if err == nil {
+			want = int64(len(magic))
+		}
+		if off := r.Offset(); off != want {
+			t.Errorf("%q: got offset %d; want %d", test.data, off, want)
+		}
+		cleanup()
+	}
+}
+
+func TestWriterCloseErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out")
+
+	// success
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("hello")
+	if err := w.Close(); err != nil {
+		t.Errorf("got %v; want no error", err)
+	}
+
+	// flush and close both fail
+	w, err = Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("hello")
+	// make subsequent flush and close fail
+	if err := w.f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	e, ok := err.(*closeError)
+	if !ok {
+		t.Fatalf("got %T (%v); want *closeError", err, err)
+	}
+	if e.flush == nil || e.close == nil {
+		t.Errorf("got flush error %v, close error %v; want both", e.flush, e.close)
+	}
+	if msg := err.Error(); msg != e.flush.Error()+"; "+e.close.Error() {
+		t.Errorf("got %q", msg)
+	}
+}
+
+func TestNewReaderWriter(t *testing.T) {
+	// regular file: seeking works
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWriter(f)
+	w.WriteString("hello, world")
+	if off := w.Offset(); off != 12 {
+		t.Errorf("got writer offset %d; want 12", off)
+	}
+	w.Seek(7, 0)
+	w.WriteString("WORLD")
+	w.Flush()
+
+	f.Seek(0, 0)
+	r := NewReader(f)
+	buf := make([]byte, 5)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if off := r.Offset(); off != 5 {
+		t.Errorf("got reader offset %d; want 5", off)
+	}
+	r.Seek(7, 0)
+	rest, err := ioutil.ReadAll(r)
+	if err != nil || string(rest) != "WORLD" {
+		t.Errorf("got %q, %v; want %q", rest, err, "WORLD")
+	}
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := w.f.Close(); err == nil {
+		t.Error("underlying file not closed by Reader.Close")
+	}
+
+	// pipe: writes go through but seeking is not possible
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = NewWriter(pw)
+	w.WriteString("through the pipe")
+	if _, err := w.f.Seek(0, 1); err == nil {
+		t.Error("seeking in pipe succeeded")
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	r = NewReader(pr)
+	got, err := ioutil.ReadAll(r)
+	if err != nil || string(got) != "through the pipe" {
+		t.Errorf("got %q, %v; want %q", got, err, "through the pipe")
+	}
+	r.Close()
+}
+
+func TestWriterAlign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out")
+
+	for _, test := range []struct {
+		n, boundary int   // bytes written before alignment; alignment
+		off         int64 // offset after alignment
+	}{
+		{0, 4, 0},
+		{1, 4, 4},
+		{3, 4, 4},
+		{4, 4, 4},
+		{5, 4, 8},
+		{1, 8, 8},
+		{8, 8, 8},
+		{13, 8, 16},
+		{5, 1, 5},
+	} {
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(strings.Repeat("x", test.n))
+		if err := w.Align(test.boundary, '-'); err != nil {
+			t.Errorf("Align(%d) after %d bytes: %v", test.boundary, test.n, err)
+		}
+		if off := w.Offset(); off != test.off {
+			t.Errorf("Align(%d) after %d bytes: got offset %d; want %d", test.boundary, test.n, off, test.off)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Repeat("x", test.n) + strings.Repeat("-", int(test.off)-test.n)
+		if string(data) != want {
+			t.Errorf("Align(%d) after %d bytes: got %q; want %q", test.boundary, test.n, data, want)
+		}
+	}
+
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, boundary := range []int{0, -4, 3, 12} {
+		if err := w.Align(boundary, 0); err == nil {
+			t.Errorf("Align(%d): got no error", boundary)
+		}
+	}
+}
+
+func TestReaderSkipToAlign(t *testing.T) {
+	r, cleanup := openTemp(t, "0123456789abcdefghij")
+	defer cleanup()
+
+	for _, test := range []struct {
+		n   int   // bytes to read before alignment
+		off int64 // offset after alignment
+	}{
+		{0, 0},
+		{1, 8},
+		{0, 8},
+		{7, 16},
+		{3, 24}, // past EOF
+	} {
+		buf := make([]byte, test.n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		err := r.SkipToAlign(8)
+		if test.off > 20 {
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("got %v; want %v", err, io.ErrUnexpectedEOF)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if off := r.Offset(); off != test.off {
+			t.Errorf("got offset %d; want %d", off, test.off)
+		}
+	}
+
+	if err := r.SkipToAlign(6); err == nil {
+		t.Error("SkipToAlign(6): got no error")
+	}
+}
+
+func TestWriterWriteRune(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		r rune
+		n int
+	}{
+		{'a', 1},
+		{'ä', 2},
+		{'世', 3},
+		{'𝜶', 4},
+	} {
+		if n, err := w.WriteRune(test.r); n != test.n || err != nil {
+			t.Errorf("WriteRune(%q) = %d, %v; want %d, nil", test.r, n, err, test.n)
+		}
+	}
+	if off := w.Offset(); off != 10 {
+		t.Errorf("got offset %d; want 10", off)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != "aä世𝜶" {
+		t.Errorf("got %q; want %q", data, "aä世𝜶")
+	}
+}
+
+func benchmarkWrite(b *testing.B, write func(w *Writer, s string)) {
+	f, err := ioutil.TempFile("", "bio")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	w := NewWriter(f)
+	defer w.Close()
+
+	s := strings.Repeat("x", 100)
+	b.SetBytes(int64(len(s)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		write(w, s)
+	}
+}
+
+func BenchmarkWriteString(b *testing.B) {
+	benchmarkWrite(b, func(w *Writer, s string) { w.WriteString(s) })
+}
+
+func BenchmarkWriteBytes(b *testing.B) {
+	benchmarkWrite(b, func(w *Writer, s string) { w.Write([]byte(s)) })
+}
+
+func TestWriterWriteDirect(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := strings.Repeat("0123456789", 1000) // larger than the buffer
+	var want string
+	for i, s := range []string{"header", big, "small", big, big, "trailer"} {
+		var err error
+		if i%2 == 0 {
+			_, err = w.WriteString(s)
+		} else {
+			_, err = w.WriteDirect([]byte(s))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += s
+		if off := w.Offset(); off != int64(len(want)) {
+			t.Errorf("write %d: got offset %d; want %d", i, off, len(want))
+		}
+	}
+	if n, err := w.WriteDirect([]byte("direct")); n != 6 || err != nil {
+		t.Errorf("WriteDirect of short data = %d, %v; want 6, nil", n, err)
+	}
+	want += "direct"
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != want {
+		t.Errorf("got %d bytes; want %d bytes", len(data), len(want))
+	}
+}
+
+func benchmarkLargeWrite(b *testing.B, write func(w *Writer, p []byte)) {
+	f, err := ioutil.TempFile("", "bio")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	w := NewWriter(f)
+	defer w.Close()
+
+	p := bytes.Repeat([]byte("x"), 4<<20)
+	b.SetBytes(int64(len(p)))
+	for i := 0; i < b.N; i++ {
+		w.Seek(0, 0)
+		w.WriteString("header") // the buffer is not empty
+		write(w, p)
+	}
+}
+
+func BenchmarkLargeWrite(b *testing.B) {
+	benchmarkLargeWrite(b, func(w *Writer, p []byte) { w.Write(p) })
+}
+
+func BenchmarkLargeWriteDirect(b *testing.B) {
+	benchmarkLargeWrite(b, func(w *Writer, p []byte) { w.WriteDirect(p) })
+}
+
+func TestReaderSetReadahead(t *testing.T) {
+	data := strings.Repeat("0123456789", 10000)
+	r, cleanup := openTemp(t, data)
+	defer cleanup()
+
+	if _, err := r.Discard(5); err != nil {
+		t.Fatal(err)
+	}
+	r.SetReadahead(64 << 10)
+	if off := r.Offset(); off != 5 {
+		t.Errorf("got offset %d after SetReadahead; want 5", off)
+	}
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != data[5:] {
+		t.Errorf("got %d bytes after SetReadahead; want %d", len(rest), len(data)-5)
+	}
+}
+
+func benchmarkReadahead(b *testing.B, n int) {
+	f, err := ioutil.TempFile("", "bio")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	data := bytes.Repeat([]byte("x"), 16<<20)
+	if _, err := f.Write(data); err != nil {
+		b.Fatal(err)
+	}
+	r := NewReader(f)
+	defer r.Close()
+
+	buf := make([]byte, 512)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		r.SetReadahead(n)
+		for {
+			if _, err := r.Read(buf); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkReadaheadDefault(b *testing.B) { benchmarkReadahead(b, 0) }
+func BenchmarkReadahead1M(b *testing.B)      { benchmarkReadahead(b, 1<<20) }
+
+func TestReaderSeekable(t *testing.T) {
+	r, cleanup := openTemp(t, "data")
+	defer cleanup()
+	if !r.Seekable() {
+		t.Error("regular file: Seekable() = false; want true")
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	r = NewReader(pr)
+	defer r.Close()
+	for i := 0; i < 2; i++ { // second call uses the cached result
+		if r.Seekable() {
+			t.Error("pipe: Seekable() = true; want false")
+		}
+	}
+}
+
+func TestReaderMarks(t *testing.T) {
+	r, cleanup := openTemp(t, "0123456789")
+	defer cleanup()
+
+	read := func(n int) string {
+		t.Helper()
+		buf := make([]byte, n)
+		if _, err := r.ReadFull(buf); err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+
+	read(2)
+	r.PushMark() // 2
+	read(3)
+	r.PushMark() // 5
+	if got := read(4); got != "5678" {
+		t.Errorf("got %q; want %q", got, "5678")
+	}
+
+	if err := r.PopMark(); err != nil {
+		t.Fatal(err)
+	}
+	if off := r.Offset(); off != 5 {
+		t.Errorf("got offset %d after first PopMark; want 5", off)
+	}
+	if got := read(2); got != "56" {
+		t.Errorf("got %q; want %q", got, "56")
+	}
+
+	if err := r.PopMark(); err != nil {
+		t.Fatal(err)
+	}
+	if off := r.Offset(); off != 2 {
+		t.Errorf("got offset %d after second PopMark; want 2", off)
+	}
+	if got := read(3); got != "234" {
+		t.Errorf("got %q; want %q", got, "234")
+	}
+
+	if err := r.PopMark(); err == nil {
+		t.Error("PopMark without mark succeeded")
+	}
+}
+
+func TestCreateTemp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out")
+
+	checkDir := func(want ...string) {
+		t.Helper()
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, fi := range fis {
+			got = append(got, fi.Name())
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("got files %q; want %q", got, want)
+		}
+	}
+
+	// success: the file appears on Close
+	w, err := CreateTemp(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("hello")
+	w.Flush()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("%s exists before Close", name)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkDir("out")
+	if data, _ := ioutil.ReadFile(name); string(data) != "hello" {
+		t.Errorf("got %q; want %q", data, "hello")
+	}
+
+	// abort: the temporary file is removed, the existing file is kept
+	w, err = CreateTemp(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("partial")
+	w.Abort()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkDir("out")
+	if data, _ := ioutil.ReadFile(name); string(data) != "hello" {
+		t.Errorf("got %q after Abort; want %q", data, "hello")
+	}
+}
+
+func TestCreateAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "out")
+
+	checkDir := func(want ...string) {
+		t.Helper()
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, fi := range fis {
+			got = append(got, fi.Name())
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("got files %q; want %q", got, want)
+		}
+	}
+
+	// error: the temporary file is removed, and there is no output
+	w, err := CreateAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.f.Close() // writes fail
+	w.WriteString(strings.Repeat("x", 10000))
+	if w.Err() == nil {
+		t.Fatal("no error writing to closed file")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close succeeded after write error")
+	}
+	checkDir()
+
+	// success: the file appears on Close, the temporary file is gone
+	w, err = CreateAtomic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("hello")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkDir("out")
+	if data, _ := ioutil.ReadFile(name); string(data) != "hello" {
+		t.Errorf("got %q; want %q", data, "hello")
+	}
+}
+
+func TestReaderReadFull(t *testing.T) {
+	r, cleanup := openTemp(t, "abcdefg")
+	defer cleanup()
+
+	for _, test := range []struct {
+		size int
+		want string
+		err  error
+	}{
+		{4, "abcd", nil},                // exact
+		{0, "", nil},                    // nothing to read
+		{5, "efg", io.ErrUnexpectedEOF}, // partial
+		{1, "", io.EOF},                 // empty
+	} {
+		buf := make([]byte, test.size)
+		n, err := r.ReadFull(buf)
+		if got := string(buf[:n]); got != test.want || err != test.err {
+			t.Errorf("ReadFull(%d bytes) = %q, %v; want %q, %v", test.size, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestReaderReadAll(t *testing.T) {
+	r, cleanup := openTemp(t, "abcdefg")
+	defer cleanup()
+
+	r.Discard(2)
+	for _, test := range []struct {
+		off  int64
+		want string
+	}{
+		{0, "abcdefg"},
+		{3, "defg"},
+		{7, ""},
+		{10, ""},
+	} {
+		data, err := r.ReadAllAt(test.off)
+		if got := string(data); got != test.want || err != nil {
+			t.Errorf("ReadAllAt(%d) = %q, %v; want %q, nil", test.off, got, err, test.want)
+		}
+	}
+	if _, err := r.ReadAllAt(-1); err == nil {
+		t.Error("ReadAllAt(-1) succeeded")
+	}
+	if off := r.Offset(); off != 2 {
+		t.Errorf("got offset %d after ReadAllAt; want 2", off)
+	}
+
+	data, err := r.ReadAll()
+	if got := string(data); got != "cdefg" || err != nil {
+		t.Errorf("ReadAll() = %q, %v; want %q, nil", got, err, "cdefg")
+	}
+	if off := r.Offset(); off != 7 {
+		t.Errorf("got offset %d after ReadAll; want 7", off)
+	}
+	data, err = r.ReadAll()
+	if len(data) != 0 || err != nil {
+		t.Errorf("ReadAll() at EOF = %q, %v; want \"\", nil", data, err)
+	}
+}
+
+func TestReaderStat(t *testing.T) {
+	const data = "some data\n"
+	r, cleanup := openTemp(t, data)
+	defer cleanup()
+
+	fi, err := r.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != int64(len(data)) {
+		t.Errorf("got size %d; want %d", fi.Size(), len(data))
+	}
+	if !fi.Mode().IsRegular() {
+		t.Errorf("got mode %v; want regular file", fi.Mode())
+	}
+}
+
+func TestReaderSetProgress(t *testing.T) {
+	const size = 100000
+	data := strings.Repeat("0123456789", size/10)
+	r, cleanup := openTemp(t, data)
+	defer cleanup()
+
+	for _, every := range []int64{10000, 1000, size + 1} {
+		r.Seek(0, 0)
+		var offsets []int64
+		r.SetProgress(every, func(off int64) {
+			offsets = append(offsets, off)
+		})
+		if n, err := io.Copy(ioutil.Discard, r); n != size || err != nil {
+			t.Fatalf("read %d bytes, %v; want %d bytes", n, err, size)
+		}
+
+		// every call reports progress past a new multiple of every
+		prev := int64(0)
+		for _, off := range offsets {
+			if off/every <= prev/every || off > size {
+				t.Errorf("every %d: unexpected offset %d after %d", every, off, prev)
+			}
+			prev = off
+		}
+		// if every is larger than the buffer size,
+		// each multiple of every is reported exactly once
+		if every > 4096 {
+			if want := size / every; int64(len(offsets)) != want {
+				t.Errorf("every %d: got %d calls; want %d", every, len(offsets), want)
+			}
+		} else if len(offsets) == 0 || offsets[len(offsets)-1] != size {
+			t.Errorf("every %d: got offsets %v; want last offset %d", every, offsets, size)
+		}
+	}
+
+	// turn progress reporting off
+	r.Seek(0, 0)
+	r.SetProgress(0, nil)
+	if r.progress != nil {
+		t.Error("progress reporting still enabled")
+	}
+}
+
+func TestReaderReopen(t *testing.T) {
+	name, cleanup := tempFile(t, "old contents\n")
+	defer cleanup()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if s, err := r.ReadString('\n'); s != "old contents\n" || err != nil {
+		t.Fatalf("got %q, %v; want %q", s, err, "old contents\n")
+	}
+
+	// replace the file
+	tmp := name + ".new"
+	if err := ioutil.WriteFile(tmp, []byte("new\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if off := r.Offset(); off != 0 {
+		t.Errorf("got offset %d after Reopen; want 0", off)
+	}
+	if s, err := r.ReadString('\n'); s != "new\n" || err != nil {
+		t.Errorf("got %q, %v; want %q", s, err, "new\n")
+	}
+
+	// Readers not created by Open cannot be reopened
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = NewReader(f)
+	defer r.Close()
+	if err := r.Reopen(); err == nil {
+		t.Error("Reopen succeeded for Reader without file name")
+	}
+}
+
+func TestWriterBarrier(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.WriteString("header")
+	off1, err := w.Barrier()
+	if off1 != 6 || err != nil {
+		t.Errorf("first Barrier() = %d, %v; want 6, nil", off1, err)
+	}
+	w.WriteString("segment")
+	off2, err := w.Barrier()
+	if off2 != 13 || err != nil {
+		t.Errorf("second Barrier() = %d, %v; want 13, nil", off2, err)
+	}
+	if w.Buffered() != 0 {
+		t.Errorf("got %d buffered bytes after Barrier; want 0", w.Buffered())
+	}
+	if data, _ := ioutil.ReadFile(name); string(data[off1:off2]) != "segment" {
+		t.Errorf("got segment %q; want %q", data[off1:off2], "segment")
+	}
+}
+
+func TestWriterReserve(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteString("hdr")
+	p, err := w.Reserve(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := w.Offset()
+	w.WriteString("body of the section")
+	n := w.Offset() - start
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(n))
+	if err := p.Fill(w, length[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Fill(w, make([]byte, 5)); err == nil {
+		t.Error("no error for too much patch data")
+	}
+	w.WriteString("!") // continues at the end
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(name)
+	if want := "hdr\x00\x00\x00\x13body of the section!"; string(data) != want {
+		t.Errorf("got %q; want %q", data, want)
+	}
+}
+
+func TestWriterOverwriteBuffered(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteString("hdr")
+	off := w.BufOffset()
+	w.WriteUint32(binary.BigEndian, 0) // placeholder
+	w.WriteString("body of the section")
+	n := w.BufOffset() - off - 4
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(n))
+	if err := w.OverwriteBuffered(off, length[:]); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); len(data) != 0 {
+		t.Errorf("got %q written before flushing; want nothing", data)
+	}
+	if err := w.OverwriteBuffered(w.BufOffset()-1, length[:]); err == nil {
+		t.Error("no error for overwriting past the written data")
+	}
+	w.WriteString("!") // continues at the end
+	if err := w.FlushNow(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bytes at off are flushed, even if as many bytes
+	// are buffered again.
+	off2 := w.BufOffset()
+	w.WriteString("second placeholder and more")
+	if err := w.OverwriteBuffered(off, length[:]); err == nil {
+		t.Error("no error for overwriting flushed data")
+	}
+	if err := w.OverwriteBuffered(off2, []byte("SECOND")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(name)
+	if want := "hdr\x00\x00\x00\x13body of the section!SECOND placeholder and more"; string(data) != want {
+		t.Errorf("got %q; want %q", data, want)
+	}
+}
+
+func TestWriterOverwriteBufferedRecords(t *testing.T) {
+	w := NewMemWriter()
+	w.WriteString("rec1;")
+	w.SetRecordBoundary()
+	off := w.BufOffset()
+	w.WriteString("n=0") // incomplete record, kept in the buffer
+	if err := w.FlushToBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.OverwriteBuffered(off+2, []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(";")
+	if got, want := string(w.Bytes()), "rec1;n=3;"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestWriterEnsureTrailingNewline(t *testing.T) {
+	for _, test := range []struct {
+		data, want string
+	}{
+		{"", ""},
+		{"\n", "\n"},
+		{"line", "line\n"},
+		{"line\n", "line\n"},
+		{"line\n\n", "line\n\n"},
+		{strings.Repeat("x", 10000), strings.Repeat("x", 10000) + "\n"},
+	} {
+		name, cleanup := tempFile(t, "")
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(test.data)
+		for i := 0; i < 2; i++ { // a second call has no effect
+			if err := w.EnsureTrailingNewline(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := ioutil.ReadFile(name); string(data) != test.want {
+			t.Errorf("%.10q: got %.10q; want %.10q", test.data, data, test.want)
+		}
+		cleanup()
+	}
+}
+
+func TestWriterLazyFlush(t *testing.T) {
+	for _, threshold := range []int{0, 100} {
+		name, cleanup := tempFile(t, "")
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.SetLazyFlush(threshold)
+
+		// count the writes to the file by watching its size
+		var writes int
+		var size int64
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(w, "record %d\n", i)
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Size() != size {
+				writes++
+				size = fi.Size()
+			}
+		}
+
+		want := 50
+		if threshold > 0 {
+			want = 4 // 490 bytes in all; one write per 100 bytes
+		}
+		if writes != want {
+			t.Errorf("threshold %d: got %d writes; want %d", threshold, writes, want)
+		}
+
+		w.WriteString("end\n")
+		if err := w.FlushNow(); err != nil {
+			t.Fatal(err)
+		}
+		if w.Buffered() != 0 {
+			t.Errorf("threshold %d: got %d bytes buffered after FlushNow", threshold, w.Buffered())
+		}
+		w.WriteString("last\n")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadFile(name)
+		if !strings.HasPrefix(string(data), "record 0\n") || !strings.HasSuffix(string(data), "record 49\nend\nlast\n") {
+			t.Errorf("threshold %d: got incomplete output %q", threshold, data)
+		}
+		cleanup()
+	}
+}
+
+func TestWriterWriteJoined(t *testing.T) {
+	for _, test := range []struct {
+		items []string
+		sep   string
+		want  string
+	}{
+		{nil, ", ", ""},
+		{[]string{"a"}, ", ", "a"},
+		{[]string{"a", "bc", "def"}, ", ", "a, bc, def"},
+		{[]string{"a", "", "b"}, ",", "a,,b"},
+		{[]string{"x", "y"}, "", "xy"},
+	} {
+		w := NewMemWriter()
+		n, err := w.WriteJoined(test.items, test.sep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(w.Bytes()); got != test.want || n != len(test.want) {
+			t.Errorf("WriteJoined(%q, %q) wrote %q (n = %d); want %q (n = %d)", test.items, test.sep, got, n, test.want, len(test.want))
+		}
+	}
+}
+
+func TestWriterWriteRepeat(t *testing.T) {
+	for _, count := range []int{0, 1, 7, maxRepeatChunk, maxRepeatChunk + 1, 10000} {
+		w := NewMemWriter()
+		w.WriteString("<")
+		n, err := w.WriteRepeat('x', count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != count {
+			t.Errorf("count %d: wrote %d bytes", count, n)
+		}
+		if off := w.Offset(); off != int64(1+count) {
+			t.Errorf("count %d: got offset %d; want %d", count, off, 1+count)
+		}
+		w.WriteString(">")
+		if got, want := string(w.Bytes()), "<"+strings.Repeat("x", count)+">"; got != want {
+			t.Errorf("count %d: got %s; want %s", count, shortData(got), shortData(want))
+		}
+	}
+
+	// the buffer is reused for different bytes
+	w := NewMemWriter()
+	w.WriteRepeat('a', 3)
+	w.WriteRepeat('b', 2)
+	w.WriteRepeat('c', 4)
+	if got := string(w.Bytes()); got != "aaabbcccc" {
+		t.Errorf("got %q; want %q", got, "aaabbcccc")
+	}
+
+	if n, err := w.WriteRepeat('x', -1); n != 0 || err == nil {
+		t.Errorf("negative count: got %d, %v; want error", n, err)
+	}
+}
+
+func TestWriterWriteBuffers(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("body", 2000) // larger than the buffer
+	n, err := w.WriteBuffers([]byte("header\n"), []byte(body), []byte("trailer\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "header\n" + body + "trailer\n"
+	if n != int64(len(want)) {
+		t.Errorf("WriteBuffers returned %d; want %d", n, len(want))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("got %d bytes %.20q...; want %d bytes %.20q...", len(data), data, len(want), want)
+	}
+
+	// Writing to a closed pipe fails in the second buffer, which
+	// does not fit into the buffer after the first one.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr.Close()
+	w = NewWriter(pw)
+	defer w.Close()
+	n, err = w.WriteBuffers([]byte("header\n"), []byte(body), []byte("trailer\n"))
+	if err == nil {
+		t.Fatal("no error writing to closed pipe")
+	}
+	if n < int64(len("header\n")) || n >= int64(len(want)) {
+		t.Errorf("WriteBuffers returned %d after error; want between %d and %d", n, len("header\n"), len(want))
+	}
+}
+
+func TestWriterFlushToBoundary(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	check := func(want string) {
+		t.Helper()
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("got file contents %q; want %q", data, want)
+		}
+	}
+
+	// without a boundary, nothing is flushed
+	w.WriteString("rec1;")
+	if err := w.FlushToBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	check("")
+
+	w.SetRecordBoundary()
+	w.WriteString("rec2;")
+	w.SetRecordBoundary()
+	w.WriteString("re")
+	if err := w.FlushToBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	check("rec1;rec2;")
+	if got := w.Buffered(); got != 2 {
+		t.Errorf("got %d bytes buffered; want 2", got)
+	}
+
+	// flushing again without a new boundary doesn't write anything
+	if err := w.FlushToBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	check("rec1;rec2;")
+
+	w.WriteString("c3;")
+	w.SetRecordBoundary()
+	if err := w.FlushToBoundary(); err != nil {
+		t.Fatal(err)
+	}
+	check("rec1;rec2;rec3;")
+	if got := w.Buffered(); got != 0 {
+		t.Errorf("got %d bytes buffered; want 0", got)
+	}
+
+	w.WriteString("rec4")
+	if err := w.FlushNow(); err != nil {
+		t.Fatal(err)
+	}
+	check("rec1;rec2;rec3;rec4")
+}
+
+func TestWriterErr(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr.Close() // writes to pw fail
+	w := NewWriter(pw)
+	defer w.Close()
+
+	if err := w.Err(); err != nil {
+		t.Fatalf("got %v before writing", err)
+	}
+	w.WriteString("hello")
+	err = w.Flush()
+	if err == nil {
+		t.Fatal("no error writing to closed pipe")
+	}
+
+	if e := w.Err(); e != err {
+		t.Errorf("Err() = %v; want %v", e, err)
+	}
+	if _, e := w.Write([]byte("more")); e != err {
+		t.Errorf("Write: got %v; want %v", e, err)
+	}
+	if _, e := w.WriteString(strings.Repeat("x", 10000)); e != err {
+		t.Errorf("WriteString: got %v; want %v", e, err)
+	}
+	if e := w.WriteByte('x'); e != err {
+		t.Errorf("WriteByte: got %v; want %v", e, err)
+	}
+	if e := w.Flush(); e != err {
+		t.Errorf("Flush: got %v; want %v", e, err)
+	}
+	if _, e := w.Barrier(); e != err {
+		t.Errorf("Barrier: got %v; want %v", e, err)
+	}
+}
+
+func TestReaderReadRune(t *testing.T) {
+	const data = "aé世😀z"
+	r, cleanup := openTemp(t, data)
+	defer cleanup()
+
+	var off int64
+	for _, want := range data {
+		c, size, err := r.ReadRune()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != want || size != utf8.RuneLen(want) {
+			t.Errorf("got %q (size %d); want %q (size %d)", c, size, want, utf8.RuneLen(want))
+		}
+		off += int64(size)
+		if got := r.Offset(); got != off {
+			t.Errorf("after %q: got offset %d; want %d", c, got, off)
+		}
+	}
+
+	// push back the last rune and read it again
+	if err := r.UnreadRune(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Offset(); got != off-1 {
+		t.Errorf("after UnreadRune: got offset %d; want %d", got, off-1)
+	}
+	if c, _, _ := r.ReadRune(); c != 'z' {
+		t.Errorf("got %q after UnreadRune; want 'z'", c)
+	}
+	if _, _, err := r.ReadRune(); err != io.EOF {
+		t.Errorf("got %v at end; want EOF", err)
+	}
+}
+
+func TestReaderReadUntilAny(t *testing.T) {
+	r, cleanup := openTemp(t, "key: value\r\nname=x;y\n\nrest")
+	defer cleanup()
+
+	for _, test := range []struct {
+		delims string
+		data   string
+		delim  byte
+		err    error
+	}{
+		{":=", "key", ':', nil},
+		{"\r\n", " value", '\r', nil}, // first delimiter found, not first in delims
+		{"\n", "", '\n', nil},
+		{";=\n", "name", '=', nil},
+		{"=\n;", "x", ';', nil},
+		{"\n", "y", '\n', nil},
+		{"\n", "", '\n', nil},
+		{"\n", "rest", 0, io.EOF},
+		{"\n", "", 0, io.EOF},
+	} {
+		data, delim, err := r.ReadUntilAny([]byte(test.delims))
+		if string(data) != test.data || delim != test.delim || err != test.err {
+			t.Errorf("ReadUntilAny(%q) = %q, %q, %v; want %q, %q, %v", test.delims, data, delim, err, test.data, test.delim, test.err)
+		}
+	}
+}
+
+func TestReaderGrow(t *testing.T) {
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	r, cleanup := openTemp(t, string(data))
+	defer cleanup()
+
+	check := func(n int) {
+		t.Helper()
+		off := r.Offset()
+		buf := make([]byte, n)
+		if _, err := r.ReadFull(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, data[off:off+int64(n)]) {
+			t.Fatalf("at offset %d: got wrong data", off)
+		}
+	}
+
+	check(1000)
+	r.Grow(1 << 16)
+	if got := r.Offset(); got != 1000 {
+		t.Errorf("got offset %d after Grow; want 1000", got)
+	}
+	check(50000)
+
+	// the larger buffer is used
+	if b, err := r.Peek(30000); err != nil || !bytes.Equal(b, data[51000:81000]) {
+		t.Errorf("Peek after Grow: got %d bytes, %v", len(b), err)
+	}
+
+	r.Grow(100) // no effect
+	check(10000)
+	r.Seek(5000, 0)
+	check(20000)
+}
+
+func TestReaderTee(t *testing.T) {
+	data := make([]byte, 20000)
+	for i := range data {
+		data[i] = byte(i % 253)
+	}
+	r, cleanup := openTemp(t, string(data))
+	defer cleanup()
+
+	if _, err := r.Peek(10); err != nil {
+		t.Fatal(err)
+	}
+	var tee bytes.Buffer
+	r.Tee(&tee) // after buffering some data
+	b, _ := r.ReadByte()
+	line, _ := r.ReadString(0)
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(append([]byte{b}, line...), rest...); !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes of wrong data", len(got))
+	}
+	if !bytes.Equal(tee.Bytes(), data) {
+		t.Errorf("tee got %d bytes; want the %d bytes of the file", tee.Len(), len(data))
+	}
+
+	// data read again after seeking is written again
+	tee.Reset()
+	r.Seek(19000, 0)
+	ioutil.ReadAll(r)
+	if !bytes.Equal(tee.Bytes(), data[19000:]) {
+		t.Errorf("tee got %d bytes after Seek; want %d", tee.Len(), 1000)
+	}
+
+	// without tee
+	tee.Reset()
+	r.Tee(nil)
+	r.Seek(0, 0)
+	ioutil.ReadAll(r)
+	if tee.Len() != 0 {
+		t.Errorf("got %d bytes after removing tee", tee.Len())
+	}
+}
+
+func TestReaderContentHash(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+	r, cleanup := openTemp(t, data)
+	defer cleanup()
+
+	if _, err := r.Discard(1234); err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	if err := r.ContentHash(h); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.Sum(nil), sha256.Sum256([]byte(data)); !bytes.Equal(got, want[:]) {
+		t.Errorf("got hash %x; want %x", got, want)
+	}
+	if off := r.Offset(); off != 1234 {
+		t.Errorf("offset after ContentHash = %d; want 1234", off)
+	}
+	if c, err := r.ReadByte(); err != nil || c != data[1234] {
+		t.Errorf("ReadByte after ContentHash = %q, %v; want %q", c, err, data[1234])
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	pipe := NewReader(pr)
+	defer pipe.Close()
+	if err := pipe.ContentHash(sha256.New()); err == nil {
+		t.Error("ContentHash succeeded for a pipe")
+	}
+}
+
+func TestReaderSection(t *testing.T) {
+	r, cleanup := openTemp(t, "0123456789")
+	defer cleanup()
+
+	if b, _ := r.ReadByte(); b != '0' {
+		t.Fatalf("got %q; want '0'", b)
+	}
+
+	s1, err := r.Section(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := r.Section(4, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// interleaved reads from overlapping sections
+	buf := make([]byte, 3)
+	for _, test := range []struct {
+		s    *io.SectionReader
+		want string
+	}{
+		{s1, "234"},
+		{s2, "456"},
+		{s1, "56"},
+		{s2, "789"},
+	} {
+		n, _ := test.s.Read(buf)
+		if got := string(buf[:n]); got != test.want {
+			t.Errorf("got %q; want %q", got, test.want)
+		}
+	}
+	if n, err := s1.Read(buf); n != 0 || err != io.EOF {
+		t.Errorf("got %d, %v at end of section; want 0, EOF", n, err)
+	}
+
+	// the Reader is not affected
+	if b, _ := r.ReadByte(); b != '1' {
+		t.Errorf("got %q; want '1'", b)
+	}
+	if off := r.Offset(); off != 2 {
+		t.Errorf("got offset %d; want 2", off)
+	}
+
+	if _, err := r.Section(-1, 2); err == nil {
+		t.Error("no error for invalid section")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"os"
+)
+
+// errCompressed is returned by methods that require file offsets
+// if the file is compressed.
+var errCompressed = errors.New("compressed file is not seekable")
+
+// CreateCompressed creates the file named name and returns a Writer
+// that writes gzip-compressed output to it. Offsets in the compressed
+// file are not related to the data written to the Writer; therefore
+// Seek and Offset must not be used, and methods depending on offsets,
+// such as Barrier or Align, return an error.
+func CreateCompressed(name string) (*Writer, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	w := newWriter(f, gz)
+	w.gz = gz
+	return w, nil
+}
+
+// OpenCompressed returns a Reader for the gzip-compressed file named
+// name, which returns the decompressed data. As for Writers created by
+// CreateCompressed, Seek and Offset must not be used, and methods
+// depending on offsets return an error.
+func OpenCompressed(name string) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Reader{f: f, name: name, Reader: bufio.NewReader(gz), gz: gz}, nil
+}
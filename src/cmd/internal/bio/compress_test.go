@@ -0,0 +1,91 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressed(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+
+	data := strings.Repeat("compressible data\n", 1000)
+	w, err := CreateCompressed(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(data)
+	if _, err := w.Barrier(); err != errCompressed {
+		t.Errorf("Barrier: got %v; want %v", err, errCompressed)
+	}
+	if err := w.Align(8, 0); err != errCompressed {
+		t.Errorf("Align: got %v; want %v", err, errCompressed)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() >= int64(len(data)) {
+		t.Errorf("got compressed file size %d; want < %d", fi.Size(), len(data))
+	}
+
+	// the output is a regular gzip file
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(gz); err != nil || string(got) != data {
+		t.Errorf("reading with gzip: got %d bytes, %v; want %d bytes", len(got), err, len(data))
+	}
+
+	r, err := OpenCompressed(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Seekable() {
+		t.Error("compressed Reader is seekable")
+	}
+	if _, err := r.Section(0, 1); err == nil {
+		t.Error("no error from Section")
+	}
+	line, err := r.ReadString('\n')
+	if err != nil || line != "compressible data\n" {
+		t.Errorf("got %q, %v; want first line", line, err)
+	}
+	if got, err := ioutil.ReadAll(r); err != nil || line+string(got) != data {
+		t.Errorf("got %d bytes, %v; want %d bytes", len(line)+len(got), err, len(data))
+	}
+
+	// Reopen starts decompressing again
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(r); err != nil || string(got) != data {
+		t.Errorf("after Reopen: got %d bytes, %v; want %d bytes", len(got), err, len(data))
+	}
+}
+
+func TestOpenCompressedError(t *testing.T) {
+	name, cleanup := tempFile(t, "not compressed")
+	defer cleanup()
+	if _, err := OpenCompressed(name); err == nil {
+		t.Error("no error opening uncompressed file")
+	}
+}
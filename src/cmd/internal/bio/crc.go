@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var errCRCTrailer = errors.New("output with CRC trailer is not seekable")
+
+// SetCRCTrailer arranges for Close to append the CRC-32 (IEEE) checksum
+// of all data written to w, as 4 bytes in the given byte order, so that
+// the output can be verified by readers. It must be called before any
+// data is written to w. Since the checksum is computed as the data is
+// written, Seek, WriteAt, and Truncate (and hence Patch.Fill) fail for
+// w afterwards.
+func (w *Writer) SetCRCTrailer(order binary.ByteOrder) error {
+	if w.crc != nil {
+		return errors.New("CRC trailer already set")
+	}
+	if w.Buffered() > 0 {
+		return errors.New("CRC trailer set after writing data")
+	}
+	if w.gz == nil {
+		if off, err := w.f.Seek(0, 1); err == nil && off > 0 {
+			return errors.New("CRC trailer set after writing data")
+		}
+	}
+	w.crc = &crcWriter{w: w.dst(), h: crc32.NewIEEE(), order: order}
+	w.setDst(w.crc)
+	return nil
+}
+
+// A crcWriter computes the checksum of the data written through it.
+type crcWriter struct {
+	w     io.Writer
+	h     hash.Hash32
+	order binary.ByteOrder
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// writeTrailer writes the checksum of the data written so far.
+// The trailer itself is not checksummed.
+func (c *crcWriter) writeTrailer() error {
+	var buf [4]byte
+	c.order.PutUint32(buf[:], c.h.Sum32())
+	_, err := c.w.Write(buf[:])
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWriterCRCTrailer(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		name, cleanup := tempFile(t, "")
+		defer cleanup()
+		w, err := Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.SetCRCTrailer(order); err != nil {
+			t.Fatal(err)
+		}
+		data := strings.Repeat("some data\n", 1000) // larger than the buffer
+		w.WriteString(data)
+		w.WriteByte('!')
+		if _, err := w.WriteAt([]byte("x"), 0); err != errCRCTrailer {
+			t.Errorf("WriteAt: got %v; want %v", err, errCRCTrailer)
+		}
+		if err := w.Truncate(0); err != errCRCTrailer {
+			t.Errorf("Truncate: got %v; want %v", err, errCRCTrailer)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := len(out) - 4
+		if n != len(data)+1 || string(out[:n]) != data+"!" {
+			t.Fatalf("%v: got %d bytes of data; want %d", order, n, len(data)+1)
+		}
+		if got, want := order.Uint32(out[n:]), crc32.ChecksumIEEE(out[:n]); got != want {
+			t.Errorf("%v: got CRC %#x; want %#x", order, got, want)
+		}
+	}
+}
+
+func TestWriterCRCTrailerErrors(t *testing.T) {
+	w := NewMemWriter()
+	w.WriteString("data")
+	if err := w.SetCRCTrailer(binary.LittleEndian); err == nil {
+		t.Error("SetCRCTrailer succeeded after a write")
+	}
+	w.Flush()
+	if err := w.SetCRCTrailer(binary.LittleEndian); err == nil {
+		t.Error("SetCRCTrailer succeeded after a flushed write")
+	}
+
+	w = NewMemWriter()
+	if err := w.SetCRCTrailer(binary.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetCRCTrailer(binary.LittleEndian); err == nil {
+		t.Error("second SetCRCTrailer succeeded")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"errors"
+	"time"
+)
+
+var errNoDeadline = errors.New("file type does not support deadline")
+
+// A deadliner is a file that supports read deadlines, such as an
+// *os.File since Go 1.10. The method is looked up dynamically since
+// this package must build with Go 1.4.
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// SetReadDeadline sets the deadline for future reads from the
+// underlying file of r, for files that support deadlines, such as
+// pipes and sockets; a zero t means no deadline. Once the deadline
+// has passed, reads that need to read from the file fail with a
+// timeout error, for which os.IsTimeout reports true; data that is
+// already buffered can still be read. For regular files, which don't
+// support deadlines, and for Readers created by NewMemReader,
+// SetReadDeadline returns an error.
+func (r *Reader) SetReadDeadline(t time.Time) error {
+	f, ok := r.f.(deadliner)
+	if !ok {
+		return errNoDeadline
+	}
+	return f.SetReadDeadline(t)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReaderSetReadDeadline(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	r := NewReader(pr)
+	defer r.Close()
+
+	pw.Write([]byte("a"))
+	if err := r.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Skipf("pipes don't support deadlines: %v", err)
+	}
+	if c, err := r.ReadByte(); c != 'a' || err != nil {
+		t.Fatalf("ReadByte() = %q, %v; want 'a', nil", c, err)
+	}
+
+	// nothing more is written
+	start := time.Now()
+	_, err = r.ReadByte()
+	if err == nil || !os.IsTimeout(err) {
+		t.Fatalf("got %v; want timeout error", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("read returned after %v", d)
+	}
+
+	// without a deadline, reading continues
+	if err := r.SetReadDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	pw.Write([]byte("b"))
+	if c, err := r.ReadByte(); c != 'b' || err != nil {
+		t.Errorf("after timeout: ReadByte() = %q, %v; want 'b', nil", c, err)
+	}
+}
+
+func TestReaderSetReadDeadlineUnsupported(t *testing.T) {
+	r, cleanup := openTemp(t, "data")
+	defer cleanup()
+	if err := r.SetReadDeadline(time.Now().Add(time.Second)); err == nil {
+		t.Error("regular file: got no error")
+	}
+	if err := NewMemReader(nil).SetReadDeadline(time.Now()); err == nil {
+		t.Error("memory reader: got no error")
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build amd64 arm64
+
+package bio
+
+import (
+	"os"
+	"syscall"
+)
+
+const _POSIX_FADV_WILLNEED = 3
+
+// fadviseWillNeed tells the kernel that the n bytes of f starting
+// at offset off will be read soon. It is only a hint; errors are
+// ignored.
+func fadviseWillNeed(f *os.File, off, n int64) {
+	syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), uintptr(off), uintptr(n), _POSIX_FADV_WILLNEED, 0, 0)
+}
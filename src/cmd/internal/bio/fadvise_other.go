@@ -0,0 +1,13 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux !amd64,!arm64
+
+package bio
+
+import "os"
+
+// fadviseWillNeed does nothing; there is no (simple) way
+// to give the kernel a read-ahead hint on this platform.
+func fadviseWillNeed(f *os.File, off, n int64) {}
@@ -0,0 +1,37 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import "encoding/gob"
+
+// WriteGob writes the gob encoding of v to w. All calls of WriteGob
+// for w share a single gob.Encoder, so the values written form one gob
+// stream: the type of a value is transmitted only the first time it is
+// written, and the stream must be read from its start with ReadGob,
+// one value per call. Other data may be written between the values if
+// the reader consumes it between the calls of ReadGob. Since the values
+// are written to the buffer of w, Offset stays accurate.
+func (w *Writer) WriteGob(v interface{}) error {
+	if w.enc == nil {
+		w.enc = gob.NewEncoder(w)
+	}
+	return w.enc.Encode(v)
+}
+
+// ReadGob reads the next value of the gob stream written by WriteGob
+// from r and stores it in v, which must be a pointer, as for
+// gob.Decoder.Decode. All calls of ReadGob for r share a single
+// gob.Decoder. Since a Reader is an io.ByteReader, the decoder reads no
+// further than the end of the value; Offset remains accurate and other
+// data following the value may be read between calls. Seeking into the
+// middle of the stream loses the type information sent earlier, so
+// later values may fail to decode.
+func (r *Reader) ReadGob(v interface{}) error {
+	if r.dec == nil {
+		// Not r.Reader: Grow and SetReadahead replace the buffer.
+		r.dec = gob.NewDecoder(r)
+	}
+	return r.dec.Decode(v)
+}
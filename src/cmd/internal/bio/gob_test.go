@@ -0,0 +1,69 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+type cacheEntry struct {
+	Key  string
+	Deps []string
+	Size int64
+}
+
+func TestWriterWriteGob(t *testing.T) {
+	values := []cacheEntry{
+		{"fmt", []string{"io", "os", "strconv"}, 1 << 20},
+		{"io", nil, 4096},
+	}
+
+	w := NewMemWriter()
+	w.WriteString("hdr")
+	var offsets []int64
+	for _, v := range values {
+		if err := w.WriteGob(&v); err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, w.Offset())
+		w.WriteByte('|') // other data between the values
+	}
+	if err := w.WriteGob(42); err != nil {
+		t.Fatal(err)
+	}
+	data := w.Bytes()
+	if offsets[1]-offsets[0] >= offsets[0]-3 {
+		t.Errorf("got gob sizes %d, %d; want the type sent only once", offsets[0]-3, offsets[1]-offsets[0])
+	}
+
+	r := NewMemReader(data)
+	if err := r.Expect([]byte("hdr")); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range values {
+		var got cacheEntry
+		if err := r.ReadGob(&got); err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("value %d: got %+v; want %+v", i, got, want)
+		}
+		if off := r.Offset(); off != offsets[i] {
+			t.Errorf("value %d: got offset %d; want %d", i, off, offsets[i])
+		}
+		if b, err := r.ReadByte(); err != nil || b != '|' {
+			t.Errorf("value %d: got %q, %v after the value; want '|'", i, b, err)
+		}
+	}
+	var n int
+	if err := r.ReadGob(&n); err != nil || n != 42 {
+		t.Errorf("got %d, %v; want 42", n, err)
+	}
+	if err := r.ReadGob(&n); err != io.EOF {
+		t.Errorf("got %v at the end of the stream; want EOF", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// A LineWriter is a buffered writer that flushes its buffer after
+// each write containing a newline, up to and including the last
+// newline written, so complete lines appear promptly on the
+// underlying writer (for instance, when streaming a log to a
+// terminal). A trailing partial line stays buffered until the next
+// newline is written or Flush is called.
+type LineWriter struct {
+	w *bufio.Writer
+}
+
+// NewLineWriter returns a LineWriter writing to w.
+func NewLineWriter(w io.Writer) *LineWriter {
+	return &LineWriter{bufio.NewWriter(w)}
+}
+
+var newline = []byte{'\n'}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	i := bytes.LastIndex(p, newline) + 1
+	if i == 0 {
+		return w.w.Write(p)
+	}
+	n, err := w.w.Write(p[:i])
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if err != nil {
+		return n, err
+	}
+	m, err := w.w.Write(p[i:])
+	return n + m, err
+}
+
+func (w *LineWriter) WriteString(s string) (int, error) {
+	i := strings.LastIndex(s, "\n") + 1
+	if i == 0 {
+		return w.w.WriteString(s)
+	}
+	n, err := w.w.WriteString(s[:i])
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if err != nil {
+		return n, err
+	}
+	m, err := w.w.WriteString(s[i:])
+	return n + m, err
+}
+
+// Flush writes any buffered partial line to the underlying writer.
+func (w *LineWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// EachLine calls f for each line read from r, until f returns false or
+// the input ends. It passes the line without its terminating "\n" or
+// "\r\n"; a final line without newline is passed as well, unless it
+// is empty. The slice passed to f aliases the buffer of r if possible
+// and is only valid during the call: f must not retain or modify it.
+// Lines longer than the buffer are assembled in a separate buffer that
+// is reused for later long lines, so EachLine does not allocate per
+// line. At the end of the input, EachLine returns nil.
+func (r *Reader) EachLine(f func(line []byte) bool) error {
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			r.long = append(r.long[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = r.ReadSlice('\n')
+				r.long = append(r.long, line...)
+			}
+			line = r.long
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) > 0 {
+			if line[len(line)-1] == '\n' {
+				line = line[:len(line)-1]
+				if len(line) > 0 && line[len(line)-1] == '\r' {
+					line = line[:len(line)-1]
+				}
+			}
+			if !f(line) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
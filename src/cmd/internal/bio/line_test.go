@@ -0,0 +1,101 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var sink bytes.Buffer
+	w := NewLineWriter(&sink)
+
+	check := func(want string) {
+		t.Helper()
+		if got := sink.String(); got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	}
+
+	w.Write([]byte("a\nb"))
+	check("a\n")
+
+	w.WriteString("c")
+	check("a\n")
+
+	w.WriteString("d\ne\nf")
+	check("a\nbcd\ne\n")
+
+	w.Write([]byte("\n"))
+	check("a\nbcd\ne\nf\n")
+
+	w.WriteString("g")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	check("a\nbcd\ne\nf\ng")
+}
+
+func TestReaderEachLine(t *testing.T) {
+	long := strings.Repeat("0123456789", 1000) // longer than the buffer
+	for _, test := range []struct {
+		data string
+		want []string
+	}{
+		{"", nil},
+		{"\n", []string{""}},
+		{"a", []string{"a"}},
+		{"a\nb\n", []string{"a", "b"}},
+		{"a\n\nb", []string{"a", "", "b"}},
+		{"dos\r\nline\r\n", []string{"dos", "line"}},
+		{"cr\r in line\n", []string{"cr\r in line"}},
+		{long + "\nshort\n" + long + "x", []string{long, "short", long + "x"}},
+	} {
+		r := NewMemReader([]byte(test.data))
+		var got []string
+		err := r.EachLine(func(line []byte) bool {
+			got = append(got, string(line))
+			return true
+		})
+		if err != nil {
+			t.Errorf("%s: %v", shortData(test.data), err)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") || len(got) != len(test.want) {
+			t.Errorf("%s: got %d lines %q; want %d lines %q", shortData(test.data), len(got), got, len(test.want), test.want)
+		}
+	}
+}
+
+func TestReaderEachLineStop(t *testing.T) {
+	r := NewMemReader([]byte("one\ntwo\nthree\nfour\n"))
+	var got []string
+	err := r.EachLine(func(line []byte) bool {
+		got = append(got, string(line))
+		return string(line) != "two"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "one two"; strings.Join(got, " ") != want {
+		t.Errorf("got %q; want %s", got, want)
+	}
+	if off := r.Offset(); off != 8 {
+		t.Errorf("got offset %d after stopping; want 8", off)
+	}
+
+	// reading resumes after the last line passed to f
+	got = got[:0]
+	if err := r.EachLine(func(line []byte) bool {
+		got = append(got, string(line))
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "three four"; strings.Join(got, " ") != want {
+		t.Errorf("got %q after resuming; want %s", got, want)
+	}
+}
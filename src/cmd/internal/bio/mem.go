@@ -0,0 +1,131 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// NewMemReader returns a Reader which reads b instead of a file.
+// All methods work as they do for a file, including Seek, Offset,
+// Section, and ContentHash. Seeking past the end of b is permitted;
+// reading there returns io.EOF. Stat and Reopen fail.
+func NewMemReader(b []byte) *Reader {
+	f := memReader{bytes.NewReader(b)}
+	return &Reader{f: f, Reader: bufio.NewReader(f)}
+}
+
+// A memReader is the input of a Reader created by NewMemReader.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error { return nil }
+
+// NewMemWriter returns a Writer whose output is kept in memory instead
+// of being written to a file. All methods work as they do for a file,
+// including Seek, Offset, WriteAt, and Truncate. Use Bytes to retrieve
+// the output.
+func NewMemWriter() *Writer {
+	f := new(memFile)
+	return newWriter(f, f)
+}
+
+// Bytes flushes w and returns the output written to a Writer created
+// by NewMemWriter. The result is only valid until the next write; it
+// remains available after Close. For other Writers, Bytes returns nil.
+func (w *Writer) Bytes() []byte {
+	f, ok := w.f.(*memFile)
+	if !ok {
+		return nil
+	}
+	w.FlushNow()
+	return f.data
+}
+
+// A memFile is an in-memory file. Like a regular file, it grows as
+// needed when writing beyond its end, and the gap reads as zeros.
+type memFile struct {
+	data []byte
+	off  int64
+}
+
+var errNegativeOffset = errors.New("negative offset")
+
+func (f *memFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		f.Truncate(end)
+	}
+	return copy(f.data[off:], p), nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case 0:
+		// relative to the start
+	case 1:
+		offset += f.off
+	case 2:
+		offset += int64(len(f.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if size < 0 {
+		return errNegativeOffset
+	}
+	if size <= int64(len(f.data)) {
+		f.data = f.data[:size]
+		return nil
+	}
+	if size <= int64(cap(f.data)) {
+		// clear bytes left over from before an earlier truncation
+		old := len(f.data)
+		f.data = f.data[:size]
+		for i := old; i < len(f.data); i++ {
+			f.data[i] = 0
+		}
+		return nil
+	}
+	f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	return nil
+}
+
+func (f *memFile) Close() error { return nil }
+func (f *memFile) Sync() error  { return nil }
+func (f *memFile) Name() string { return "<memory>" }
@@ -0,0 +1,147 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestMemWriter(t *testing.T) {
+	w := NewMemWriter()
+
+	// write a header with a size field to be filled in later
+	w.WriteString("hdr:")
+	sizeOff := w.Offset()
+	w.WriteUint32(binary.LittleEndian, 0)
+	start := w.Offset()
+	w.WriteString("payload")
+	size := w.Offset() - start
+
+	// backpatch using Seek
+	w.Seek(sizeOff, 0)
+	w.WriteUint32(binary.LittleEndian, uint32(size))
+	w.Seek(0, 2)
+	w.WriteString("!")
+
+	want := "hdr:\x07\x00\x00\x00payload!"
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// WriteAt doesn't change the offset
+	if _, err := w.WriteAt([]byte("HDR"), 0); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("?")
+	want = "HDR:\x07\x00\x00\x00payload!?"
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("got %q after WriteAt; want %q", got, want)
+	}
+
+	// truncate and continue at the new end
+	if err := w.Truncate(11); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(w.Bytes()), "HDR:\x07\x00\x00\x00pay"; got != want {
+		t.Errorf("got %q after Truncate; want %q", got, want)
+	}
+	if off := w.Seek(0, 2); off != 11 {
+		t.Errorf("got end offset %d after Truncate; want 11", off)
+	}
+	w.WriteString("PAY")
+
+	// growing fills with zeros, also where data was truncated before
+	if err := w.Truncate(16); err != nil {
+		t.Fatal(err)
+	}
+	// writing beyond the end leaves a gap of zeros
+	w.Seek(18, 0)
+	w.WriteByte('x')
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want = "HDR:\x07\x00\x00\x00payPAY\x00\x00\x00\x00x"
+	if got := string(w.Bytes()); got != want {
+		t.Errorf("got %q after Close; want %q", got, want)
+	}
+}
+
+func TestMemWriterEnsureTrailingNewline(t *testing.T) {
+	w := NewMemWriter()
+	w.EnsureTrailingNewline() // empty output: nothing to do
+	w.WriteString("a")
+	w.EnsureTrailingNewline()
+	w.EnsureTrailingNewline()
+	if got := string(w.Bytes()); got != "a\n" {
+		t.Errorf("got %q; want %q", got, "a\n")
+	}
+}
+
+func TestMemReader(t *testing.T) {
+	data := []byte("0123456789abcdef")
+	r := NewMemReader(data)
+	defer r.Close()
+
+	if !r.Seekable() {
+		t.Error("Seekable() = false; want true")
+	}
+	if b, err := r.Peek(4); string(b) != "0123" || err != nil {
+		t.Errorf("Peek(4) = %q, %v; want %q, nil", b, err, "0123")
+	}
+	if off := r.Offset(); off != 0 {
+		t.Errorf("got offset %d after Peek; want 0", off)
+	}
+	r.Discard(3)
+	if off := r.Offset(); off != 3 {
+		t.Errorf("got offset %d; want 3", off)
+	}
+
+	if off := r.Seek(10, 0); off != 10 {
+		t.Errorf("Seek(10, 0) = %d; want 10", off)
+	}
+	if c, err := r.ReadByte(); c != 'a' || err != nil {
+		t.Errorf("ReadByte() = %q, %v after Seek; want 'a', nil", c, err)
+	}
+	if off := r.Seek(-2, 1); off != 9 {
+		t.Errorf("Seek(-2, 1) = %d; want 9", off)
+	}
+	if off := r.Seek(-1, 2); off != 15 {
+		t.Errorf("Seek(-1, 2) = %d; want 15", off)
+	}
+
+	// seeking past the end is permitted
+	if off := r.Seek(100, 0); off != 100 {
+		t.Errorf("Seek(100, 0) = %d; want 100", off)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("ReadByte() past end: got %v; want io.EOF", err)
+	}
+	if off := r.Offset(); off != 100 {
+		t.Errorf("got offset %d past end; want 100", off)
+	}
+
+	// ReadAt via Section doesn't change the offset
+	sec, err := r.Section(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf [3]byte
+	if n, err := sec.ReadAt(buf[:], 0); string(buf[:n]) != "456" || err != nil {
+		t.Errorf("ReadAt = %q, %v; want %q, nil", buf[:n], err, "456")
+	}
+	if n, err := sec.ReadAt(buf[:], 2); string(buf[:n]) != "6" || err != io.EOF {
+		t.Errorf("ReadAt at end of section = %q, %v; want %q, io.EOF", buf[:n], err, "6")
+	}
+	if off := r.Offset(); off != 100 {
+		t.Errorf("got offset %d after ReadAt; want 100", off)
+	}
+
+	if _, err := r.Stat(); err == nil {
+		t.Error("Stat succeeded for in-memory Reader")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// WriteGoString writes s to w as a double-quoted Go string literal,
+// as produced by strconv.Quote, and returns the number of bytes
+// written. The literal is built in a buffer that is reused by later
+// calls.
+func (w *Writer) WriteGoString(s string) (int, error) {
+	w.tmp = strconv.AppendQuote(w.tmp[:0], s)
+	return w.Write(w.tmp)
+}
+
+// WriteGoByte writes b to w as a single-quoted Go rune literal with
+// value b and returns the number of bytes written. Bytes that are not
+// ASCII are written as hexadecimal escapes, such as '\xff'.
+func (w *Writer) WriteGoByte(b byte) (int, error) {
+	buf := w.scratch[:0]
+	if b < utf8.RuneSelf {
+		buf = strconv.AppendQuoteRune(buf, rune(b))
+	} else {
+		const hex = "0123456789abcdef"
+		buf = append(buf, '\'', '\\', 'x', hex[b>>4], hex[b&0xf], '\'')
+	}
+	return w.Write(buf)
+}
+
+// WriteByteSliceLiteral writes data to w as a Go composite literal of
+// type []byte, with the elements in hexadecimal form such as 0x2a,
+// and returns the number of bytes written. If perLine > 0, the
+// elements are written perLine to a line, indented by a tab, as
+// formatted by gofmt:
+//
+//	[]byte{
+//		0x01, 0x02, 0x03,
+//		0x04,
+//	}
+//
+// Otherwise, or if data is empty, the literal is written on a single
+// line. The literal is streamed into the buffer of w.
+func (w *Writer) WriteByteSliceLiteral(data []byte, perLine int) (int, error) {
+	const hex = "0123456789abcdef"
+	cw := countWriter{w: w.Writer}
+	multiLine := perLine > 0 && len(data) > 0
+	io.WriteString(&cw, "[]byte{")
+	for i, b := range data {
+		buf := w.scratch[:0]
+		switch {
+		case multiLine && i%perLine == 0:
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, '\n', '\t')
+		case i > 0:
+			buf = append(buf, ',', ' ')
+		}
+		buf = append(buf, '0', 'x', hex[b>>4], hex[b&0xf])
+		cw.Write(buf)
+	}
+	if multiLine {
+		io.WriteString(&cw, ",\n")
+	}
+	_, err := io.WriteString(&cw, "}") // errors are sticky
+	return cw.n, err
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriterWriteGoString(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"hello",
+		`say "hi"`,
+		`back\slash`,
+		"line 1\nline 2\r\n\ttab",
+		"naïve 世界 ☺",
+		"\x00\x7f\xff invalid UTF-8",
+		strings.Repeat("long \"quoted\" text\n", 500), // larger than the buffer when quoted
+	} {
+		w := NewMemWriter()
+		w.WriteByte('=')
+		n, err := w.WriteGoString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "=" + strconv.Quote(s)
+		got := string(w.Bytes())
+		if got != want {
+			t.Errorf("%q: got %s; want %s", s, got, want)
+			continue
+		}
+		if n != len(want)-1 {
+			t.Errorf("%q: got n = %d; want %d", s, n, len(want)-1)
+		}
+		if u, err := strconv.Unquote(got[1:]); err != nil || u != s {
+			t.Errorf("%q: unquoted %s: got %q, %v", s, got[1:], u, err)
+		}
+	}
+}
+
+func TestWriterWriteGoByte(t *testing.T) {
+	for _, test := range []struct {
+		b    byte
+		want string
+	}{
+		{'a', `'a'`},
+		{'\'', `'\''`},
+		{'"', `'"'`},
+		{'\\', `'\\'`},
+		{'\n', `'\n'`},
+		{0, `'\x00'`},
+		{0x7f, `'\x7f'`},
+		{0x80, `'\x80'`},
+		{0xe9, `'\xe9'`},
+		{0xff, `'\xff'`},
+	} {
+		w := NewMemWriter()
+		n, err := w.WriteGoByte(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(w.Bytes()); got != test.want || n != len(test.want) {
+			t.Errorf("%#x: got %s (n = %d); want %s", test.b, got, n, test.want)
+		}
+	}
+
+	// every byte must round-trip
+	for i := 0; i < 256; i++ {
+		w := NewMemWriter()
+		w.WriteGoByte(byte(i))
+		lit := string(w.Bytes())
+		v, _, tail, err := strconv.UnquoteChar(lit[1:len(lit)-1], '\'')
+		if err != nil || tail != "" || v != rune(i) {
+			t.Errorf("%#x: %s unquotes to %#x, %q, %v", i, lit, v, tail, err)
+		}
+	}
+}
+
+func TestWriterWriteByteSliceLiteral(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	for _, test := range []struct {
+		data    []byte
+		perLine int
+		want    string // if not empty
+	}{
+		{nil, 8, "[]byte{}"},
+		{nil, 0, "[]byte{}"},
+		{[]byte{1, 2, 0xff}, 0, "[]byte{0x01, 0x02, 0xff}"},
+		{[]byte{1, 2, 0xff}, 8, "[]byte{\n\t0x01, 0x02, 0xff,\n}"},
+		{[]byte{1, 2, 3, 4}, 2, "[]byte{\n\t0x01, 0x02,\n\t0x03, 0x04,\n}"},
+		{[]byte{1, 2, 3, 4, 0xab}, 2, "[]byte{\n\t0x01, 0x02,\n\t0x03, 0x04,\n\t0xab,\n}"},
+		{data, 12, ""},
+		{data, 1, ""},
+		{data, -1, ""},
+	} {
+		w := NewMemWriter()
+		n, err := w.WriteByteSliceLiteral(test.data, test.perLine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(w.Bytes())
+		if n != len(got) {
+			t.Errorf("%d bytes, perLine %d: got n = %d; wrote %d bytes", len(test.data), test.perLine, n, len(got))
+		}
+		if test.want != "" && got != test.want {
+			t.Errorf("%d bytes, perLine %d: got\n%s\nwant\n%s", len(test.data), test.perLine, got, test.want)
+			continue
+		}
+
+		// the literal must be valid Go, formatted as by gofmt
+		if formatted, err := format.Source([]byte(got)); err != nil {
+			t.Errorf("%d bytes, perLine %d: %v", len(test.data), test.perLine, err)
+		} else if string(formatted) != got {
+			t.Errorf("%d bytes, perLine %d: not formatted; got\n%s\nwant\n%s", len(test.data), test.perLine, got, formatted)
+		}
+
+		// and denote data
+		x, err := parser.ParseExpr(got)
+		if err != nil {
+			t.Errorf("%d bytes, perLine %d: %v", len(test.data), test.perLine, err)
+			continue
+		}
+		lit := x.(*ast.CompositeLit)
+		var elems []byte
+		for _, e := range lit.Elts {
+			v, err := strconv.ParseUint(e.(*ast.BasicLit).Value, 0, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			elems = append(elems, byte(v))
+		}
+		if string(elems) != string(test.data) {
+			t.Errorf("%d bytes, perLine %d: literal denotes %x", len(test.data), test.perLine, elems)
+		}
+	}
+}
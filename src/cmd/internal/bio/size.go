@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import "unicode/utf8"
+
+// A SizeWriter counts the bytes written to it and discards them.
+// It has the same writing methods as a Writer, so that a generator
+// can be run once with a SizeWriter to compute the size of its output,
+// for instance for a size header, and then again with a Writer to
+// emit the output. The zero value is ready to use.
+type SizeWriter struct {
+	n int64
+}
+
+func (w *SizeWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (w *SizeWriter) WriteString(s string) (int, error) {
+	w.n += int64(len(s))
+	return len(s), nil
+}
+
+func (w *SizeWriter) WriteByte(c byte) error {
+	w.n++
+	return nil
+}
+
+// WriteRune counts the size of the UTF-8 encoding of r, as written by
+// Writer.WriteRune; invalid runes are counted as utf8.RuneError.
+func (w *SizeWriter) WriteRune(r rune) (int, error) {
+	n := utf8.RuneLen(r)
+	if n < 0 {
+		n = utf8.RuneLen(utf8.RuneError)
+	}
+	w.n += int64(n)
+	return n, nil
+}
+
+// Offset returns the number of bytes written to w so far.
+func (w *SizeWriter) Offset() int64 {
+	return w.n
+}
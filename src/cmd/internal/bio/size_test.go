@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSizeWriter(t *testing.T) {
+	// gen writes the same output to a SizeWriter and a Writer.
+	type writer interface {
+		io.Writer
+		WriteString(string) (int, error)
+		WriteByte(byte) error
+		WriteRune(rune) (int, error)
+		Offset() int64
+	}
+	gen := func(w writer) int64 {
+		w.WriteString("header\n")
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(w, "line %d: ", i)
+			w.Write([]byte{'[', byte(i)})
+			w.WriteByte(']')
+			w.WriteRune('ä')
+			w.WriteRune('世')
+			w.WriteRune(0x10000)
+			w.WriteRune(utf8.MaxRune + 1) // invalid
+			w.WriteByte('\n')
+		}
+		return w.Offset()
+	}
+
+	var sw SizeWriter
+	size := gen(&sw)
+
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off := gen(w); off != size {
+		t.Errorf("got Writer offset %d; want %d", off, size)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != size {
+		t.Errorf("got file size %d; SizeWriter counted %d", fi.Size(), size)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A string table consists of its size in bytes, as a uvarint, followed
+// by the strings. Each string is its length, as a uvarint, followed by
+// its bytes. A string is identified by its offset in the table, which
+// is counted from the first byte after the size.
+
+// WriteStringTable writes a string table containing the strings in list
+// to w and returns the offset of each string in the table. Duplicate
+// strings are stored only once.
+func (w *Writer) WriteStringTable(list []string) ([]int, error) {
+	var data []byte
+	var tmp [binary.MaxVarintLen64]byte
+	offsets := make([]int, len(list))
+	index := make(map[string]int)
+	for i, s := range list {
+		off, ok := index[s]
+		if !ok {
+			off = len(data)
+			index[s] = off
+			n := binary.PutUvarint(tmp[:], uint64(len(s)))
+			data = append(data, tmp[:n]...)
+			data = append(data, s...)
+		}
+		offsets[i] = off
+	}
+
+	n := binary.PutUvarint(tmp[:], uint64(len(data)))
+	if _, err := w.Write(tmp[:n]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// A StringTable is a string table read by ReadStringTable.
+type StringTable struct {
+	strs map[int]string // strings by offset
+}
+
+// ReadStringTable reads a string table as written by WriteStringTable.
+// All strings are created when the table is read, so they are shared by
+// all lookups with the same offset. It is an error if the table is
+// truncated or a string extends beyond the end of the table.
+func (r *Reader) ReadStringTable() (*StringTable, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading string table: %v", err)
+	}
+	if size > uint64(int(^uint(0)>>1)) {
+		return nil, fmt.Errorf("reading string table: invalid size %d", size)
+	}
+	// Don't trust size for allocating the table; a corrupt size
+	// would otherwise cause a huge allocation. The buffer grows
+	// with the data actually read.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading string table: %v", err)
+	}
+	data := buf.Bytes()
+
+	all := string(data) // the strings share the memory of all
+	t := &StringTable{strs: make(map[int]string)}
+	for off := 0; off < len(data); {
+		n, k := binary.Uvarint(data[off:])
+		if k <= 0 || n > uint64(len(data)-off-k) {
+			return nil, fmt.Errorf("reading string table: invalid string at offset %d", off)
+		}
+		start := off + k
+		end := start + int(n)
+		t.strs[off] = all[start:end]
+		off = end
+	}
+	return t, nil
+}
+
+// Lookup returns the string at offset off in t. It is an error
+// if off is not the offset of a string in t.
+func (t *StringTable) Lookup(off int) (string, error) {
+	s, ok := t.strs[off]
+	if !ok {
+		return "", fmt.Errorf("invalid string table offset %d", off)
+	}
+	return s, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bio
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStringTable(t *testing.T) {
+	name, cleanup := tempFile(t, "")
+	defer cleanup()
+	w, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := []string{"main", "", "runtime.gopanic", "main", strings.Repeat("x", 200), "ä世"}
+	offsets, err := w.WriteStringTable(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("trailer")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if offsets[0] != 0 || offsets[3] != offsets[0] {
+		t.Errorf("got offsets %v; want duplicates to share offset 0", offsets)
+	}
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	tab, err := r.ReadStringTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, off := range offsets {
+		if s, err := tab.Lookup(off); s != list[i] || err != nil {
+			t.Errorf("Lookup(%d) = %.10q, %v; want %.10q, nil", off, s, err, list[i])
+		}
+	}
+	for _, off := range []int{-1, 1, offsets[len(offsets)-1] + 1, 1 << 20} {
+		if _, err := tab.Lookup(off); err == nil {
+			t.Errorf("Lookup(%d) succeeded", off)
+		}
+	}
+
+	// the table is followed by the rest of the file
+	if rest, _ := r.ReadString(0); rest != "trailer" {
+		t.Errorf("got %q after string table; want %q", rest, "trailer")
+	}
+}
+
+func TestStringTableErrors(t *testing.T) {
+	for _, data := range []string{
+		"",              // no size
+		"\x05abc",       // truncated table
+		"\x03\x05abc",   // string longer than table
+		"\x03\x01a\x01", // last string truncated
+		"\x01\x80",      // invalid uvarint
+	} {
+		r, cleanup := openTemp(t, data)
+		if tab, err := r.ReadStringTable(); err == nil {
+			t.Errorf("%q: got %v; want error", data, tab)
+		}
+		cleanup()
+	}
+}
+
+func TestStringTableHugeSize(t *testing.T) {
+	// The declared size must not be used to allocate the table.
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], 1<<40)
+	r := NewMemReader(append(size[:n], "\x01a"...))
+	_, err := r.ReadStringTable()
+	if err == nil || !strings.Contains(err.Error(), io.ErrUnexpectedEOF.Error()) {
+		t.Errorf("got error %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
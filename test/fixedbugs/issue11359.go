@@ -8,4 +8,4 @@
 // issue 11359.
 
 package p
-var ۶ = 0 // ERROR "identifier cannot begin with digit"
+var ۶ = 0 // ERROR "identifier cannot begin with digit U\+06F6 '۶' at offset [0-9]+"
@@ -8,8 +8,8 @@ package p
 
 const (
 	_ = iota
-	_ // ERROR "illegal character|invalid character"
-	_  // ERROR "illegal character|invalid character"
-	_  // ERROR "illegal character|invalid character"
-	_  // ERROR "illegal character|invalid character"
+	_ // ERROR "illegal character|invalid character U\+0007 at offset [0-9]+"
+	_  // ERROR "illegal character|invalid character U\+0008 at offset [0-9]+"
+	_  // ERROR "illegal character|invalid character U\+000B at offset [0-9]+"
+	_  // ERROR "illegal character|invalid character U\+000C at offset [0-9]+"
 )